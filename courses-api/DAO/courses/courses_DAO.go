@@ -1,14 +1,30 @@
 package courses
 
 type Course struct {
-	ID           int64   `bson:"id"`
-	Name         string  `bson:"name"`
-	Description  string  `bson:"description"`
-	Category     string  `bson:"category"`
-	Duration     string  `bson:"duration"`
-	InstructorID int64   `bson:"instructor_id"`
-	ImageBase64  string  `bson:"image_base64"`
-	Capacity     int     `bson:"capacity"`
-	Available    bool    `bson:"available"`
-	Rating       float64 `bson:"rating"`
+	ID            int64   `bson:"id"`
+	Name          string  `bson:"name"`
+	Description   string  `bson:"description"`
+	Category      string  `bson:"category"`
+	Duration      string  `bson:"duration"`
+	InstructorID  int64   `bson:"instructor_id"`
+	ImageBase64   string  `bson:"image_base64"`
+	Capacity      int     `bson:"capacity"`
+	Available     bool    `bson:"available"` // Derivada: enrolled_count < capacity
+	EnrolledCount int     `bson:"enrolled_count"`
+	Enrollments   []int64 `bson:"enrollments"` // IDs de las inscripciones contadas en enrolled_count
+	Rating        float64 `bson:"rating"`
+	Version       int64   `bson:"version"` // Marca de tiempo (µs) de la última escritura, usada por search-api para descartar eventos viejos
+
+	// Última versión aplicada de cada inscripción del curso (incluidas las
+	// dadas de baja), para descartar los eventos que llegan desordenados
+	EnrollmentVersions []EnrollmentVersion `bson:"enrollment_versions"`
+	// Indica si ya se pidieron a inscriptions-api las inscripciones anteriores
+	// a que se contaran por eventos (ver Service.RequestEnrollments)
+	EnrollmentsSynced bool `bson:"enrollments_synced"`
+}
+
+// EnrollmentVersion es la versión del último evento aplicado de una inscripción
+type EnrollmentVersion struct {
+	InscriptionID int64 `bson:"id"`
+	Version       int64 `bson:"version"`
 }
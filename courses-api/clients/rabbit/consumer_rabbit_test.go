@@ -0,0 +1,101 @@
+package rabbit
+
+import (
+	"context"
+	"courses-api/domain/courses"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeAcknowledger registra cómo se confirmó cada entrega
+type fakeAcknowledger struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (a *fakeAcknowledger) record(result string, tag uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.results = append(a.results, fmt.Sprintf("%s:%d", result, tag))
+	return nil
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	return a.record("ack", tag)
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	return a.record(fmt.Sprintf("nack(requeue=%t)", requeue), tag)
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.record(fmt.Sprintf("reject(requeue=%t)", requeue), tag)
+}
+
+func (a *fakeAcknowledger) String() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return strings.Join(a.results, ",")
+}
+
+func delivery(ack amqp.Acknowledger, tag uint64, body string) amqp.Delivery {
+	return amqp.Delivery{Acknowledger: ack, DeliveryTag: tag, Body: []byte(body)}
+}
+
+func TestConsumerAppliesEventsInOrder(t *testing.T) {
+	ack := &fakeAcknowledger{}
+	deliveries := make(chan amqp.Delivery, 3)
+	deliveries <- delivery(ack, 1, `{"event_id":"a","type":"enrollment.created","inscription_id":7,"course_id":1}`)
+	deliveries <- delivery(ack, 2, `no es json`)
+	deliveries <- delivery(ack, 3, `{"event_id":"b","type":"enrollment.cancelled","inscription_id":7,"course_id":1}`)
+	close(deliveries)
+
+	// El primer evento falla dos veces: el segundo no se aplica antes que él
+	var applied []string
+	failures := 2
+	consumer := NewConsumer(ConsumerConfig{RetryDelay: time.Millisecond})
+	err := consumer.consume(context.Background(), deliveries, func(ctx context.Context, event courses.EnrollmentEvent) error {
+		if failures > 0 {
+			failures--
+			return errors.New("mongo no responde")
+		}
+		applied = append(applied, event.EventID)
+		return nil
+	})
+
+	if !errors.Is(err, amqp.ErrClosed) {
+		t.Errorf("expected the consumer to stop when the channel closes, got %v", err)
+	}
+	if strings.Join(applied, ",") != "a,b" {
+		t.Errorf("expected events a and b in order, got %v", applied)
+	}
+	if got := ack.String(); got != "ack:1,reject(requeue=false):2,ack:3" {
+		t.Errorf("unexpected acknowledgements %s", got)
+	}
+}
+
+func TestConsumerRequeuesPendingEventOnShutdown(t *testing.T) {
+	ack := &fakeAcknowledger{}
+	deliveries := make(chan amqp.Delivery, 1)
+	deliveries <- delivery(ack, 1, `{"event_id":"a","type":"enrollment.created","inscription_id":7,"course_id":1}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := NewConsumer(ConsumerConfig{RetryDelay: time.Hour})
+	err := consumer.consume(ctx, deliveries, func(ctx context.Context, event courses.EnrollmentEvent) error {
+		cancel()
+		return errors.New("mongo no responde")
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the consumer to stop with the context, got %v", err)
+	}
+	if got := ack.String(); got != "nack(requeue=true):1" {
+		t.Errorf("expected the event to go back to the queue, got %s", got)
+	}
+}
@@ -2,59 +2,451 @@ package rabbit
 
 import (
 	"courses-api/domain/courses"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// Valores por defecto del publicador
+const (
+	defaultPoolSize       = 4
+	defaultConfirmTimeout = 5 * time.Second
+	defaultPublishRetries = 3
+	defaultMinBackoff     = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+var (
+	ErrPublisherClosed = errors.New("el publicador de Rabbit está cerrado")
+	ErrNoChannel       = errors.New("no hay canales de Rabbit disponibles")
+	ErrNack            = errors.New("RabbitMQ rechazó el mensaje (nack)")
+	ErrConfirmTimeout  = errors.New("tiempo de espera agotado esperando la confirmación de RabbitMQ")
+)
+
 type RabbitConfig struct {
-	URI       string
-	QueueName string
+	URI            string
+	QueueName      string
+	PoolSize       int           // Cantidad de canales abiertos en modo confirmación
+	ConfirmTimeout time.Duration // Tiempo máximo para esperar un canal libre o el ack del broker
+	PublishRetries int           // Intentos de publicación ante errores de canal o conexión
+	MinBackoff     time.Duration // Espera inicial entre reconexiones
+	MaxBackoff     time.Duration // Espera máxima entre reconexiones
+}
+
+// Connection abstrae *amqp.Connection para poder reemplazarla en los tests
+type Connection interface {
+	Channel() (Channel, error)
+	NotifyClose(receiver chan *amqp.Error) chan *amqp.Error
+	Close() error
+}
+
+// Channel abstrae *amqp.Channel con las operaciones que usa el publicador
+type Channel interface {
+	Confirm(noWait bool) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	Close() error
+}
+
+// Dialer abre una nueva conexión contra el broker
+type Dialer func(uri string) (Connection, error)
+
+type amqpConnection struct {
+	*amqp.Connection
+}
+
+func (c amqpConnection) Channel() (Channel, error) {
+	return c.Connection.Channel()
 }
 
+func dialAMQP(uri string) (Connection, error) {
+	connection, err := amqp.Dial(uri)
+	if err != nil {
+		return nil, err
+	}
+	return amqpConnection{connection}, nil
+}
+
+// pooledChannel es un canal en modo confirmación junto con su flujo de acks
+type pooledChannel struct {
+	channel    Channel
+	confirms   chan amqp.Confirmation
+	generation uint64
+}
+
+// Rabbit publica eventos de cursos con confirmaciones del broker. Mantiene un
+// pool de canales (amqp.Channel no es seguro entre goroutines) y se reconecta
+// con backoff exponencial si se pierde la conexión.
 type Rabbit struct {
-	Connection *amqp.Connection
-	Channel    *amqp.Channel
-	Queue      amqp.Queue
+	config RabbitConfig
+	dial   Dialer
+
+	mu         sync.Mutex
+	connection Connection
+	generation uint64
+	closed     bool
+
+	pool chan *pooledChannel
+	done chan struct{}
+}
+
+// NewRabbit conecta con RabbitMQ, declara la cola durable y prepara el pool de canales
+func NewRabbit(config RabbitConfig) (*Rabbit, error) {
+	return newRabbit(config, dialAMQP)
 }
 
-func NewRabbit(config RabbitConfig) Rabbit {
-	connection, err := amqp.Dial(config.URI)
+func newRabbit(config RabbitConfig, dial Dialer) (*Rabbit, error) {
+	if config.PoolSize <= 0 {
+		config.PoolSize = defaultPoolSize
+	}
+	if config.ConfirmTimeout <= 0 {
+		config.ConfirmTimeout = defaultConfirmTimeout
+	}
+	if config.PublishRetries <= 0 {
+		config.PublishRetries = defaultPublishRetries
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+
+	rabbit := &Rabbit{
+		config: config,
+		dial:   dial,
+		pool:   make(chan *pooledChannel, config.PoolSize),
+		done:   make(chan struct{}),
+	}
+	if err := rabbit.connect(); err != nil {
+		return nil, err
+	}
+	return rabbit, nil
+}
+
+// connect abre la conexión, declara la cola y llena el pool con una nueva generación de canales
+func (r *Rabbit) connect() error {
+	connection, err := r.dial(r.config.URI)
 	if err != nil {
-		log.Fatalf("error getting Rabbit connection: %v", err)
+		return fmt.Errorf("error getting Rabbit connection: %w", err)
 	}
+
 	channel, err := connection.Channel()
 	if err != nil {
-		log.Fatalf("error creating Rabbit channel: %v", err)
+		connection.Close()
+		return fmt.Errorf("error creating Rabbit channel: %w", err)
+	}
+	if _, err := channel.QueueDeclare(r.config.QueueName, true, false, false, false, nil); err != nil {
+		connection.Close()
+		return fmt.Errorf("error declaring Rabbit queue: %w", err)
+	}
+	channel.Close()
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		connection.Close()
+		return ErrPublisherClosed
+	}
+	r.connection = connection
+	r.generation++
+	generation := r.generation
+	r.mu.Unlock()
+
+	// Los canales de la conexión anterior que quedaron en el pool ya no sirven y
+	// ocuparían el lugar de los nuevos
+	r.drain(generation)
+	for i := 0; i < r.config.PoolSize; i++ {
+		r.openChannel(generation)
 	}
-	queue, err := channel.QueueDeclare(config.QueueName, false, false, false, false, nil)
+
+	go r.watch(connection.NotifyClose(make(chan *amqp.Error, 1)), generation)
+	return nil
+}
+
+// openChannel agrega un canal al pool si la conexión de esa generación sigue vigente
+func (r *Rabbit) openChannel(generation uint64) {
+	r.mu.Lock()
+	connection := r.connection
+	current := r.generation
+	r.mu.Unlock()
+	if generation != current || connection == nil {
+		return
+	}
+
+	channel, err := connection.Channel()
 	if err != nil {
-		log.Fatalf("error declaring Rabbit queue: %v", err)
+		log.Printf("Error al abrir un canal de Rabbit: %v", err)
+		return
+	}
+	if err := channel.Confirm(false); err != nil {
+		log.Printf("Error al activar confirmaciones en el canal de Rabbit: %v", err)
+		channel.Close()
+		return
+	}
+
+	pooled := &pooledChannel{
+		channel:    channel,
+		confirms:   channel.NotifyPublish(make(chan amqp.Confirmation, 1)),
+		generation: generation,
+	}
+	select {
+	case r.pool <- pooled:
+	default:
+		channel.Close()
+	}
+}
+
+// drain cierra los canales del pool que no son de la generación indicada
+func (r *Rabbit) drain(generation uint64) {
+	for i := 0; i < cap(r.pool); i++ {
+		select {
+		case pooled := <-r.pool:
+			if pooled.generation == generation {
+				r.release(pooled, false)
+				continue
+			}
+			pooled.channel.Close()
+		default:
+			return
+		}
+	}
+}
+
+// watch espera el cierre de la conexión y dispara la reconexión
+func (r *Rabbit) watch(notify chan *amqp.Error, generation uint64) {
+	select {
+	case err := <-notify:
+		if err != nil {
+			log.Printf("Conexión con RabbitMQ perdida: %v", err)
+		}
+	case <-r.done:
+		return
+	}
+
+	r.mu.Lock()
+	stale := r.generation == generation
+	if stale {
+		r.connection = nil
+	}
+	r.mu.Unlock()
+	if stale {
+		r.reconnect()
+	}
+}
+
+// reconnect reintenta la conexión con backoff exponencial hasta lograrlo o hasta que se cierre el publicador
+func (r *Rabbit) reconnect() {
+	delay := r.config.MinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-r.done:
+			return
+		case <-time.After(delay):
+		}
+
+		err := r.connect()
+		if err == nil {
+			log.Printf("Reconectado a RabbitMQ tras %d intentos", attempt)
+			return
+		}
+		if errors.Is(err, ErrPublisherClosed) {
+			return
+		}
+		log.Printf("Intento %d de reconexión a RabbitMQ fallido: %v", attempt, err)
+
+		delay *= 2
+		if delay > r.config.MaxBackoff {
+			delay = r.config.MaxBackoff
+		}
 	}
-	return Rabbit{
-		Connection: connection,
-		Channel:    channel,
-		Queue:      queue,
+}
+
+// acquire toma un canal vigente del pool. Los de conexiones anteriores se
+// descartan y se reemplazan por canales de la conexión actual.
+func (r *Rabbit) acquire() (*pooledChannel, error) {
+	timeout := time.NewTimer(r.config.ConfirmTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case pooled := <-r.pool:
+			r.mu.Lock()
+			current := r.generation
+			r.mu.Unlock()
+			if pooled.generation != current {
+				pooled.channel.Close()
+				go r.openChannel(current)
+				continue
+			}
+			return pooled, nil
+		case <-r.done:
+			return nil, ErrPublisherClosed
+		case <-timeout.C:
+			return nil, ErrNoChannel
+		}
+	}
+}
+
+// release devuelve el canal al pool o, si quedó inutilizable, lo reemplaza por uno nuevo
+func (r *Rabbit) release(pooled *pooledChannel, broken bool) {
+	if broken {
+		pooled.channel.Close()
+		go r.openChannel(pooled.generation)
+		return
+	}
+	select {
+	case r.pool <- pooled:
+	default:
+		pooled.channel.Close()
 	}
 }
 
-func (r Rabbit) Publish(cursoNew courses.CursosNew) error {
+// Publish envía el evento como mensaje persistente y espera la confirmación del broker.
+// Cada evento recibe un ID único que se conserva en los reintentos, para que el
+// consumidor pueda descartar duplicados.
+func (r *Rabbit) Publish(cursoNew courses.CursosNew) error {
+	if cursoNew.EventID == "" {
+		eventID, err := newEventID()
+		if err != nil {
+			return fmt.Errorf("error al generar el ID del evento: %w", err)
+		}
+		cursoNew.EventID = eventID
+	}
+
 	bytes, err := json.Marshal(cursoNew)
 	if err != nil {
 		return fmt.Errorf("error al serializar CursosNew: %w", err)
 	}
-	if err := r.Channel.Publish(
-		"",
-		r.Queue.Name,
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        bytes,
-		}); err != nil {
-		return fmt.Errorf("error al publicar en Rabbit: %w", err)
+
+	return r.publish(amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    cursoNew.EventID,
+		Timestamp:    time.Now().UTC(),
+		Body:         bytes,
+	})
+}
+
+// PublishCourseEvent envía un evento de curso para inscriptions-api, con las
+// mismas garantías que Publish
+func (r *Rabbit) PublishCourseEvent(event courses.CourseEvent) error {
+	if event.EventID == "" {
+		eventID, err := newEventID()
+		if err != nil {
+			return fmt.Errorf("error al generar el ID del evento: %w", err)
+		}
+		event.EventID = eventID
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	bytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error al serializar el evento %s: %w", event.Type, err)
+	}
+
+	return r.publish(amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    event.EventID,
+		Type:         event.Type,
+		Timestamp:    event.OccurredAt,
+		Body:         bytes,
+	})
+}
+
+// publish reintenta la publicación del mensaje ante errores de canal o conexión
+func (r *Rabbit) publish(message amqp.Publishing) error {
+	var lastErr error
+	for attempt := 1; attempt <= r.config.PublishRetries; attempt++ {
+		lastErr = r.publishOnce(message)
+		if lastErr == nil {
+			return nil
+		}
+		if errors.Is(lastErr, ErrPublisherClosed) {
+			break
+		}
+		log.Printf("Intento %d de publicación en Rabbit fallido: %v", attempt, lastErr)
+	}
+	return fmt.Errorf("error al publicar en Rabbit: %w", lastErr)
+}
+
+func (r *Rabbit) publishOnce(message amqp.Publishing) error {
+	pooled, err := r.acquire()
+	if err != nil {
+		return err
+	}
+
+	if err := pooled.channel.Publish("", r.config.QueueName, false, false, message); err != nil {
+		r.release(pooled, true)
+		return err
+	}
+
+	timeout := time.NewTimer(r.config.ConfirmTimeout)
+	defer timeout.Stop()
+
+	select {
+	case confirmation, ok := <-pooled.confirms:
+		if !ok {
+			// El canal se cerró antes de recibir la confirmación
+			r.release(pooled, true)
+			return amqp.ErrClosed
+		}
+		r.release(pooled, false)
+		if !confirmation.Ack {
+			return ErrNack
+		}
+		return nil
+	case <-timeout.C:
+		// Una confirmación tardía desincronizaría el canal, así que se descarta
+		r.release(pooled, true)
+		return ErrConfirmTimeout
+	}
+}
+
+// newEventID genera un identificador aleatorio de 128 bits en hexadecimal
+func newEventID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Close detiene las reconexiones y cierra los canales y la conexión
+func (r *Rabbit) Close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	connection := r.connection
+	r.connection = nil
+	r.mu.Unlock()
+
+	close(r.done)
+	for {
+		select {
+		case pooled := <-r.pool:
+			pooled.channel.Close()
+		default:
+			if connection != nil {
+				if err := connection.Close(); err != nil {
+					log.Printf("Error al cerrar la conexión de Rabbit: %v", err)
+				}
+			}
+			return
+		}
 	}
-	return nil
 }
@@ -0,0 +1,321 @@
+package rabbit
+
+import (
+	"courses-api/domain/courses"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// fakeBroker simula RabbitMQ en memoria: registra declaraciones y mensajes y
+// permite cortar la conexión activa para probar la reconexión.
+type fakeBroker struct {
+	mu        sync.Mutex
+	dials     int
+	durable   map[string]bool
+	published []amqp.Publishing
+	nack      bool
+	current   *fakeConnection
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{durable: map[string]bool{}}
+}
+
+func (b *fakeBroker) dial(uri string) (Connection, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dials++
+	b.current = &fakeConnection{broker: b}
+	return b.current, nil
+}
+
+// drop cierra la conexión actual como lo haría un reinicio del broker
+func (b *fakeBroker) drop() {
+	b.mu.Lock()
+	connection := b.current
+	b.mu.Unlock()
+	connection.kill(&amqp.Error{Code: amqp.ConnectionForced, Reason: "broker restart"})
+}
+
+func (b *fakeBroker) messages() []amqp.Publishing {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]amqp.Publishing(nil), b.published...)
+}
+
+func (b *fakeBroker) dialCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dials
+}
+
+type fakeConnection struct {
+	broker    *fakeBroker
+	mu        sync.Mutex
+	closed    bool
+	listeners []chan *amqp.Error
+}
+
+func (c *fakeConnection) Channel() (Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil, amqp.ErrClosed
+	}
+	return &fakeChannel{connection: c}, nil
+}
+
+func (c *fakeConnection) NotifyClose(receiver chan *amqp.Error) chan *amqp.Error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		close(receiver)
+		return receiver
+	}
+	c.listeners = append(c.listeners, receiver)
+	return receiver
+}
+
+func (c *fakeConnection) Close() error {
+	c.kill(nil)
+	return nil
+}
+
+func (c *fakeConnection) kill(reason *amqp.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for _, listener := range c.listeners {
+		if reason != nil {
+			listener <- reason
+		}
+		close(listener)
+	}
+}
+
+func (c *fakeConnection) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+type fakeChannel struct {
+	connection *fakeConnection
+	confirm    bool
+	confirms   chan amqp.Confirmation
+	tag        uint64
+	inUse      int32
+}
+
+func (ch *fakeChannel) Confirm(noWait bool) error {
+	ch.confirm = true
+	return nil
+}
+
+func (ch *fakeChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	ch.connection.broker.mu.Lock()
+	defer ch.connection.broker.mu.Unlock()
+	ch.connection.broker.durable[name] = durable
+	return amqp.Queue{Name: name}, nil
+}
+
+func (ch *fakeChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if !atomic.CompareAndSwapInt32(&ch.inUse, 0, 1) {
+		panic("canal usado por más de una goroutine a la vez")
+	}
+	defer atomic.StoreInt32(&ch.inUse, 0)
+
+	if ch.connection.isClosed() {
+		return amqp.ErrClosed
+	}
+
+	broker := ch.connection.broker
+	broker.mu.Lock()
+	ack := !broker.nack
+	if ack {
+		broker.published = append(broker.published, msg)
+	}
+	broker.mu.Unlock()
+
+	ch.tag++
+	if ch.confirm {
+		ch.confirms <- amqp.Confirmation{DeliveryTag: ch.tag, Ack: ack}
+	}
+	return nil
+}
+
+func (ch *fakeChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	ch.confirms = confirm
+	return confirm
+}
+
+func (ch *fakeChannel) Close() error {
+	return nil
+}
+
+func newTestRabbit(t *testing.T, broker *fakeBroker) *Rabbit {
+	t.Helper()
+	rabbit, err := newRabbit(RabbitConfig{
+		URI:            "amqp://fake",
+		QueueName:      "courses_queue",
+		PoolSize:       2,
+		ConfirmTimeout: 200 * time.Millisecond,
+		PublishRetries: 10,
+		MinBackoff:     10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}, broker.dial)
+	if err != nil {
+		t.Fatalf("error creando el publicador: %v", err)
+	}
+	t.Cleanup(rabbit.Close)
+	return rabbit
+}
+
+func TestPublishPersistentMessageWithConfirm(t *testing.T) {
+	broker := newFakeBroker()
+	rabbit := newTestRabbit(t, broker)
+
+	if err := rabbit.Publish(courses.CursosNew{Operation: "POST", ID: 7}); err != nil {
+		t.Fatalf("error inesperado al publicar: %v", err)
+	}
+
+	if !broker.durable["courses_queue"] {
+		t.Errorf("la cola courses_queue debería declararse durable")
+	}
+	messages := broker.messages()
+	if len(messages) != 1 {
+		t.Fatalf("se esperaba 1 mensaje publicado, hay %d", len(messages))
+	}
+	if messages[0].DeliveryMode != amqp.Persistent {
+		t.Errorf("el mensaje debería ser persistente, DeliveryMode=%d", messages[0].DeliveryMode)
+	}
+	var event courses.CursosNew
+	if err := json.Unmarshal(messages[0].Body, &event); err != nil {
+		t.Fatalf("cuerpo inválido: %v", err)
+	}
+	if event.Operation != "POST" || event.ID != 7 {
+		t.Errorf("evento inesperado: %+v", event)
+	}
+	if event.EventID == "" || messages[0].MessageId != event.EventID {
+		t.Errorf("el evento debería llevar un ID único también como MessageId: %q / %q", event.EventID, messages[0].MessageId)
+	}
+}
+
+func TestPublishCourseEvent(t *testing.T) {
+	broker := newFakeBroker()
+	rabbit := newTestRabbit(t, broker)
+
+	err := rabbit.PublishCourseEvent(courses.CourseEvent{Type: courses.EventCapacityIncreased, CourseID: 3, Capacity: 40})
+	if err != nil {
+		t.Fatalf("error inesperado al publicar: %v", err)
+	}
+
+	messages := broker.messages()
+	if len(messages) != 1 {
+		t.Fatalf("se esperaba 1 mensaje publicado, hay %d", len(messages))
+	}
+	var event courses.CourseEvent
+	if err := json.Unmarshal(messages[0].Body, &event); err != nil {
+		t.Fatalf("cuerpo inválido: %v", err)
+	}
+	if event.Type != courses.EventCapacityIncreased || event.CourseID != 3 || event.Capacity != 40 {
+		t.Errorf("evento inesperado: %+v", event)
+	}
+	if messages[0].Type != event.Type || messages[0].MessageId != event.EventID || event.EventID == "" {
+		t.Errorf("el mensaje debería llevar el tipo y el ID del evento: %q / %q", messages[0].Type, messages[0].MessageId)
+	}
+}
+
+func TestPublishReturnsErrorOnNack(t *testing.T) {
+	broker := newFakeBroker()
+	broker.nack = true
+	rabbit := newTestRabbit(t, broker)
+
+	err := rabbit.Publish(courses.CursosNew{Operation: "UPDATE", ID: 1})
+	if !errors.Is(err, ErrNack) {
+		t.Fatalf("se esperaba ErrNack, se obtuvo %v", err)
+	}
+}
+
+func TestPublishAfterBrokerRestart(t *testing.T) {
+	broker := newFakeBroker()
+	rabbit := newTestRabbit(t, broker)
+
+	if err := rabbit.Publish(courses.CursosNew{Operation: "POST", ID: 1}); err != nil {
+		t.Fatalf("error inesperado al publicar: %v", err)
+	}
+
+	broker.drop()
+
+	if err := rabbit.Publish(courses.CursosNew{Operation: "UPDATE", ID: 1}); err != nil {
+		t.Fatalf("el publicador debería reconectarse, error: %v", err)
+	}
+	if broker.dialCount() < 2 {
+		t.Errorf("se esperaba una reconexión, conexiones abiertas: %d", broker.dialCount())
+	}
+	if len(broker.messages()) != 2 {
+		t.Errorf("se esperaban 2 mensajes publicados, hay %d", len(broker.messages()))
+	}
+}
+
+func TestConcurrentPublishUsesPooledChannels(t *testing.T) {
+	broker := newFakeBroker()
+	rabbit := newTestRabbit(t, broker)
+
+	const publishers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, publishers)
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			errs <- rabbit.Publish(courses.CursosNew{Operation: "UPDATE", ID: id})
+		}(int64(i))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("error inesperado al publicar: %v", err)
+		}
+	}
+	if len(broker.messages()) != publishers {
+		t.Errorf("se esperaban %d mensajes, hay %d", publishers, len(broker.messages()))
+	}
+}
+
+func TestPublishAfterIdleReconnect(t *testing.T) {
+	broker := newFakeBroker()
+	rabbit := newTestRabbit(t, broker)
+
+	// El broker se reinicia sin publicaciones en curso: el pool sigue lleno de
+	// canales de la conexión anterior cuando se completa la reconexión
+	broker.drop()
+	deadline := time.Now().Add(time.Second)
+	for broker.dialCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("el publicador no se reconectó")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	for i := int64(1); i <= 3; i++ {
+		if err := rabbit.Publish(courses.CursosNew{Operation: "UPDATE", ID: i}); err != nil {
+			t.Fatalf("error al publicar tras la reconexión: %v", err)
+		}
+	}
+	if len(broker.messages()) != 3 {
+		t.Errorf("se esperaban 3 mensajes publicados, hay %d", len(broker.messages()))
+	}
+}
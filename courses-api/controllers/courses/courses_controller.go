@@ -3,8 +3,10 @@ package courses
 import (
 	"context"
 	"courses-api/domain/courses"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +15,8 @@ import (
 type Service interface {
 	CreateCourse(ctx context.Context, req courses.CreateCourseRequest) (courses.CourseResponse, error)
 	GetCourses(ctx context.Context) ([]courses.CourseResponse, error)
+	GetCoursesPage(ctx context.Context, offset int64, limit int64) ([]courses.CourseResponse, error)
+	GetCoursesByIDs(ctx context.Context, ids []int64) ([]courses.CourseResponse, error)
 	GetCourseByID(ctx context.Context, id int64) (courses.CourseResponse, error)
 	UpdateCourse(ctx context.Context, id int64, req courses.UpdateCourseRequest) (courses.CourseResponse, error)
 	DeleteCourse(ctx context.Context, id int64) error
@@ -47,8 +51,21 @@ func (ctrl Controller) CreateCourse(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, course)
 }
 
-// Obtener todos los cursos
+// maxPageSize es el límite máximo de cursos por página
+const maxPageSize = 500
+
+// Obtener todos los cursos, los de "ids" (separados por comas), o una página
+// ordenada por ID si se indica "limit" (y opcionalmente "offset")
 func (ctrl Controller) GetCourses(ctx *gin.Context) {
+	if ctx.Query("ids") != "" {
+		ctrl.getCoursesByIDs(ctx)
+		return
+	}
+	if ctx.Query("limit") != "" {
+		ctrl.getCoursesPage(ctx)
+		return
+	}
+
 	courses, err := ctrl.service.GetCourses(ctx.Request.Context())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al listar cursos: " + err.Error()})
@@ -57,6 +74,50 @@ func (ctrl Controller) GetCourses(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, courses)
 }
 
+func (ctrl Controller) getCoursesPage(ctx *gin.Context) {
+	limit, err := strconv.ParseInt(ctx.Query("limit"), 10, 64)
+	if err != nil || limit <= 0 || limit > maxPageSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit inválido (1 a 500)"})
+		return
+	}
+	offset, err := strconv.ParseInt(ctx.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "offset inválido"})
+		return
+	}
+
+	courses, err := ctrl.service.GetCoursesPage(ctx.Request.Context(), offset, limit)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al listar cursos: " + err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, courses)
+}
+
+// getCoursesByIDs devuelve los cursos pedidos en el orden de "ids", sin los que no existen
+func (ctrl Controller) getCoursesByIDs(ctx *gin.Context) {
+	var ids []int64
+	for _, param := range strings.Split(ctx.Query("ids"), ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "ID inválido: " + param})
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > maxPageSize {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Se pueden pedir hasta 500 cursos a la vez"})
+		return
+	}
+
+	courses, err := ctrl.service.GetCoursesByIDs(ctx.Request.Context(), ids)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al listar cursos: " + err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, courses)
+}
+
 // Obtener curso por ID
 func (ctrl Controller) GetCourseByID(ctx *gin.Context) {
 	courseID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
@@ -65,6 +126,10 @@ func (ctrl Controller) GetCourseByID(ctx *gin.Context) {
 		return
 	}
 	course, err := ctrl.service.GetCourseByID(ctx.Request.Context(), courseID)
+	if errors.Is(err, courses.ErrCourseNotFound) {
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Error al obtener curso: " + err.Error()})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener curso: " + err.Error()})
 		return
@@ -1,5 +1,13 @@
 package courses
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrCourseNotFound indica que no existe un curso con ese ID
+var ErrCourseNotFound = errors.New("curso no encontrado")
+
 type CreateCourseRequest struct {
 	Name         string `json:"name" binding:"required"`
 	Description  string `json:"description" binding:"required"`
@@ -24,18 +32,68 @@ type UpdateCourseRequest struct {
 }
 
 type CourseResponse struct {
-	ID           int64   `json:"id"`
-	Name         string  `json:"name"`
-	Description  string  `json:"description"`
-	Category     string  `json:"category"`
-	Duration     string  `json:"duration"`
-	InstructorID int64   `json:"instructor_id"`
-	Capacity     int     `json:"capacity"`
-	Available    bool    `json:"available"`
-	Rating       float64 `json:"rating"`
-	ImageBase64  string  `json:"imageBase64,omitempty"`
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	Description   string  `json:"description"`
+	Category      string  `json:"category"`
+	Duration      string  `json:"duration"`
+	InstructorID  int64   `json:"instructor_id"`
+	Capacity      int     `json:"capacity"`
+	Available     bool    `json:"available"`
+	EnrolledCount int     `json:"enrolled_count"` // Inscripciones que ocupan un lugar, según los eventos de inscriptions-api
+	Rating        float64 `json:"rating"`
+	ImageBase64   string  `json:"imageBase64,omitempty"`
+	Version       int64   `json:"version"`
 }
 type CursosNew struct {
+	EventID   string `json:"event_id"` // Identificador único del evento, lo asigna el publicador
 	Operation string `json:"operation"`
 	ID        int64  `json:"id"`
+	Version   int64  `json:"version"` // Versión del curso a la que corresponde el evento
+}
+
+// Tipos de los eventos de inscriptions-api que cambian el cupo de un curso.
+// enrollment.snapshot es el estado actual de una inscripción, que
+// inscriptions-api publica cuando se le piden las inscripciones de un curso.
+const (
+	EventEnrollmentCreated   = "enrollment.created"
+	EventEnrollmentCancelled = "enrollment.cancelled"
+	EventEnrollmentSnapshot  = "enrollment.snapshot"
+)
+
+// Estados de una inscripción que ocupan un lugar y courses-api cuenta como inscriptos
+const (
+	InscriptionStatusActive    = "active"
+	InscriptionStatusCompleted = "completed"
+)
+
+// EnrollmentEvent es un evento que inscriptions-api publica en la cola
+// inscriptions_events cuando cambia una inscripción
+type EnrollmentEvent struct {
+	EventID       string    `json:"event_id"`
+	Type          string    `json:"type"`
+	InscriptionID int64     `json:"inscription_id"`
+	UserID        int64     `json:"user_id"`
+	CourseID      int64     `json:"course_id"`
+	Version       int64     `json:"version"`          // Versión de la inscripción después del cambio (0 en eventos anteriores a las versiones)
+	Status        string    `json:"status,omitempty"` // Estado de la inscripción, en enrollment.snapshot
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Tipos de los eventos de cursos que consume inscriptions-api.
+// course.capacity_increased le avisa que hay lugares nuevos para la lista de
+// espera; course.enrollments_requested le pide las inscripciones del curso
+// como eventos enrollment.snapshot.
+const (
+	EventCapacityIncreased    = "course.capacity_increased"
+	EventEnrollmentsRequested = "course.enrollments_requested"
+)
+
+// CourseEvent es un evento que courses-api publica en la cola courses_events
+type CourseEvent struct {
+	EventID    string    `json:"event_id"` // Identificador único del evento, lo asigna el publicador
+	Type       string    `json:"type"`
+	CourseID   int64     `json:"course_id"`
+	Capacity   int       `json:"capacity,omitempty"` // Capacidad nueva, en course.capacity_increased
+	OccurredAt time.Time `json:"occurred_at"`
 }
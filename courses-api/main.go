@@ -6,7 +6,6 @@ import (
 	"os"
 	"time"
 
-	"courses-api/clients"
 	"courses-api/clients/rabbit"
 	commentsController "courses-api/controllers/comments"
 	coursesController "courses-api/controllers/courses"
@@ -20,7 +19,6 @@ import (
 	filesServices "courses-api/services/files"
 
 	"github.com/gin-contrib/cors"
-	"github.com/streadway/amqp"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -82,41 +80,37 @@ func main() {
 		QueueName: "courses_queue",
 	}
 
-	// Conectar a RabbitMQ con reintento
-	var rabbitConn *amqp.Connection
+	// Conectar a RabbitMQ con reintento. El publicador se reconecta solo si luego se pierde la conexión
+	var rabbitQueue *rabbit.Rabbit
 	err = connectWithRetry(func() error {
 		var err error
-		rabbitConn, err = amqp.Dial(rabbitConfig.URI)
+		rabbitQueue, err = rabbit.NewRabbit(rabbitConfig)
 		return err
 	})
 	if err != nil {
 		log.Fatalf("Failed to connect to RabbitMQ after retries: %v", err)
 	}
-	defer rabbitConn.Close()
+	defer rabbitQueue.Close()
 
-	rabbitChannel, err := rabbitConn.Channel()
-	if err != nil {
-		log.Fatalf("Failed to open a channel: %v", err)
+	// Los eventos de cursos para inscriptions-api (aumentos de capacidad y
+	// pedidos de inscripciones) van en otra cola, que consume esa API
+	courseEventsQueueName := os.Getenv("COURSES_EVENTS_QUEUE")
+	if courseEventsQueueName == "" {
+		courseEventsQueueName = "courses_events"
 	}
-	defer rabbitChannel.Close()
-
-	queue, err := rabbitChannel.QueueDeclare(
-		rabbitConfig.QueueName,
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+	var courseEventsQueue *rabbit.Rabbit
+	err = connectWithRetry(func() error {
+		var err error
+		courseEventsQueue, err = rabbit.NewRabbit(rabbit.RabbitConfig{
+			URI:       rabbitURI,
+			QueueName: courseEventsQueueName,
+		})
+		return err
+	})
 	if err != nil {
-		log.Fatalf("Failed to declare a queue: %v", err)
-	}
-
-	rabbitQueue := rabbit.Rabbit{
-		Connection: rabbitConn,
-		Channel:    rabbitChannel,
-		Queue:      queue,
+		log.Fatalf("Failed to connect to RabbitMQ after retries: %v", err)
 	}
+	defer courseEventsQueue.Close()
 
 	// Crear instancias del repositorio
 	courseRepo := coursesRepositories.NewMongo(coursesRepositories.MongoConfig{
@@ -130,22 +124,44 @@ func main() {
 	commentRepo := commentsRepositories.NewCommentsMongo(client, "courses-api", "comments")
 	fileRepo := filesRepositories.NewMongo(client, "courses-api", "files")
 
-	// Crear el cliente HTTP para la API de inscripciones
-	inscriptionsAPIURL := os.Getenv("INSCRIPTIONS_API_URL")
-	if inscriptionsAPIURL == "" {
-		inscriptionsAPIURL = "http://nginx:8085"
+	// Leer la clave JWT desde la variable de entorno
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		jwtSecret = "ThisIsAnExampleJWTKey!"
 	}
-	httpClient := clients.NewHTTPClient(inscriptionsAPIURL)
 
 	// Crear el servicio de cursos
 	courseService := coursesServices.NewService(
 		courseRepo,
 		commentRepo,
 		fileRepo,
-		&rabbitQueue,
-		httpClient,
+		rabbitQueue,
+		courseEventsQueue,
 	)
 
+	// Consumir los eventos de inscriptions-api, con los que se cuentan los
+	// inscriptos de cada curso y se deriva su disponibilidad
+	eventsQueueName := os.Getenv("INSCRIPTIONS_EVENTS_QUEUE")
+	if eventsQueueName == "" {
+		eventsQueueName = "inscriptions_events"
+	}
+	enrollmentsConsumer := rabbit.NewConsumer(rabbit.ConsumerConfig{
+		URI:       rabbitURI,
+		QueueName: eventsQueueName,
+	})
+	go enrollmentsConsumer.Run(context.Background(), courseService.ApplyEnrollmentEvent)
+
+	// Pedir a inscriptions-api las inscripciones de los cursos anteriores a los
+	// eventos; llegan como eventos por la cola que consume enrollmentsConsumer
+	go func() {
+		err := connectWithRetry(func() error {
+			return courseService.RequestEnrollments(context.Background())
+		})
+		if err != nil {
+			log.Printf("No se pudieron pedir las inscripciones de los cursos: %v", err)
+		}
+	}()
+
 	// Crear el controlador de cursos
 	courseController := coursesController.NewController(courseService)
 
@@ -157,12 +173,6 @@ func main() {
 	fileService := filesServices.NewService(fileRepo, courseRepo)
 	fileController := filesController.NewController(fileService)
 
-	// Leer la clave JWT desde la variable de entorno
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "ThisIsAnExampleJWTKey!"
-	}
-
 	// Configurar las rutas
 	router := coursesRouter.SetupRouter(courseController, commentController, fileController, jwtSecret)
 
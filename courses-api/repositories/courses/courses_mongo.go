@@ -3,6 +3,8 @@ package repositories
 import (
 	"context"
 	coursesDAO "courses-api/DAO/courses"
+	"courses-api/domain/courses"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -94,6 +96,11 @@ func getNextID() int64 {
 func (m Mongo) CreateCourse(ctx context.Context, course coursesDAO.Course) (coursesDAO.Course, error) {
 	course.ID = getNextID()
 	course.Rating = 0 // Inicializar el rating en 0
+	course.Version = time.Now().UnixMicro()
+	course.EnrolledCount = 0
+	course.Enrollments = []int64{}
+	course.EnrollmentVersions = []coursesDAO.EnrollmentVersion{}
+	course.EnrollmentsSynced = true // Un curso nuevo no tiene inscripciones anteriores
 
 	collection := m.client.Database(m.database).Collection(m.collection)
 	_, err := collection.InsertOne(ctx, course)
@@ -116,25 +123,194 @@ func (m Mongo) GetCourses(ctx context.Context) ([]coursesDAO.Course, error) {
 	return courses, nil
 }
 
+// GetCoursesPage devuelve una página de cursos ordenados por ID
+func (m Mongo) GetCoursesPage(ctx context.Context, offset int64, limit int64) ([]coursesDAO.Course, error) {
+	var courses []coursesDAO.Course
+	collection := m.client.Database(m.database).Collection(m.collection)
+	findOptions := options.Find().SetSort(bson.D{{Key: "id", Value: 1}}).SetSkip(offset).SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find courses: %v", err)
+	}
+	if err := cursor.All(ctx, &courses); err != nil {
+		return nil, fmt.Errorf("failed to decode courses: %v", err)
+	}
+	return courses, nil
+}
+
+// GetCoursesByIDs devuelve los cursos con esos IDs en una sola consulta; los
+// que no existen no se incluyen
+func (m Mongo) GetCoursesByIDs(ctx context.Context, ids []int64) ([]coursesDAO.Course, error) {
+	var courses []coursesDAO.Course
+	collection := m.client.Database(m.database).Collection(m.collection)
+	cursor, err := collection.Find(ctx, bson.M{"id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find courses: %v", err)
+	}
+	if err := cursor.All(ctx, &courses); err != nil {
+		return nil, fmt.Errorf("failed to decode courses: %v", err)
+	}
+	return courses, nil
+}
+
 func (m Mongo) GetCourseByID(ctx context.Context, id int64) (coursesDAO.Course, error) {
 	var course coursesDAO.Course
 	collection := m.client.Database(m.database).Collection(m.collection)
 	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&course)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return coursesDAO.Course{}, courses.ErrCourseNotFound
+	}
 	if err != nil {
 		return coursesDAO.Course{}, fmt.Errorf("failed to find course: %v", err)
 	}
 	return course, nil
 }
 
+// UpdateCourse guarda los datos editables del curso y devuelve cómo quedó. Las
+// inscripciones no se tocan, porque las actualizan los eventos mientras tanto,
+// y la disponibilidad se recalcula con ellas y la capacidad nueva.
 func (m Mongo) UpdateCourse(ctx context.Context, course coursesDAO.Course) (coursesDAO.Course, error) {
 	collection := m.client.Database(m.database).Collection(m.collection)
 	filter := bson.M{"id": course.ID}
-	update := bson.M{"$set": course}
-	_, err := collection.UpdateOne(ctx, filter, update)
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"name":          course.Name,
+			"description":   course.Description,
+			"category":      course.Category,
+			"duration":      course.Duration,
+			"instructor_id": course.InstructorID,
+			"image_base64":  course.ImageBase64,
+			"capacity":      course.Capacity,
+			"rating":        course.Rating,
+			"version":       time.Now().UnixMicro(),
+		}}},
+		{{Key: "$set", Value: bson.M{"available": availableExpr}}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated coursesDAO.Course
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return coursesDAO.Course{}, courses.ErrCourseNotFound
+	}
 	if err != nil {
 		return coursesDAO.Course{}, fmt.Errorf("failed to update course: %v", err)
 	}
-	return course, nil
+	return updated, nil
+}
+
+// availableExpr calcula la disponibilidad con los inscriptos y la capacidad
+var availableExpr = bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$enrolled_count", 0}}, "$capacity"}}
+
+// ApplyEnrollment suma (enrolled) o resta una inscripción del cupo del curso y
+// devuelve cómo quedó, y si cambió su disponibilidad. Los eventos pueden
+// llegar desordenados, así que se guarda la última versión aplicada de cada
+// inscripción y se descartan los de una versión igual (repetidos) o anterior:
+// una baja que llega después de la reinscripción no la borra. Los eventos sin
+// versión (versión 0, de antes de que inscriptions-api las publicara) solo se
+// aplican si todavía no se aplicó ninguno versionado de esa inscripción.
+//
+// Todo se hace en una sola actualización, sin leer antes, así que los eventos
+// simultáneos no se pisan entre sí ni con UpdateCourse. La versión del curso
+// cambia solo si cambia la disponibilidad, que es lo que search-api indexa.
+func (m Mongo) ApplyEnrollment(ctx context.Context, courseID int64, inscriptionID int64, inscriptionVersion int64, enrolled bool) (coursesDAO.Course, bool, error) {
+	operator := "$setDifference"
+	if enrolled {
+		operator = "$setUnion"
+	}
+	version := time.Now().UnixMicro()
+
+	versions := bson.M{"$ifNull": bson.A{"$enrollment_versions", bson.A{}}}
+	lastVersion := bson.M{"$ifNull": bson.A{
+		bson.M{"$arrayElemAt": bson.A{
+			bson.M{"$map": bson.M{
+				"input": bson.M{"$filter": bson.M{"input": versions, "as": "e", "cond": bson.M{"$eq": bson.A{"$$e.id", inscriptionID}}}},
+				"as":    "e",
+				"in":    "$$e.version",
+			}},
+			0,
+		}},
+		0,
+	}}
+	enrollments := bson.M{"$ifNull": bson.A{"$enrollments", bson.A{}}}
+
+	collection := m.client.Database(m.database).Collection(m.collection)
+	filter := bson.M{"id": courseID}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"_apply": bson.M{"$or": bson.A{
+				bson.M{"$gt": bson.A{inscriptionVersion, lastVersion}},
+				bson.M{"$and": bson.A{bson.M{"$eq": bson.A{inscriptionVersion, 0}}, bson.M{"$eq": bson.A{lastVersion, 0}}}},
+			}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"enrollments": bson.M{"$cond": bson.A{"$_apply", bson.M{operator: bson.A{enrollments, bson.A{inscriptionID}}}, enrollments}},
+			"enrollment_versions": bson.M{"$cond": bson.A{
+				"$_apply",
+				bson.M{"$concatArrays": bson.A{
+					bson.M{"$filter": bson.M{"input": versions, "as": "e", "cond": bson.M{"$ne": bson.A{"$$e.id", inscriptionID}}}},
+					bson.A{bson.M{"id": inscriptionID, "version": inscriptionVersion}},
+				}},
+				versions,
+			}},
+		}}},
+		{{Key: "$set", Value: bson.M{"enrolled_count": bson.M{"$size": "$enrollments"}}}},
+		// Las dos expresiones ven el available anterior
+		{{Key: "$set", Value: bson.M{
+			"available": availableExpr,
+			"version":   bson.M{"$cond": bson.A{bson.M{"$ne": bson.A{availableExpr, "$available"}}, version, "$version"}},
+		}}},
+		{{Key: "$unset", Value: "_apply"}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated coursesDAO.Course
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return coursesDAO.Course{}, false, courses.ErrCourseNotFound
+	}
+	if err != nil {
+		return coursesDAO.Course{}, false, fmt.Errorf("failed to apply enrollment to course: %v", err)
+	}
+	return updated, updated.Version == version, nil
+}
+
+// GetUnsyncedCourseIDs devuelve los cursos cuyas inscripciones todavía no se
+// pidieron a inscriptions-api
+func (m Mongo) GetUnsyncedCourseIDs(ctx context.Context) ([]int64, error) {
+	collection := m.client.Database(m.database).Collection(m.collection)
+	opts := options.Find().SetProjection(bson.M{"id": 1}).SetSort(bson.D{{Key: "id", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{"enrollments_synced": bson.M{"$ne": true}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unsynced courses: %v", err)
+	}
+	var found []coursesDAO.Course
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("failed to decode unsynced courses: %v", err)
+	}
+	ids := make([]int64, 0, len(found))
+	for _, course := range found {
+		ids = append(ids, course.ID)
+	}
+	return ids, nil
+}
+
+// MarkEnrollmentsSynced registra que las inscripciones del curso ya se
+// pidieron. Hasta que lleguen, el conteo es el de las ya aplicadas.
+func (m Mongo) MarkEnrollmentsSynced(ctx context.Context, courseID int64) error {
+	collection := m.client.Database(m.database).Collection(m.collection)
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"enrollments":         bson.M{"$ifNull": bson.A{"$enrollments", bson.A{}}},
+			"enrollment_versions": bson.M{"$ifNull": bson.A{"$enrollment_versions", bson.A{}}},
+			"enrollments_synced":  true,
+		}}},
+		{{Key: "$set", Value: bson.M{"enrolled_count": bson.M{"$size": "$enrollments"}}}},
+	}
+	if _, err := collection.UpdateOne(ctx, bson.M{"id": courseID}, update); err != nil {
+		return fmt.Errorf("failed to mark enrollments of course %d as synced: %v", courseID, err)
+	}
+	return nil
 }
 
 func (m Mongo) DeleteCourse(ctx context.Context, id int64) error {
@@ -149,7 +325,7 @@ func (m Mongo) DeleteCourse(ctx context.Context, id int64) error {
 func (m Mongo) UpdateCourseRating(ctx context.Context, courseID int64, newRating float64) error {
 	collection := m.client.Database(m.database).Collection(m.collection)
 	filter := bson.M{"id": courseID}
-	update := bson.M{"$set": bson.M{"rating": newRating}}
+	update := bson.M{"$set": bson.M{"rating": newRating, "version": time.Now().UnixMicro()}}
 	_, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return fmt.Errorf("failed to update course rating: %v", err)
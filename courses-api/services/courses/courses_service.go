@@ -3,18 +3,24 @@ package courses
 import (
 	"context"
 	coursesDAO "courses-api/DAO/courses"
-	"courses-api/clients"
 	"courses-api/domain/courses"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // Repository interface para las operaciones de curso
 type Repository interface {
 	CreateCourse(ctx context.Context, course coursesDAO.Course) (coursesDAO.Course, error)
 	GetCourses(ctx context.Context) ([]coursesDAO.Course, error)
+	GetCoursesPage(ctx context.Context, offset int64, limit int64) ([]coursesDAO.Course, error)
+	GetCoursesByIDs(ctx context.Context, ids []int64) ([]coursesDAO.Course, error)
 	GetCourseByID(ctx context.Context, id int64) (coursesDAO.Course, error)
 	UpdateCourse(ctx context.Context, course coursesDAO.Course) (coursesDAO.Course, error)
+	ApplyEnrollment(ctx context.Context, courseID int64, inscriptionID int64, inscriptionVersion int64, enrolled bool) (coursesDAO.Course, bool, error)
+	GetUnsyncedCourseIDs(ctx context.Context) ([]int64, error)
+	MarkEnrollmentsSynced(ctx context.Context, courseID int64) error
 	DeleteCourse(ctx context.Context, id int64) error
 }
 
@@ -27,6 +33,11 @@ type Queue interface {
 	Publish(courseNew courses.CursosNew) error
 }
 
+// CourseEventsQueue publica los eventos de cursos que consume inscriptions-api
+type CourseEventsQueue interface {
+	PublishCourseEvent(event courses.CourseEvent) error
+}
+
 // FilesRepository interface para las operaciones de archivos
 type FilesRepository interface {
 	DeleteFilesByCourseID(ctx context.Context, courseID int64) error
@@ -38,17 +49,17 @@ type Service struct {
 	commentsRepository CommentsRepository
 	filesRepository    FilesRepository
 	eventsQueue        Queue
-	httpClient         *clients.HTTPClient
+	courseEvents       CourseEventsQueue
 }
 
 // NewService constructor para el servicio de cursos
-func NewService(repository Repository, commentsRepository CommentsRepository, filesRepository FilesRepository, eventsQueue Queue, httpClient *clients.HTTPClient) Service {
+func NewService(repository Repository, commentsRepository CommentsRepository, filesRepository FilesRepository, eventsQueue Queue, courseEvents CourseEventsQueue) Service {
 	return Service{
 		repository:         repository,
 		commentsRepository: commentsRepository,
 		filesRepository:    filesRepository,
 		eventsQueue:        eventsQueue,
-		httpClient:         httpClient,
+		courseEvents:       courseEvents,
 	}
 }
 
@@ -74,22 +85,25 @@ func (s Service) CreateCourse(ctx context.Context, req courses.CreateCourseReque
 		if err := s.eventsQueue.Publish(courses.CursosNew{
 			Operation: "POST",
 			ID:        createdCourse.ID,
+			Version:   createdCourse.Version,
 		}); err != nil {
 			fmt.Printf("Error al publicar nuevo curso: %v", err)
 		}
 	}()
 
 	return courses.CourseResponse{
-		ID:           createdCourse.ID,
-		Name:         createdCourse.Name,
-		Description:  createdCourse.Description,
-		Category:     createdCourse.Category,
-		Duration:     createdCourse.Duration,
-		InstructorID: createdCourse.InstructorID,
-		ImageBase64:  createdCourse.ImageBase64,
-		Capacity:     createdCourse.Capacity,
-		Rating:       createdCourse.Rating,
-		Available:    createdCourse.Available,
+		ID:            createdCourse.ID,
+		Name:          createdCourse.Name,
+		Description:   createdCourse.Description,
+		Category:      createdCourse.Category,
+		Duration:      createdCourse.Duration,
+		InstructorID:  createdCourse.InstructorID,
+		ImageBase64:   createdCourse.ImageBase64,
+		Capacity:      createdCourse.Capacity,
+		Rating:        createdCourse.Rating,
+		Available:     createdCourse.Available,
+		EnrolledCount: createdCourse.EnrolledCount,
+		Version:       createdCourse.Version,
 	}, nil
 }
 
@@ -102,16 +116,84 @@ func (s Service) GetCourses(ctx context.Context) ([]courses.CourseResponse, erro
 	var coursesResponse []courses.CourseResponse
 	for _, course := range coursesDAO {
 		coursesResponse = append(coursesResponse, courses.CourseResponse{
-			ID:           course.ID,
-			Name:         course.Name,
-			Description:  course.Description,
-			Category:     course.Category,
-			Duration:     course.Duration,
-			InstructorID: course.InstructorID,
-			ImageBase64:  course.ImageBase64,
-			Capacity:     course.Capacity,
-			Rating:       course.Rating,
-			Available:    course.Available,
+			ID:            course.ID,
+			Name:          course.Name,
+			Description:   course.Description,
+			Category:      course.Category,
+			Duration:      course.Duration,
+			InstructorID:  course.InstructorID,
+			ImageBase64:   course.ImageBase64,
+			Capacity:      course.Capacity,
+			Rating:        course.Rating,
+			Available:     course.Available,
+			EnrolledCount: course.EnrolledCount,
+			Version:       course.Version,
+		})
+	}
+
+	return coursesResponse, nil
+}
+
+// GetCoursesPage devuelve una página de cursos ordenados por ID
+func (s Service) GetCoursesPage(ctx context.Context, offset int64, limit int64) ([]courses.CourseResponse, error) {
+	coursesDAO, err := s.repository.GetCoursesPage(ctx, offset, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courses: %v", err)
+	}
+
+	coursesResponse := make([]courses.CourseResponse, 0, len(coursesDAO))
+	for _, course := range coursesDAO {
+		coursesResponse = append(coursesResponse, courses.CourseResponse{
+			ID:            course.ID,
+			Name:          course.Name,
+			Description:   course.Description,
+			Category:      course.Category,
+			Duration:      course.Duration,
+			InstructorID:  course.InstructorID,
+			ImageBase64:   course.ImageBase64,
+			Capacity:      course.Capacity,
+			Rating:        course.Rating,
+			Available:     course.Available,
+			EnrolledCount: course.EnrolledCount,
+			Version:       course.Version,
+		})
+	}
+
+	return coursesResponse, nil
+}
+
+// GetCoursesByIDs devuelve los cursos pedidos en el mismo orden, sin los que no
+// existen, así otra API obtiene muchos cursos con un solo pedido
+func (s Service) GetCoursesByIDs(ctx context.Context, ids []int64) ([]courses.CourseResponse, error) {
+	models, err := s.repository.GetCoursesByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courses: %v", err)
+	}
+	byID := make(map[int64]coursesDAO.Course, len(models))
+	for _, course := range models {
+		byID[course.ID] = course
+	}
+
+	coursesResponse := make([]courses.CourseResponse, 0, len(models))
+	for _, id := range ids {
+		course, ok := byID[id]
+		if !ok {
+			continue
+		}
+		delete(byID, id) // Un ID repetido se devuelve una vez
+		coursesResponse = append(coursesResponse, courses.CourseResponse{
+			ID:            course.ID,
+			Name:          course.Name,
+			Description:   course.Description,
+			Category:      course.Category,
+			Duration:      course.Duration,
+			InstructorID:  course.InstructorID,
+			ImageBase64:   course.ImageBase64,
+			Capacity:      course.Capacity,
+			Rating:        course.Rating,
+			Available:     course.Available,
+			EnrolledCount: course.EnrolledCount,
+			Version:       course.Version,
 		})
 	}
 
@@ -121,33 +203,31 @@ func (s Service) GetCourses(ctx context.Context) ([]courses.CourseResponse, erro
 func (s Service) GetCourseByID(ctx context.Context, id int64) (courses.CourseResponse, error) {
 	course, err := s.repository.GetCourseByID(ctx, id)
 	if err != nil {
-		return courses.CourseResponse{}, fmt.Errorf("failed to get course: %v", err)
+		return courses.CourseResponse{}, fmt.Errorf("failed to get course: %w", err)
 	}
 
 	return courses.CourseResponse{
-		ID:           course.ID,
-		Name:         course.Name,
-		Description:  course.Description,
-		Category:     course.Category,
-		Duration:     course.Duration,
-		InstructorID: course.InstructorID,
-		ImageBase64:  course.ImageBase64,
-		Capacity:     course.Capacity,
-		Rating:       course.Rating,
-		Available:    course.Available,
+		ID:            course.ID,
+		Name:          course.Name,
+		Description:   course.Description,
+		Category:      course.Category,
+		Duration:      course.Duration,
+		InstructorID:  course.InstructorID,
+		ImageBase64:   course.ImageBase64,
+		Capacity:      course.Capacity,
+		Rating:        course.Rating,
+		Available:     course.Available,
+		EnrolledCount: course.EnrolledCount,
+		Version:       course.Version,
 	}, nil
 }
 
 func (s Service) UpdateCourse(ctx context.Context, id int64, req courses.UpdateCourseRequest) (courses.CourseResponse, error) {
-	var auxiliar int
-	var borrarcurso bool
-	borrarcurso = false
 	course, err := s.repository.GetCourseByID(ctx, id)
 	if err != nil {
 		return courses.CourseResponse{}, fmt.Errorf("course not found: %v", err)
 	}
-
-	// Verificar si la capacidad ha cambiado
+	previous := course
 
 	// Actualizar los campos del curso
 	if req.Name != "" {
@@ -166,102 +246,77 @@ func (s Service) UpdateCourse(ctx context.Context, id int64, req courses.UpdateC
 		course.InstructorID = req.InstructorID
 	}
 	if req.Capacity != 0 {
-		if req.Capacity < course.Capacity {
-			// Verificar inscripciones actuales
-			inscriptions, err := s.httpClient.GetInscriptionsByCourse(uint(id))
-			if err != nil {
-				return courses.CourseResponse{}, fmt.Errorf("error al verificar inscripciones: %v", err)
-			}
-			if len(inscriptions) > req.Capacity {
-				return courses.CourseResponse{}, fmt.Errorf("no se puede reducir la capacidad a un número menor que las inscripciones actuales")
-			}
-
-		}
-		if req.Capacity > course.Capacity {
-			if !course.Available {
-				req.Available = false
-				course.Available = true
-			}
+		// Los inscriptos se cuentan con los eventos de inscriptions-api
+		if req.Capacity < course.EnrolledCount {
+			return courses.CourseResponse{}, fmt.Errorf("no se puede reducir la capacidad a un número menor que las inscripciones actuales")
 		}
-
-		auxiliar = course.Capacity
 		course.Capacity = req.Capacity
 	}
 	if req.ImageBase64 != "" {
 		course.ImageBase64 = req.ImageBase64
 	}
 
+	// La disponibilidad la recalcula el repositorio con la capacidad nueva
 	updatedCourse, err := s.repository.UpdateCourse(ctx, course)
 	if err != nil {
 		return courses.CourseResponse{}, fmt.Errorf("failed to update course: %v", err)
 	}
 
-	if req.Capacity != 0 {
-		if req.Capacity < auxiliar {
-			inscriptions, err := s.httpClient.GetInscriptionsByCourse(uint(id))
-			if err != nil {
-				return courses.CourseResponse{}, fmt.Errorf("error al verificar inscripciones: %v", err)
+	if updatedCourse.Capacity > previous.Capacity {
+		// Los nuevos lugares son primero para la lista de espera: inscriptions-api
+		// la promueve al recibir el evento. Si no se publica, la próxima
+		// inscripción al curso la atiende antes de ocupar un lugar.
+		go func() {
+			if err := s.courseEvents.PublishCourseEvent(courses.CourseEvent{
+				Type:     courses.EventCapacityIncreased,
+				CourseID: updatedCourse.ID,
+				Capacity: updatedCourse.Capacity,
+			}); err != nil {
+				fmt.Printf("Error al publicar el aumento de capacidad del curso %d: %v\n", id, err)
 			}
-			if len(inscriptions) == req.Capacity {
-				if err := s.UpdateCourseAvailability(ctx, course.ID); err != nil {
-					return courses.CourseResponse{}, fmt.Errorf("error al actualizar disponibilidad: %v", err)
-				}
-				borrarcurso = true
-			}
-
-		}
-		if req.Capacity > auxiliar {
-			if !req.Available {
-				go func() {
-					if err := s.eventsQueue.Publish(courses.CursosNew{
-						Operation: "POST",
-						ID:        course.ID,
-					}); err != nil {
-						fmt.Printf("Error al publicar nuevo curso: %v", err)
-					}
-				}()
-
+		}()
+	}
+
+	// search-api solo indexa los cursos disponibles
+	switch {
+	case updatedCourse.Available != previous.Available:
+		go s.publishAvailability(updatedCourse)
+	case updatedCourse.Available:
+		go func() {
+			if err := s.eventsQueue.Publish(courses.CursosNew{
+				Operation: "UPDATE",
+				ID:        updatedCourse.ID,
+				Version:   updatedCourse.Version,
+			}); err != nil {
+				fmt.Printf("Error al publicar nuevo curso: %v", err)
 			}
-
-		}
-	}
-	// Actualizar la disponibilidad del curso
-
-	if course.Available {
-		if !borrarcurso {
-			go func() {
-				if err := s.eventsQueue.Publish(courses.CursosNew{
-					Operation: "UPDATE",
-					ID:        course.ID,
-				}); err != nil {
-					fmt.Printf("Error al publicar nuevo curso: %v", err)
-				}
-			}()
-		}
+		}()
 	}
 	return courses.CourseResponse{
-		ID:           updatedCourse.ID,
-		Name:         updatedCourse.Name,
-		Description:  updatedCourse.Description,
-		Category:     updatedCourse.Category,
-		Duration:     updatedCourse.Duration,
-		InstructorID: updatedCourse.InstructorID,
-		ImageBase64:  updatedCourse.ImageBase64,
-		Capacity:     updatedCourse.Capacity,
-		Rating:       updatedCourse.Rating,
-		Available:    updatedCourse.Available,
+		ID:            updatedCourse.ID,
+		Name:          updatedCourse.Name,
+		Description:   updatedCourse.Description,
+		Category:      updatedCourse.Category,
+		Duration:      updatedCourse.Duration,
+		InstructorID:  updatedCourse.InstructorID,
+		ImageBase64:   updatedCourse.ImageBase64,
+		Capacity:      updatedCourse.Capacity,
+		Rating:        updatedCourse.Rating,
+		Available:     updatedCourse.Available,
+		EnrolledCount: updatedCourse.EnrolledCount,
+		Version:       updatedCourse.Version,
 	}, nil
 }
 
 func (s Service) DeleteCourse(ctx context.Context, id int64) error {
 	// Verificar si hay inscripciones para este curso
-	inscriptions, err := s.httpClient.GetInscriptionsByCourse(uint(id))
+	course, err := s.repository.GetCourseByID(ctx, id)
 	if err != nil {
-		return fmt.Errorf("error al verificar inscripciones: %v", err)
+		return fmt.Errorf("error al obtener el curso: %w", err)
 	}
 
-	if len(inscriptions) > 0 {
-		return fmt.Errorf("no se puede eliminar el curso porque tiene %d inscripciones activas", len(inscriptions))
+	if course.EnrolledCount > 0 {
+		return fmt.Errorf("no se puede eliminar el curso porque tiene %d inscripciones activas", course.EnrolledCount)
 	}
 
 	// Eliminar los comentarios asociados al curso
@@ -286,6 +341,7 @@ func (s Service) DeleteCourse(ctx context.Context, id int64) error {
 		if err := s.eventsQueue.Publish(courses.CursosNew{
 			Operation: "DELETE",
 			ID:        id,
+			Version:   time.Now().UnixMicro(),
 		}); err != nil {
 			fmt.Printf("Error al publicar eliminación de curso: %v", err)
 		}
@@ -310,51 +366,112 @@ func (s Service) UpdateCourseRating(ctx context.Context, courseID int64, newRati
 	return nil
 }
 
+// UpdateCourseAvailability recalcula la disponibilidad del curso con los
+// inscriptos que cuentan los eventos, sin consultar a inscriptions-api
 func (s Service) UpdateCourseAvailability(ctx context.Context, courseID int64) error {
-	// Obtener el curso por ID
 	course, err := s.repository.GetCourseByID(ctx, courseID)
 	if err != nil {
 		return fmt.Errorf("error al obtener el curso: %v", err)
 	}
 
-	// Log para mostrar el estado del curso
-	fmt.Printf("Estado del curso antes de la actualización: %+v\n", course)
+	updatedCourse, err := s.repository.UpdateCourse(ctx, course)
+	if err != nil {
+		return fmt.Errorf("error al actualizar la disponibilidad del curso: %v", err)
+	}
 
-	// Obtener las inscripciones actuales para el curso
-	inscriptions, err := s.httpClient.GetInscriptionsByCourse(uint(courseID))
+	if updatedCourse.Available != course.Available {
+		s.publishAvailability(updatedCourse)
+	}
+	return nil
+}
+
+// ApplyEnrollmentEvent actualiza el cupo del curso con un evento de
+// inscriptions-api. Si el curso se llenó o se liberó un lugar, se lo avisa a
+// search-api. Los demás tipos de eventos, y los que son más viejos que el
+// último aplicado de la misma inscripción, se ignoran. Un enrollment.snapshot
+// lleva la versión actual de la inscripción sin cambiarla, así que no pisa un
+// evento posterior ni cuenta dos veces uno ya aplicado.
+func (s Service) ApplyEnrollmentEvent(ctx context.Context, event courses.EnrollmentEvent) error {
+	var enrolled bool
+	switch event.Type {
+	case courses.EventEnrollmentCreated:
+		enrolled = true
+	case courses.EventEnrollmentCancelled:
+		enrolled = false
+	case courses.EventEnrollmentSnapshot:
+		enrolled = event.Status == courses.InscriptionStatusActive || event.Status == courses.InscriptionStatusCompleted
+	default:
+		return nil
+	}
+
+	course, changed, err := s.repository.ApplyEnrollment(ctx, event.CourseID, event.InscriptionID, event.Version, enrolled)
+	if errors.Is(err, courses.ErrCourseNotFound) {
+		fmt.Printf("Evento %s de la inscripción %d para el curso %d, que no existe\n", event.Type, event.InscriptionID, event.CourseID)
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("error al obtener inscripciones: %v", err)
+		return fmt.Errorf("error al actualizar el cupo del curso %d: %w", event.CourseID, err)
 	}
 
-	// Log para mostrar la cantidad de inscripciones
-	fmt.Printf("Cantidad de inscripciones para el curso ID %d: %d\n", courseID, len(inscriptions))
+	if changed {
+		fmt.Printf("Curso ID %d: %d inscriptos de %d, disponible: %t\n", course.ID, course.EnrolledCount, course.Capacity, course.Available)
+		s.publishAvailability(course)
+	}
+	return nil
+}
 
-	// Verificar si la cantidad de inscripciones es igual o mayor que la capacidad
-	if len(inscriptions) >= course.Capacity {
-		course.Available = false
-		fmt.Printf("El curso ID %d ya no está disponible. Inscripciones: %d, Capacidad: %d\n", courseID, len(inscriptions), course.Capacity)
+// RequestEnrollments pide a inscriptions-api las inscripciones de los cursos
+// creados antes de que se contaran con sus eventos, que no tienen enrollments
+// ni enrolled_count. inscriptions-api responde con un evento
+// enrollment.snapshot por inscripción en la cola de eventos, con su versión,
+// así que se aplican como los demás y no pisan los que llegan mientras tanto.
+// El curso se marca una vez que el broker confirmó el pedido; si falla, se
+// puede volver a ejecutar.
+func (s Service) RequestEnrollments(ctx context.Context) error {
+	ids, err := s.repository.GetUnsyncedCourseIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("error al obtener los cursos a sincronizar: %w", err)
+	}
 
-		// Publicar un mensaje en RabbitMQ para indicar que el curso ya no está disponible
-		if err := s.eventsQueue.Publish(courses.CursosNew{
-			Operation: "DELETE",
-			ID:        course.ID,
+	for _, id := range ids {
+		if err := s.courseEvents.PublishCourseEvent(courses.CourseEvent{
+			Type:     courses.EventEnrollmentsRequested,
+			CourseID: id,
 		}); err != nil {
-			fmt.Printf("Error al publicar eliminación de curso en RabbitMQ: %v", err)
+			return fmt.Errorf("error al pedir las inscripciones del curso %d: %w", id, err)
+		}
+		if err := s.repository.MarkEnrollmentsSynced(ctx, id); err != nil {
+			return err
 		}
-	} else {
-		course.Available = true
-		fmt.Printf("El curso ID %d está disponible. Inscripciones: %d, Capacidad: %d\n", courseID, len(inscriptions), course.Capacity)
 	}
 
-	// Actualizar el curso en la base de datos
-	_, err = s.repository.UpdateCourse(ctx, course)
-	if err != nil {
-		return fmt.Errorf("error al actualizar la disponibilidad del curso: %v", err)
+	if len(ids) > 0 {
+		fmt.Printf("Inscripciones pedidas a inscriptions-api para %d cursos\n", len(ids))
 	}
-
 	return nil
 }
 
+// availabilityOperation es el evento de search-api para un curso cuya
+// disponibilidad cambió: sin lugares sale del buscador y con lugares vuelve
+func availabilityOperation(course coursesDAO.Course) string {
+	if course.Available {
+		return "POST"
+	}
+	return "DELETE"
+}
+
+// publishAvailability publica el cambio de disponibilidad del curso. Se publica
+// después de guardar para que el evento lleve la versión nueva.
+func (s Service) publishAvailability(course coursesDAO.Course) {
+	if err := s.eventsQueue.Publish(courses.CursosNew{
+		Operation: availabilityOperation(course),
+		ID:        course.ID,
+		Version:   course.Version,
+	}); err != nil {
+		fmt.Printf("Error al publicar la disponibilidad del curso en RabbitMQ: %v", err)
+	}
+}
+
 func (s Service) CourseAvailability(ctx context.Context) ([]courses.CourseResponse, error) {
 	coursesDAO, err := s.repository.GetCourses(ctx)
 	if err != nil {
@@ -364,16 +481,18 @@ func (s Service) CourseAvailability(ctx context.Context) ([]courses.CourseRespon
 	var coursesResponse []courses.CourseResponse
 	for _, course := range coursesDAO {
 		coursesResponse = append(coursesResponse, courses.CourseResponse{
-			ID:           course.ID,
-			Name:         course.Name,
-			Description:  course.Description,
-			Category:     course.Category,
-			Duration:     course.Duration,
-			InstructorID: course.InstructorID,
-			ImageBase64:  course.ImageBase64,
-			Capacity:     course.Capacity,
-			Rating:       course.Rating,
-			Available:    course.Available,
+			ID:            course.ID,
+			Name:          course.Name,
+			Description:   course.Description,
+			Category:      course.Category,
+			Duration:      course.Duration,
+			InstructorID:  course.InstructorID,
+			ImageBase64:   course.ImageBase64,
+			Capacity:      course.Capacity,
+			Rating:        course.Rating,
+			Available:     course.Available,
+			EnrolledCount: course.EnrolledCount,
+			Version:       course.Version,
 		})
 	}
 
@@ -8,7 +8,6 @@ function Register() {
     const [username, setUsername] = useState('');
     const [email, setEmail] = useState('');
     const [password, setPassword] = useState('');
-    const [user_type, setUserType] = useState('alumno');
     const [error, setError] = useState('');
     const { setUser } = useContext(UserContext);
     const navigate = useNavigate();
@@ -20,7 +19,8 @@ function Register() {
                 username,
                 email,
                 password,
-                user_type
+                // Los administradores los registra otro administrador
+                user_type: 'alumno'
             });
             setUser(response.data);
             alert('Registro exitoso');
@@ -39,10 +39,6 @@ function Register() {
                     <input type="text" value={username} onChange={e => setUsername(e.target.value)} placeholder="Usuario" required className="input-field" />
                 <input type="email" value={email} onChange={e => setEmail(e.target.value)} placeholder="Email" required className="input-field" />
                     <input type="password" value={password} onChange={e => setPassword(e.target.value)} placeholder="Contraseña" required className="input-field" />
-                <select value={user_type} onChange={e => setUserType(e.target.value)} required className="input-field">
-                    <option value="alumno">Alumno</option>
-                    <option value="administrador">Administrador</option>
-                </select>
                     <button type="submit" className="register-button">Registrarse</button>
             </form>
                 <div className="register-login-link">
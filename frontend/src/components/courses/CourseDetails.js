@@ -1,8 +1,10 @@
-import React, { useEffect, useState } from 'react';
+import React, { useEffect, useRef, useState } from 'react';
 import axios from 'axios';
 import { useNavigate, Link, useParams } from 'react-router-dom';
 import '../assets/styles/CourseDetails.css';
 
+// inscriptions-api toma el usuario del token
+const authHeaders = () => ({ Authorization: `Bearer ${localStorage.getItem('token')}` });
 
 function CourseDetails() {
     const { courseId } = useParams();
@@ -11,6 +13,10 @@ function CourseDetails() {
     const [loading, setLoading] 
     = useState(true);
     const [error, setError] = useState(null);
+    const [similar, setSimilar] = useState([]);
+    const [waitlistEntry, setWaitlistEntry] = useState(null);
+    // Si el pedido se repite (doble clic, reintento), inscriptions-api devuelve la respuesta original
+    const enrollKey = useRef(crypto.randomUUID());
 
     useEffect(() => {
         const fetchCourse = async () => {
@@ -27,6 +33,50 @@ function CourseDetails() {
         fetchCourse();
     }, [courseId]);
 
+    // Los cursos parecidos son opcionales: si la búsqueda falla, la sección no se muestra
+    useEffect(() => {
+        axios.get(`http://localhost:8082/search/courses/${courseId}/similar`, { params: { limit: 4 } })
+            .then(response => setSimilar(response.data.results || []))
+            .catch(() => setSimilar([]));
+    }, [courseId]);
+
+    // Posición del usuario en la lista de espera, si está anotado
+    useEffect(() => {
+        const userId = localStorage.getItem('userId');
+        if (!userId) {
+            return;
+        }
+        axios.get(`http://localhost:8085/courses/${courseId}/waitlist/${userId}`, { headers: authHeaders() })
+            .then(response => setWaitlistEntry(response.data))
+            .catch(() => setWaitlistEntry(null));
+    }, [courseId]);
+
+    const handleJoinWaitlist = async () => {
+        const userId = localStorage.getItem('userId');
+        if (!userId) {
+            alert('Por favor, inicia sesión para anotarte en la lista de espera.');
+            return;
+        }
+        try {
+            const response = await axios.post(`http://localhost:8085/courses/${courseId}/waitlist`, {}, {
+                headers: authHeaders()
+            });
+            setWaitlistEntry(response.data);
+        } catch (err) {
+            alert('Error al anotarse en la lista de espera: ' + (err.response?.data?.error || err.message));
+        }
+    };
+
+    const handleLeaveWaitlist = async () => {
+        const userId = localStorage.getItem('userId');
+        try {
+            await axios.delete(`http://localhost:8085/courses/${courseId}/waitlist/${userId}`, { headers: authHeaders() });
+            setWaitlistEntry(null);
+        } catch (err) {
+            alert('Error al salir de la lista de espera: ' + (err.response?.data?.error || err.message));
+        }
+    };
+
     const handleEnroll = async () => {
         const userId = localStorage.getItem('userId');
         if (!userId) {
@@ -37,12 +87,21 @@ function CourseDetails() {
         try {
             // Intentar inscribirse en el curso
             await axios.post(`http://localhost:8085/inscriptions`, {
-                user_id: parseInt(userId),
                 course_id: parseInt(courseId)
+            }, {
+                headers: { ...authHeaders(), 'Idempotency-Key': enrollKey.current }
             });
             alert('Inscripción exitosa!');
             navigate('/my-courses'); // Redirigir a "Mis Cursos" después de inscribirse
         } catch (err) {
+            // Un nuevo intento después de un error es un pedido distinto
+            enrollKey.current = crypto.randomUUID();
+            if (err.response?.status === 409 && err.response.data?.error === 'course has no seats left') {
+                if (window.confirm('El curso está lleno. ¿Querés anotarte en la lista de espera?')) {
+                    handleJoinWaitlist();
+                }
+                return;
+            }
             alert('Error en la inscripción: ' + err.response?.data?.error || err.message);
         }
     };
@@ -70,8 +129,18 @@ function CourseDetails() {
                         <p><strong>Rating:</strong> {course.rating}</p>
                     </div>
                     <div className="course-actions-block">
-                        {course.capacity > 0 ? (
+                        {waitlistEntry ? (
+                            <>
+                                <p className="full-message">Estás en la lista de espera, posición {waitlistEntry.position}.</p>
+                                <button onClick={handleLeaveWaitlist} className="enroll-button">Salir de la lista</button>
+                            </>
+                        ) : course.capacity > 0 && course.available ? (
                             <button onClick={handleEnroll} className="enroll-button">Inscribirse</button>
+                        ) : course.capacity > 0 ? (
+                            <>
+                                <p className="full-message">El curso está lleno.</p>
+                                <button onClick={handleJoinWaitlist} className="enroll-button">Unirme a la lista de espera</button>
+                            </>
                         ) : (
                             <p className="full-message">El curso está lleno. No se puede inscribir.</p>
                         )}
@@ -79,6 +148,18 @@ function CourseDetails() {
                         <Link to={`/courses/${courseId}/comments`} className="comments-button">Ver Comentarios</Link>
                     </div>
                 </div>
+                {similar.length > 0 && (
+                    <div className="similar-courses">
+                        <h2>Cursos parecidos</h2>
+                        <ul>
+                            {similar.map(other => (
+                                <li key={other.id}>
+                                    <Link to={`/courses/${other.id}`}>{other.name}</Link> <span>({other.category})</span>
+                                </li>
+                            ))}
+                        </ul>
+                    </div>
+                )}
             </main>
         </div>
     );
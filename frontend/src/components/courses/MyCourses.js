@@ -3,6 +3,9 @@ import axios from 'axios';
 import { Link, useNavigate } from 'react-router-dom';
 import '../assets/styles/MyCourses.css';
 
+// inscriptions-api toma el usuario del token
+const authHeaders = () => ({ Authorization: `Bearer ${localStorage.getItem('token')}` });
+
 function MyCourses() {
     const [courses, setCourses] = useState([]);
     const [loading, setLoading] = useState(true);
@@ -16,25 +19,16 @@ function MyCourses() {
                 if (!userId) {
                     throw new Error('User ID not found');
                 }
-                const inscriptionsResponse = await axios.get(`http://localhost:8085/users/${userId}/inscriptions`);
-                const courseIds = inscriptionsResponse.data
-                    .filter(inscription => inscription.course_id)
-                    .map(inscription => inscription.course_id);
-                if (courseIds.length === 0) {
-                    setCourses([]);
-                    return;
-                }
-            const coursesData = await Promise.all(
-                courseIds.map(async (id) => {
-                    try {
-                        const response = await axios.get(`http://localhost:8080/courses/${id}`);
-                        return response.data;
-                    } catch (error) {
-                        return null;
-                    }
-                })
-            );
-            setCourses(coursesData.filter(course => course !== null));
+                // expand=course trae los datos de cada curso en el mismo pedido
+                const inscriptionsResponse = await axios.get(`http://localhost:8085/users/${userId}/inscriptions`, {
+                    headers: authHeaders(),
+                    params: { expand: 'course', limit: 100 },
+                });
+                // Sin course, el curso ya no existe
+                const coursesData = inscriptionsResponse.data.results
+                    .filter(inscription => inscription.course)
+                    .map(inscription => ({ ...inscription.course, inscriptionId: inscription.id, status: inscription.status }));
+                setCourses(coursesData);
         } catch (err) {
             setError('Error fetching my courses');
         } finally {
@@ -50,6 +44,17 @@ function MyCourses() {
     const handleCommentClick = (courseId) => {
         navigate(`/courses/${courseId}/comments`);
     };
+    const handleCancelClick = async (course) => {
+        if (!window.confirm(`¿Darte de baja de ${course.name}?`)) {
+            return;
+        }
+        try {
+            await axios.post(`http://localhost:8085/inscriptions/${course.inscriptionId}/cancel`, null, { headers: authHeaders() });
+            setCourses(courses.filter(c => c.inscriptionId !== course.inscriptionId));
+        } catch (err) {
+            alert(err.response?.data?.error || 'Error al darse de baja del curso');
+        }
+    };
     if (loading) return <div className="my-courses-container">Cargando...</div>;
     if (error) return <div className="my-courses-container">{error}</div>;
     return (
@@ -62,7 +67,7 @@ function MyCourses() {
             {courses.length > 0 ? (
                     <div className="my-courses-grid">
                         {courses.map(course => (
-                            <div key={course.id} className="my-course-card">
+                            <div key={course.inscriptionId} className="my-course-card">
                                 <div className="my-course-card-content">
                                     <h3>{course.name}</h3>
                                     <p className="my-course-desc">{course.description}</p>
@@ -71,6 +76,9 @@ function MyCourses() {
                                     <Link to={`/courses/${course.id}`} className="details-button">Ver detalles</Link>
                                 <button onClick={() => handleUploadClick(course.id)} className="upload-button">Subir Archivo</button>
                                     <button onClick={() => handleCommentClick(course.id)} className="comment-button">Comentar</button>
+                                    {course.status !== 'completed' && (
+                                        <button onClick={() => handleCancelClick(course)} className="cancel-button">Darme de baja</button>
+                                    )}
                                 </div>
                             </div>
                         ))}
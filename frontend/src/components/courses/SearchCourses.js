@@ -8,19 +8,29 @@ const SearchCourses = () => {
     const [courses, setCourses] = useState([]);
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState(null);
+    const [didYouMean, setDidYouMean] = useState([]);
+    const [queryId, setQueryId] = useState(null);
     const navigate = useNavigate();
 
-    const handleSearch = async (e) => {
-        e.preventDefault();
+    // El token es opcional: solo se usa para contar usuarios distintos en la analítica
+    const authHeaders = () => {
+        const token = localStorage.getItem('token');
+        return token ? { Authorization: `Bearer ${token}` } : {};
+    };
+
+    const search = async (term) => {
         setLoading(true);
         setError(null);
         try {
-            const response = await axios.get(`http://localhost:8082/search?q=${searchTerm}`);
-            if (!response.data || response.data.length === 0) {
+            const response = await axios.get('http://localhost:8082/search', { params: { q: term }, headers: authHeaders() });
+            const results = (response.data && response.data.results) || [];
+            setQueryId((response.data && response.data.query_id) || null);
+            setDidYouMean((response.data && response.data.did_you_mean) || []);
+            if (results.length === 0) {
                 setError('No se encontraron cursos con ese nombre.');
                 setCourses([]);
             } else {
-                setCourses(response.data);
+                setCourses(results);
             }
         } catch (err) {
             setError('Error fetching courses: ' + err.message);
@@ -29,6 +39,27 @@ const SearchCourses = () => {
         }
     };
 
+    const handleSearch = (e) => {
+        e.preventDefault();
+        search(searchTerm);
+    };
+
+    // Informa el resultado elegido; un error aquí no debe impedir la navegación
+    const openCourse = (course, position) => {
+        if (queryId) {
+            axios.post('http://localhost:8082/search/click',
+                { query_id: queryId, course_id: course.id, position },
+                { headers: authHeaders() }
+            ).catch(() => {});
+        }
+        navigate(`/courses/${course.id}`);
+    };
+
+    const searchSuggestion = (query) => {
+        setSearchTerm(query);
+        search(query);
+    };
+
     return (
         <div className="search-courses-outer">
             <div className="search-courses-card">
@@ -47,14 +78,29 @@ const SearchCourses = () => {
             </form>
             {loading && <p>Cargando...</p>}
             {error && <p className="error-message">{error}</p>}
+            {didYouMean.length > 0 && (
+                <p className="did-you-mean">
+                    Quizás quisiste decir:{' '}
+                    {didYouMean.map(collation => (
+                        <button key={collation.query} className="link-button" onClick={() => searchSuggestion(collation.query)}>
+                            {collation.query}
+                        </button>
+                    ))}
+                </p>
+            )}
                 {courses.length > 0 && (
                     <div className="search-courses-grid">
-                    {courses.map(course => (
+                    {courses.map((course, index) => (
                             <div key={course.id} className="search-course-card">
-                            <h3>{course.name}</h3>
-                            <p>{course.description}</p>
+                            {/* El resaltado llega escapado como HTML desde la API, solo con los marcadores */}
+                            {course.highlight && course.highlight.name
+                                ? <h3 dangerouslySetInnerHTML={{ __html: course.highlight.name }} />
+                                : <h3>{course.name}</h3>}
+                            {course.highlight && course.highlight.description
+                                ? <p dangerouslySetInnerHTML={{ __html: course.highlight.description }} />
+                                : <p>{course.description}</p>}
                             <div className="my-course-actions">
-                                <button className="details-button" onClick={() => navigate(`/courses/${course.id}`)}>Ver detalles</button>
+                                <button className="details-button" onClick={() => openCourse(course, index + 1)}>Ver detalles</button>
                                 <button className="upload-button" onClick={() => navigate(`/upload/${course.id}`)}>Subir Archivo</button>
                                 <button className="comment-button" onClick={() => navigate(`/courses/${course.id}/comments`)}>Comentar</button>
                             </div>
@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	stateClosed = iota
+	stateOpen
+	stateHalfOpen
+)
+
+// breaker es un circuit breaker por servicio: después de threshold fallas
+// seguidas deja de llamarlo durante openTimeout, y después deja pasar un solo
+// pedido de prueba. Si la prueba sale bien se cierra; si no, vuelve a abrirse.
+type breaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow indica si se puede llamar al servicio
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = stateHalfOpen
+		b.probing = true
+		log.Printf("%s: circuit breaker half-open, probing", b.name)
+		return true
+	case stateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record anota el resultado de un pedido que allow dejó pasar
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		if b.state != stateClosed {
+			log.Printf("%s: circuit breaker closed", b.name)
+		}
+		b.state = stateClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || (b.state == stateClosed && b.failures >= b.threshold) {
+		log.Printf("%s: circuit breaker open after %d failures", b.name, b.failures)
+		b.state = stateOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+// release libera la prueba de un pedido que no llegó a tener resultado (por
+// ejemplo, porque lo canceló el cliente)
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == stateHalfOpen {
+		b.probing = false
+	}
+}
@@ -0,0 +1,249 @@
+// Package httpclient es el cliente HTTP con el que las APIs se llaman entre sí.
+// Cada servicio al que se llama (upstream) tiene su propio Client, con su
+// circuit breaker y su bulkhead, así un servicio caído o lento no arrastra las
+// llamadas a los demás.
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// metrics expone en /debug/vars los pedidos, reintentos y rechazos de cada upstream
+var metrics = expvar.NewMap("http_clients")
+
+type Config struct {
+	Name             string        // nombre del upstream, para los errores y las métricas
+	Timeout          time.Duration // plazo de cada intento, si el del contexto no es menor
+	MaxAttempts      int           // intentos de los pedidos idempotentes
+	BaseBackoff      time.Duration // espera antes del primer reintento; después se duplica
+	MaxBackoff       time.Duration
+	FailureThreshold int           // fallas seguidas que abren el circuito
+	OpenTimeout      time.Duration // tiempo que el circuito queda abierto
+	MaxConcurrent    int           // pedidos en curso a la vez (bulkhead)
+	QueueTimeout     time.Duration // espera por un lugar en el bulkhead
+}
+
+type Client struct {
+	config  Config
+	client  *http.Client
+	breaker *breaker
+	slots   chan struct{}
+}
+
+// New crea el cliente de un upstream. Los valores en cero de config toman
+// valores por defecto.
+func New(config Config) *Client {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = 100 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 2 * time.Second
+	}
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = 32
+	}
+	if config.QueueTimeout <= 0 {
+		config.QueueTimeout = time.Second
+	}
+
+	return &Client{
+		config: config,
+		client: &http.Client{},
+		breaker: &breaker{
+			name:        config.Name,
+			threshold:   config.FailureThreshold,
+			openTimeout: config.OpenTimeout,
+		},
+		slots: make(chan struct{}, config.MaxConcurrent),
+	}
+}
+
+// Do hace el pedido con el contexto de req. Los pedidos idempotentes (GET,
+// HEAD, OPTIONS, PUT, DELETE o con Idempotency-Key) se reintentan con espera
+// exponencial y aleatoria ante fallas de red, 5xx y 429. Las respuestas 4xx y
+// 5xx se devuelven como *StatusError.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	metrics.Add(c.config.Name+".requests", 1)
+
+	attempts := 1
+	if retryable(req) {
+		attempts = c.config.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, retry, err := c.attempt(req, attempt)
+		if err == nil {
+			return resp, nil
+		}
+		if !retry || attempt >= attempts {
+			metrics.Add(c.config.Name+".failures", 1)
+			return nil, err
+		}
+
+		metrics.Add(c.config.Name+".retries", 1)
+		if sleepErr := sleep(req.Context(), c.backoff(attempt)); sleepErr != nil {
+			metrics.Add(c.config.Name+".failures", 1)
+			return nil, err
+		}
+	}
+}
+
+// DoJSON hace el pedido y decodifica la respuesta en out (si no es nil)
+func (c *Client) DoJSON(req *http.Request, out any) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: error decoding response: %w", c.config.Name, err)
+	}
+	return nil
+}
+
+// attempt hace un intento del pedido e indica si vale la pena reintentarlo
+func (c *Client) attempt(req *http.Request, attempt int) (*http.Response, bool, error) {
+	ctx := req.Context()
+	if !c.breaker.allow() {
+		metrics.Add(c.config.Name+".circuit_open", 1)
+		return nil, false, fmt.Errorf("%s: %w", c.config.Name, ErrCircuitOpen)
+	}
+	release, err := c.acquire(ctx)
+	if err != nil {
+		c.breaker.release()
+		return nil, false, err
+	}
+	defer release()
+
+	attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	attemptReq := req.Clone(attemptCtx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			c.breaker.release()
+			return nil, false, fmt.Errorf("%s: error rewinding request body: %w", c.config.Name, err)
+		}
+		attemptReq.Body = body
+	}
+
+	resp, err := c.client.Do(attemptReq)
+	if err != nil {
+		cancel()
+		// Si el que llama dejó de esperar, no es una falla del servicio
+		if ctx.Err() != nil {
+			c.breaker.release()
+			return nil, false, fmt.Errorf("%s: %w", c.config.Name, ctx.Err())
+		}
+		c.breaker.record(false)
+		return nil, true, fmt.Errorf("%s: %w: %w", c.config.Name, ErrUnavailable, err)
+	}
+
+	c.breaker.record(resp.StatusCode < http.StatusInternalServerError)
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		cancel()
+		retry := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, &StatusError{
+			Upstream:   c.config.Name,
+			Method:     req.Method,
+			URL:        req.URL.Redacted(),
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+		}
+	}
+
+	// El plazo del intento sigue corriendo mientras se lee el cuerpo
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, false, nil
+}
+
+// acquire toma un lugar del bulkhead, esperando a lo sumo QueueTimeout
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	release := func() { <-c.slots }
+	select {
+	case c.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	timer := time.NewTimer(c.config.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case c.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		metrics.Add(c.config.Name+".rejected", 1)
+		return nil, fmt.Errorf("%s: %w", c.config.Name, ErrBulkheadFull)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", c.config.Name, ctx.Err())
+	}
+}
+
+// backoff devuelve la espera antes del reintento: la mitad fija y la otra
+// mitad al azar, para que las réplicas no reintenten todas a la vez
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.config.BaseBackoff << (attempt - 1)
+	if wait <= 0 || wait > c.config.MaxBackoff {
+		wait = c.config.MaxBackoff
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func sleep(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
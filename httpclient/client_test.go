@@ -0,0 +1,183 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"httpclient"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// upstream responde con los códigos de statuses en orden (el último se repite)
+// y cuenta los pedidos
+func upstream(t *testing.T, calls *atomic.Int32, statuses ...int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		status := statuses[min(n, len(statuses))-1]
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newClient(name string) *httpclient.Client {
+	return httpclient.New(httpclient.Config{
+		Name:             name,
+		Timeout:          time.Second,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		FailureThreshold: 3,
+		OpenTimeout:      50 * time.Millisecond,
+	})
+}
+
+func get(t *testing.T, ctx context.Context, client *httpclient.Client, url string) error {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("error building request: %v", err)
+	}
+	var out struct{ ID int }
+	return client.DoJSON(req, &out)
+}
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := upstream(t, &calls, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK)
+
+	if err := get(t, context.Background(), newClient("retries"), server.URL); err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+}
+
+func TestDoesNotRetryNonIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := upstream(t, &calls, http.StatusServiceUnavailable, http.StatusOK)
+	client := newClient("post")
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	if err := client.DoJSON(req, nil); !errors.Is(err, httpclient.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d", calls.Load())
+	}
+
+	// Con Idempotency-Key sí se reintenta, y el cuerpo se vuelve a enviar
+	calls.Store(0)
+	req, _ = http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "key")
+	if err := client.DoJSON(req, nil); err != nil || calls.Load() != 2 {
+		t.Errorf("expected the retry to succeed, got %v after %d attempts", err, calls.Load())
+	}
+}
+
+func TestMapsStatusCodesToTypedErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := upstream(t, &calls, http.StatusNotFound)
+
+	err := get(t, context.Background(), newClient("not-found"), server.URL)
+	if !errors.Is(err, httpclient.ErrNotFound) || errors.Is(err, httpclient.ErrUnavailable) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	var statusErr *httpclient.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a StatusError with code 404, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 404 not to be retried, got %d attempts", calls.Load())
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+	client := newClient("breaker")
+
+	// Tres fallas seguidas abren el circuito
+	if err := get(t, context.Background(), client, server.URL); !errors.Is(err, httpclient.ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if err := get(t, context.Background(), client, server.URL); !errors.Is(err, httpclient.ErrCircuitOpen) {
+		t.Fatalf("expected the circuit to be open, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected no calls while the circuit is open, got %d", calls.Load())
+	}
+
+	// Pasado el tiempo, un pedido de prueba que sale bien lo cierra
+	healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if err := get(t, context.Background(), client, server.URL); err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	if err := get(t, context.Background(), client, server.URL); err != nil {
+		t.Errorf("expected the circuit to be closed, got %v", err)
+	}
+}
+
+func TestPropagatesContextDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := get(t, ctx, newClient("deadline"), server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the request to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestBulkheadRejectsExcessRequests(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer server.Close()
+	client := httpclient.New(httpclient.Config{Name: "bulkhead", MaxConcurrent: 2, QueueTimeout: 20 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, context.Background(), client, server.URL)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if err := get(t, context.Background(), client, server.URL); !errors.Is(err, httpclient.ErrBulkheadFull) {
+		t.Errorf("expected ErrBulkheadFull, got %v", err)
+	}
+	close(unblock)
+	wg.Wait()
+}
@@ -0,0 +1,43 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound es un 404 del servicio
+	ErrNotFound = errors.New("resource not found")
+	// ErrUnavailable agrupa los errores de un servicio que no puede responder:
+	// 5xx, fallas de red, tiempo agotado, circuito abierto o bulkhead lleno
+	ErrUnavailable = errors.New("service unavailable")
+
+	ErrCircuitOpen  = fmt.Errorf("circuit breaker open: %w", ErrUnavailable)
+	ErrBulkheadFull = fmt.Errorf("too many concurrent requests: %w", ErrUnavailable)
+)
+
+// StatusError es una respuesta 4xx o 5xx del servicio. Los 404 cumplen
+// errors.Is(err, ErrNotFound) y los 5xx errors.Is(err, ErrUnavailable).
+type StatusError struct {
+	Upstream   string
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string // el comienzo del cuerpo, para los logs
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s %s: status code %d", e.Upstream, e.Method, e.URL, e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
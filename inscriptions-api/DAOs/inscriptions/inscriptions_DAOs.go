@@ -1,13 +1,85 @@
 package dao
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
+// InscriptionModel es la inscripción de un usuario a un curso. Hay una sola
+// fila por usuario y curso: volver a inscribirse después de una baja reactiva
+// la misma.
 type InscriptionModel struct {
-	ID       uint `gorm:"primaryKey;autoIncrement"`
-	UserID   uint `gorm:"not null;index"`
-	CourseID uint `gorm:"not null;index"`
+	ID          uint      `gorm:"primaryKey;autoIncrement"`
+	UserID      uint      `gorm:"not null;index;uniqueIndex:idx_inscription_user_course"`
+	CourseID    uint      `gorm:"not null;index;uniqueIndex:idx_inscription_user_course"`
+	Status      string    `gorm:"size:16;not null;default:active;index"`
+	CreatedAt   time.Time `gorm:"index"`
+	UpdatedAt   time.Time
+	CancelledAt *time.Time
+	Version     uint64 `gorm:"not null;default:0"` // Aumenta con cada evento publicado sobre la inscripción
+}
+
+// CourseSeatsModel es el registro de lugares ocupados de un curso. Todas las
+// inscripciones pasan por su fila, así que las réplicas de la API no pueden
+// ocupar más lugares que la capacidad aunque se inscriban a la vez.
+type CourseSeatsModel struct {
+	CourseID  uint `gorm:"primaryKey;autoIncrement:false"`
+	Capacity  int  `gorm:"not null"` // Última capacidad informada por courses-api
+	Reserved  int  `gorm:"not null"` // Lugares ocupados
+	UpdatedAt time.Time
+}
+
+// WaitlistEntryModel es un lugar en la lista de espera de un curso lleno. El
+// orden de llegada lo da el ID; al pasar a inscripción la fila se borra.
+type WaitlistEntryModel struct {
+	ID        uint `gorm:"primaryKey;autoIncrement"`
+	UserID    uint `gorm:"not null;uniqueIndex:idx_waitlist_user_course"`
+	CourseID  uint `gorm:"not null;uniqueIndex:idx_waitlist_user_course;index"`
+	CreatedAt time.Time
+}
+
+// EventModel es un evento pendiente de publicar (outbox). Se guarda en la misma
+// transacción que el cambio que lo origina, así no se pierde si RabbitMQ no
+// está disponible, y se publica después.
+type EventModel struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement"`
+	EventID     string `gorm:"size:32;not null;uniqueIndex"`
+	Type        string `gorm:"size:64;not null"`
+	Payload     []byte `gorm:"not null"`
+	CreatedAt   time.Time
+	PublishedAt *time.Time `gorm:"index"`
+}
+
+// IdempotencyKeyModel guarda la respuesta a un pedido con Idempotency-Key
+// para devolverla si el pedido se repite. StatusCode 0 indica que el pedido
+// original todavía se está procesando: la réplica que lo procesa lo reserva
+// hasta LockedUntil, y si se cae, un reintento lo retoma cuando el plazo vence.
+type IdempotencyKeyModel struct {
+	IdempotencyKey string `gorm:"primaryKey;size:160"` // Clave del encabezado, precedida por el ID del usuario
+	Fingerprint    string `gorm:"size:64;not null"`    // SHA-256 del método, la ruta y el cuerpo
+	StatusCode     int    `gorm:"not null"`
+	Response       []byte
+	LockedUntil    *time.Time
+	CreatedAt      time.Time
+	ExpiresAt      time.Time `gorm:"not null;index"`
+}
+
+// EnrollmentSagaModel es el estado de la saga de una inscripción: qué paso
+// falta ejecutar y cuántas veces falló. Una réplica la reserva hasta
+// LockedUntil mientras la ejecuta; si se cae, el recuperador la retoma
+// cuando el plazo vence.
+type EnrollmentSagaModel struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement"`
+	InscriptionID uint      `gorm:"not null;index"`
+	CourseID      uint      `gorm:"not null"`
+	Step          string    `gorm:"size:32;not null"` // Próximo paso a ejecutar
+	Status        string    `gorm:"size:16;not null;index"`
+	Attempts      int       `gorm:"not null"` // Intentos fallidos del paso actual
+	LastError     string    `gorm:"size:512"`
+	LockedUntil   time.Time `gorm:"not null;index"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
 type InscriptionDAO struct {
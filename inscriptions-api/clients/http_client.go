@@ -1,95 +1,127 @@
 package clients
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
+	domain "inscriptions-api/domain/inscriptions"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"httpclient"
 )
 
+// HTTPClient llama a users-api y a courses-api. Cada una tiene su propio
+// httpclient.Client, así una API caída no frena las llamadas a la otra.
 type HTTPClient struct {
 	usersAPIURL   string
 	coursesAPIURL string
-	client        *http.Client
+	users         *httpclient.Client
+	courses       *httpclient.Client
 }
 
-func NewHTTPClient(usersAPIURL, coursesAPIURL string) *HTTPClient {
+// NewHTTPClient crea el cliente con config para las dos APIs (el nombre de
+// cada upstream lo pone el cliente)
+func NewHTTPClient(usersAPIURL, coursesAPIURL string, config httpclient.Config) *HTTPClient {
+	usersConfig, coursesConfig := config, config
+	usersConfig.Name = "users-api"
+	coursesConfig.Name = "courses-api"
 	return &HTTPClient{
 		usersAPIURL:   usersAPIURL,
 		coursesAPIURL: coursesAPIURL,
-		client:        &http.Client{},
+		users:         httpclient.New(usersConfig),
+		courses:       httpclient.New(coursesConfig),
 	}
 }
 
-func (c *HTTPClient) CheckUserExists(userID uint) error {
-	resp, err := c.client.Get(fmt.Sprintf("%s/users/%d", c.usersAPIURL, userID))
+func (c *HTTPClient) CheckUserExists(ctx context.Context, userID uint) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/users/%d", c.usersAPIURL, userID), nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("user does not exist")
+	if err := c.users.DoJSON(req, nil); err != nil {
+		if errors.Is(err, httpclient.ErrNotFound) {
+			return fmt.Errorf("%w: %d", domain.ErrUserNotFound, userID)
+		}
+		return err
 	}
-
 	return nil
 }
 
-func (c *HTTPClient) CheckCourseExists(courseID uint) error {
-	resp, err := c.client.Get(fmt.Sprintf("%s/courses/%d", c.coursesAPIURL, courseID))
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("course with ID %d not found", courseID)
-	}
-
-	return nil
+func (c *HTTPClient) CheckCourseExists(ctx context.Context, courseID uint) error {
+	_, err := c.GetCourseDetails(ctx, courseID)
+	return err
 }
 
 type CourseDetails struct {
-	ID        uint `json:"id"`
-	Capacity  int  `json:"capacity"`
-	Available bool `json:"available"`
+	ID           uint `json:"id"`
+	InstructorID uint `json:"instructor_id"`
+	Capacity     int  `json:"capacity"`
+	Available    bool `json:"available"`
 	// Add other fields if needed
 }
 
-func (c *HTTPClient) GetCourseDetails(courseID uint) (*CourseDetails, error) {
-	resp, err := c.client.Get(fmt.Sprintf("%s/courses/%d", c.coursesAPIURL, courseID))
+func (c *HTTPClient) GetCourseDetails(ctx context.Context, courseID uint) (*CourseDetails, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/courses/%d", c.coursesAPIURL, courseID), nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("course with ID %d not found", courseID)
-	}
 
 	var course CourseDetails
-	if err := json.NewDecoder(resp.Body).Decode(&course); err != nil {
-		return nil, fmt.Errorf("error decoding course details: %v", err)
+	if err := c.courses.DoJSON(req, &course); err != nil {
+		if errors.Is(err, httpclient.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %d", domain.ErrCourseNotFound, courseID)
+		}
+		return nil, err
 	}
-
 	return &course, nil
 }
 
-func (c *HTTPClient) UpdateCourseAvailability(courseID int64) error {
-	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/courses/%d/availability", c.coursesAPIURL, courseID), nil)
-	if err != nil {
-		return err
+// maxBatchIDs es la cantidad de IDs por pedido a GET /users?ids= y GET
+// /courses?ids= (users-api acepta hasta 100)
+const maxBatchIDs = 100
+
+// GetUsersByIDs obtiene los usuarios con esos IDs, de a maxBatchIDs por pedido.
+// Los que no existen no están en el resultado.
+func (c *HTTPClient) GetUsersByIDs(ctx context.Context, userIDs []uint) (map[uint]domain.UserSummary, error) {
+	users := make(map[uint]domain.UserSummary, len(userIDs))
+	for start := 0; start < len(userIDs); start += maxBatchIDs {
+		var batch []domain.UserSummary
+		if err := c.getBatch(ctx, c.users, c.usersAPIURL+"/users", userIDs[start:min(start+maxBatchIDs, len(userIDs))], &batch); err != nil {
+			return nil, err
+		}
+		for _, user := range batch {
+			users[user.ID] = user
+		}
 	}
+	return users, nil
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return err
+// GetCoursesByIDs obtiene los cursos con esos IDs, de a maxBatchIDs por pedido.
+// Los que no existen no están en el resultado.
+func (c *HTTPClient) GetCoursesByIDs(ctx context.Context, courseIDs []uint) (map[uint]domain.CourseSummary, error) {
+	courses := make(map[uint]domain.CourseSummary, len(courseIDs))
+	for start := 0; start < len(courseIDs); start += maxBatchIDs {
+		var batch []domain.CourseSummary
+		if err := c.getBatch(ctx, c.courses, c.coursesAPIURL+"/courses", courseIDs[start:min(start+maxBatchIDs, len(courseIDs))], &batch); err != nil {
+			return nil, err
+		}
+		for _, course := range batch {
+			courses[course.ID] = course
+		}
 	}
-	defer resp.Body.Close()
+	return courses, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update course availability for course ID %d", courseID)
+func (c *HTTPClient) getBatch(ctx context.Context, client *httpclient.Client, url string, ids []uint, out any) error {
+	params := make([]string, len(ids))
+	for i, id := range ids {
+		params[i] = strconv.FormatUint(uint64(id), 10)
 	}
-
-	return nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"?ids="+strings.Join(params, ","), nil)
+	if err != nil {
+		return err
+	}
+	return client.DoJSON(req, out)
 }
@@ -0,0 +1,161 @@
+package rabbit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	domain "inscriptions-api/domain/inscriptions"
+	"log"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Valores por defecto del consumidor
+const (
+	defaultPrefetch   = 16
+	defaultRetryDelay = 2 * time.Second
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+type ConsumerConfig struct {
+	URI        string
+	QueueName  string
+	Prefetch   int           // Mensajes sin confirmar que el broker entrega por adelantado (QoS)
+	RetryDelay time.Duration // Espera antes de volver a aplicar un evento que falló
+	MinBackoff time.Duration // Espera inicial entre reconexiones
+	MaxBackoff time.Duration // Espera máxima entre reconexiones
+}
+
+// CourseEventHandler aplica un evento de courses-api
+type CourseEventHandler func(ctx context.Context, event domain.CourseEvent) error
+
+// Consumer consume los eventos de courses-api de a uno. Las dos réplicas de la
+// API consumen la misma cola, así que cada evento lo aplica una sola. Si
+// aplicar un evento falla (por ejemplo, porque MySQL no responde), lo reintenta
+// antes de pasar al siguiente.
+type Consumer struct {
+	config ConsumerConfig
+}
+
+func NewConsumer(config ConsumerConfig) *Consumer {
+	if config.Prefetch <= 0 {
+		config.Prefetch = defaultPrefetch
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+	return &Consumer{config: config}
+}
+
+// Run consume la cola hasta que se cancele ctx, reconectándose con backoff
+// exponencial si se pierde la conexión
+func (c *Consumer) Run(ctx context.Context, handler CourseEventHandler) {
+	delay := c.config.MinBackoff
+	for {
+		connected, err := c.consumeOnce(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = c.config.MinBackoff
+		}
+		log.Printf("Consumidor de la cola %s desconectado: %v. Reintentando en %v", c.config.QueueName, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > c.config.MaxBackoff {
+			delay = c.config.MaxBackoff
+		}
+	}
+}
+
+// consumeOnce conecta, declara la cola y consume hasta que se corte la conexión.
+// Indica si llegó a conectarse.
+func (c *Consumer) consumeOnce(ctx context.Context, handler CourseEventHandler) (bool, error) {
+	connection, err := amqp.Dial(c.config.URI)
+	if err != nil {
+		return false, fmt.Errorf("error getting Rabbit connection: %w", err)
+	}
+	defer connection.Close()
+
+	channel, err := connection.Channel()
+	if err != nil {
+		return false, fmt.Errorf("error creating Rabbit channel: %w", err)
+	}
+	// La declara igual que courses-api, así no importa cuál arranca primero
+	if _, err := channel.QueueDeclare(c.config.QueueName, true, false, false, false, nil); err != nil {
+		return false, fmt.Errorf("error declaring Rabbit queue: %w", err)
+	}
+	if err := channel.Qos(c.config.Prefetch, 0, false); err != nil {
+		return false, fmt.Errorf("error setting Rabbit QoS: %w", err)
+	}
+	deliveries, err := channel.Consume(c.config.QueueName, "", false, false, false, false, nil)
+	if err != nil {
+		return false, fmt.Errorf("error consuming Rabbit queue: %w", err)
+	}
+
+	log.Printf("Consumiendo eventos de cursos de la cola %s", c.config.QueueName)
+	return true, c.consume(ctx, deliveries, handler)
+}
+
+// consume procesa las entregas en orden hasta que se cierre el canal o se cancele ctx
+func (c *Consumer) consume(ctx context.Context, deliveries <-chan amqp.Delivery, handler CourseEventHandler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-deliveries:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			c.process(ctx, msg, handler)
+		}
+	}
+}
+
+// process aplica un evento y lo confirma. Un mensaje que no se puede leer se
+// descarta; uno que falla se reintenta hasta lograrlo, y si se cancela ctx
+// mientras tanto vuelve a la cola.
+func (c *Consumer) process(ctx context.Context, msg amqp.Delivery, handler CourseEventHandler) {
+	var event domain.CourseEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		log.Printf("Evento de curso inválido, se descarta: %v", err)
+		if err := msg.Reject(false); err != nil {
+			log.Printf("Error al descartar el mensaje: %v", err)
+		}
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := handler(ctx, event)
+		if err == nil {
+			if err := msg.Ack(false); err != nil {
+				// Se vuelve a entregar, y aplicarlo dos veces no cambia nada
+				log.Printf("Error al confirmar el evento %s: %v", event.EventID, err)
+			}
+			return
+		}
+		log.Printf("Intento %d de aplicar el evento %s fallido: %v", attempt, event.EventID, err)
+
+		select {
+		case <-ctx.Done():
+			if err := msg.Nack(false, true); err != nil {
+				log.Printf("Error al devolver el evento %s a la cola: %v", event.EventID, err)
+			}
+			return
+		case <-time.After(c.config.RetryDelay):
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package rabbit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+const defaultConfirmTimeout = 5 * time.Second
+
+var (
+	ErrNack           = errors.New("RabbitMQ rejected the message (nack)")
+	ErrConfirmTimeout = errors.New("timed out waiting for RabbitMQ confirmation")
+)
+
+type RabbitConfig struct {
+	URI            string
+	QueueName      string
+	ConfirmTimeout time.Duration // Tiempo máximo para esperar el ack del broker
+}
+
+// Rabbit publica los eventos de inscripciones en una cola durable, con
+// confirmaciones del broker. Se conecta al publicar por primera vez y, si la
+// conexión se pierde, vuelve a conectarse en la publicación siguiente.
+type Rabbit struct {
+	config RabbitConfig
+
+	mu         sync.Mutex
+	connection *amqp.Connection
+	channel    *amqp.Channel
+	confirms   chan amqp.Confirmation
+}
+
+func NewRabbit(config RabbitConfig) *Rabbit {
+	if config.ConfirmTimeout <= 0 {
+		config.ConfirmTimeout = defaultConfirmTimeout
+	}
+	return &Rabbit{config: config}
+}
+
+// Publish envía el evento como mensaje persistente y espera la confirmación del broker
+func (r *Rabbit) Publish(eventID string, eventType string, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channel == nil {
+		if err := r.connect(); err != nil {
+			return err
+		}
+	}
+
+	message := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    eventID,
+		Type:         eventType,
+		Timestamp:    time.Now().UTC(),
+		Body:         body,
+	}
+	if err := r.channel.Publish("", r.config.QueueName, false, false, message); err != nil {
+		r.reset()
+		return fmt.Errorf("error publishing to Rabbit: %w", err)
+	}
+
+	timeout := time.NewTimer(r.config.ConfirmTimeout)
+	defer timeout.Stop()
+
+	select {
+	case confirmation, ok := <-r.confirms:
+		if !ok {
+			r.reset()
+			return amqp.ErrClosed
+		}
+		if !confirmation.Ack {
+			return ErrNack
+		}
+		return nil
+	case <-timeout.C:
+		// Una confirmación tardía desincronizaría el canal, así que se descarta
+		r.reset()
+		return ErrConfirmTimeout
+	}
+}
+
+func (r *Rabbit) connect() error {
+	connection, err := amqp.Dial(r.config.URI)
+	if err != nil {
+		return fmt.Errorf("error getting Rabbit connection: %w", err)
+	}
+	channel, err := connection.Channel()
+	if err != nil {
+		connection.Close()
+		return fmt.Errorf("error creating Rabbit channel: %w", err)
+	}
+	if _, err := channel.QueueDeclare(r.config.QueueName, true, false, false, false, nil); err != nil {
+		connection.Close()
+		return fmt.Errorf("error declaring Rabbit queue: %w", err)
+	}
+	if err := channel.Confirm(false); err != nil {
+		connection.Close()
+		return fmt.Errorf("error enabling Rabbit confirmations: %w", err)
+	}
+
+	r.connection = connection
+	r.channel = channel
+	r.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+// reset cierra la conexión actual; la próxima publicación abre una nueva
+func (r *Rabbit) reset() {
+	if r.connection != nil {
+		r.connection.Close()
+	}
+	r.connection = nil
+	r.channel = nil
+	r.confirms = nil
+}
+
+// Close cierra la conexión con RabbitMQ
+func (r *Rabbit) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reset()
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	domain "inscriptions-api/domain/inscriptions"
+	"inscriptions-api/middleware"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permisos sobre las inscripciones: cada alumno maneja solo las suyas; los
+// administradores manejan todas, y el instructor de un curso maneja las de
+// ese curso.
+
+// canManageCourse indica si el usuario puede ver y administrar las
+// inscripciones y la lista de espera de un curso
+func (ctrl *Controller) canManageCourse(ctx context.Context, caller domain.Caller, courseID uint) (bool, error) {
+	if caller.Privileged() {
+		return true, nil
+	}
+	instructorID, err := ctrl.service.GetCourseInstructor(ctx, courseID)
+	if err != nil {
+		return false, err
+	}
+	return instructorID != 0 && instructorID == caller.UserID, nil
+}
+
+// canActFor indica si el usuario puede inscribir o dar de baja a userID en un curso
+func (ctrl *Controller) canActFor(ctx context.Context, caller domain.Caller, userID, courseID uint) (bool, error) {
+	if caller.UserID != 0 && caller.UserID == userID {
+		return true, nil
+	}
+	return ctrl.canManageCourse(ctx, caller, courseID)
+}
+
+// authorize responde 403 (o el error al consultar el curso) si el usuario no
+// tiene permiso. Devuelve false si ya respondió.
+func authorize(c *gin.Context, allowed bool, err error) bool {
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": domain.ErrForbidden.Error()})
+		return false
+	}
+	return true
+}
+
+// caller devuelve el usuario autenticado (ver middleware.Authenticate)
+func caller(c *gin.Context) domain.Caller {
+	caller, _ := middleware.CallerFrom(c)
+	return caller
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	domain "inscriptions-api/domain/inscriptions"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Límites de una importación
+const (
+	maxBulkRows     = 1000
+	maxBulkBodySize = 1 << 20
+)
+
+// exportHeader son las columnas del CSV de GET /inscriptions/export
+var exportHeader = []string{"id", "user_id", "course_id", "status", "created_at", "updated_at", "cancelled_at"}
+
+// BulkCreateInscriptions importa inscripciones (POST /inscriptions/bulk). El
+// cuerpo es un CSV (Content-Type: text/csv) con las columnas user_id y
+// course_id, o un arreglo JSON de {"user_id", "course_id"}. El parámetro mode
+// es partial (por defecto) o all_or_nothing. Responde el informe con el
+// resultado de cada fila: 201 si se crearon todas, 200 si en modo partial
+// fallaron algunas, y 422 si en modo all_or_nothing no se creó ninguna.
+func (ctrl *Controller) BulkCreateInscriptions(c *gin.Context) {
+	if !authorize(c, caller(c).Privileged(), nil) {
+		return
+	}
+
+	mode := c.DefaultQuery("mode", domain.BulkPartial)
+	if mode != domain.BulkPartial && mode != domain.BulkAllOrNothing {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid mode %q: use %s or %s", mode, domain.BulkPartial, domain.BulkAllOrNothing)})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkBodySize)
+	var (
+		rows []domain.BulkRow
+		err  error
+	)
+	if mediaType, _, _ := mime.ParseMediaType(c.ContentType()); mediaType == "text/csv" {
+		rows, err = parseBulkCSV(body)
+	} else {
+		err = c.ShouldBindJSON(&rows)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid format: %s", err.Error())})
+		return
+	}
+	if len(rows) == 0 || len(rows) > maxBulkRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An import must have between 1 and %d rows", maxBulkRows)})
+		return
+	}
+
+	report, err := ctrl.service.BulkCreateInscriptions(c.Request.Context(), rows, mode)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case report.Created == report.Total:
+		c.JSON(http.StatusCreated, report)
+	case mode == domain.BulkAllOrNothing:
+		c.JSON(http.StatusUnprocessableEntity, report)
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// parseBulkCSV lee las filas de un CSV con encabezado. Las columnas se buscan
+// por nombre, así que pueden venir en cualquier orden y con otras columnas. Un
+// ID que no es un número queda en cero, y el servicio informa la fila como
+// inválida.
+func parseBulkCSV(body io.Reader) ([]domain.BulkRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+	userColumn, courseColumn := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "user_id":
+			userColumn = i
+		case "course_id":
+			courseColumn = i
+		}
+	}
+	if userColumn < 0 || courseColumn < 0 {
+		return nil, errors.New("the CSV header must have user_id and course_id columns")
+	}
+
+	var rows []domain.BulkRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %w", err)
+		}
+		if len(rows) == maxBulkRows {
+			return nil, fmt.Errorf("an import must have at most %d rows", maxBulkRows)
+		}
+		rows = append(rows, domain.BulkRow{
+			UserID:   parseBulkID(record[userColumn]),
+			CourseID: parseBulkID(record[courseColumn]),
+		})
+	}
+}
+
+func parseBulkID(value string) uint {
+	id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// ExportInscriptions devuelve las inscripciones como CSV (GET
+// /inscriptions/export), escribiéndolas a medida que se leen de la base. Acepta
+// el mismo parámetro status que los listados; para exportar todas, status=all.
+func (ctrl *Controller) ExportInscriptions(c *gin.Context) {
+	if !authorize(c, caller(c).Privileged(), nil) {
+		return
+	}
+	statuses, ok := statusFilter(c)
+	if !ok {
+		return
+	}
+
+	// La respuesta empieza con el primer lote, así un error al leer el primero
+	// todavía se puede responder como JSON
+	writer := csv.NewWriter(c.Writer)
+	started := false
+	start := func() {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="inscriptions.csv"`)
+		c.Status(http.StatusOK)
+		writer.Write(exportHeader)
+		started = true
+	}
+	err := ctrl.service.ExportInscriptions(c.Request.Context(), statuses, func(inscriptions []domain.Inscription) error {
+		if !started {
+			start()
+		}
+		for _, inscription := range inscriptions {
+			writer.Write(exportRecord(inscription))
+		}
+		writer.Flush()
+		c.Writer.Flush()
+		return writer.Error()
+	})
+
+	switch {
+	case err != nil && !started:
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+	case err != nil:
+		// La respuesta ya empezó: el CSV queda cortado
+		log.Printf("failed to export inscriptions: %v", err)
+	case !started:
+		// Sin inscripciones, solo el encabezado
+		start()
+		writer.Flush()
+	}
+}
+
+func exportRecord(inscription domain.Inscription) []string {
+	cancelledAt := ""
+	if inscription.CancelledAt != nil {
+		cancelledAt = inscription.CancelledAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatUint(uint64(inscription.ID), 10),
+		strconv.FormatUint(uint64(inscription.UserID), 10),
+		strconv.FormatUint(uint64(inscription.CourseID), 10),
+		inscription.Status,
+		inscription.CreatedAt.UTC().Format(time.RFC3339),
+		inscription.UpdatedAt.UTC().Format(time.RFC3339),
+		cancelledAt,
+	}
+}
@@ -2,20 +2,34 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	domain "inscriptions-api/domain/inscriptions"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"httpclient"
 )
 
 type Service interface {
 	CreateInscription(ctx context.Context, userID, courseID uint) (*domain.Inscription, error)
-	GetInscriptions(ctx context.Context) ([]domain.Inscription, error)
-	GetInscriptionsByUser(ctx context.Context, userID uint) ([]domain.Inscription, error)
-	GetInscriptionsByCourse(ctx context.Context, courseID uint) ([]domain.Inscription, error)
+	CancelInscription(ctx context.Context, id uint) (*domain.Inscription, error)
+	CompleteInscription(ctx context.Context, id uint) (*domain.Inscription, error)
+	GetInscription(ctx context.Context, id uint) (*domain.Inscription, error)
+	ListInscriptions(ctx context.Context, query domain.InscriptionQuery) (*domain.InscriptionPage, error)
+	JoinWaitlist(ctx context.Context, userID, courseID uint) (*domain.WaitlistEntry, error)
+	LeaveWaitlist(ctx context.Context, userID, courseID uint) error
+	GetWaitlist(ctx context.Context, courseID uint) ([]domain.WaitlistEntry, error)
+	GetWaitlistEntry(ctx context.Context, userID, courseID uint) (*domain.WaitlistEntry, error)
+	PromoteWaitlist(ctx context.Context, courseID uint) ([]domain.Inscription, error)
+	GetCourseInstructor(ctx context.Context, courseID uint) (uint, error)
+	BulkCreateInscriptions(ctx context.Context, rows []domain.BulkRow, mode string) (*domain.BulkReport, error)
+	ExportInscriptions(ctx context.Context, statuses []string, fn func([]domain.Inscription) error) error
 }
 
 type Controller struct {
@@ -26,66 +40,417 @@ func NewController(service Service) *Controller {
 	return &Controller{service: service}
 }
 
+// CreateInscription inscribe en un curso al usuario del token. user_id solo
+// hace falta para inscribir a otro usuario, y eso lo pueden hacer los
+// administradores y el instructor del curso.
 func (ctrl *Controller) CreateInscription(c *gin.Context) {
 	var req struct {
-		UserID   uint `json:"user_id" binding:"required"`
+		UserID   uint `json:"user_id"`
 		CourseID uint `json:"course_id" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid format: %s", err.Error())})
 		return
 	}
+	caller := caller(c)
+	if req.UserID == 0 {
+		req.UserID = caller.UserID
+	}
+	allowed, err := ctrl.canActFor(c.Request.Context(), caller, req.UserID, req.CourseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
 
 	inscription, err := ctrl.service.CreateInscription(c.Request.Context(), req.UserID, req.CourseID)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err.Error() == "user does not exist" || err.Error() == "course does not exist" {
-			status = http.StatusNotFound
-		}
-		c.JSON(status, gin.H{"error": err.Error()})
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	// Una inscripción pendiente se completa en segundo plano
+	if inscription.Status == domain.StatusPending {
+		c.JSON(http.StatusAccepted, inscription)
+		return
+	}
 	c.JSON(http.StatusCreated, inscription)
 }
 
-func (ctrl *Controller) GetInscriptions(c *gin.Context) {
-	inscriptions, err := ctrl.service.GetInscriptions(c.Request.Context())
+// CancelInscription da de baja una inscripción (DELETE /inscriptions/:id o
+// POST /inscriptions/:id/cancel) y libera su lugar
+func (ctrl *Controller) CancelInscription(c *gin.Context) {
+	ctrl.changeStatus(c, ctrl.service.CancelInscription, false)
+}
+
+// CompleteInscription marca una inscripción como completada. No la puede
+// marcar el alumno, solo quien administra el curso.
+func (ctrl *Controller) CompleteInscription(c *gin.Context) {
+	ctrl.changeStatus(c, ctrl.service.CompleteInscription, true)
+}
+
+func (ctrl *Controller) changeStatus(c *gin.Context, change func(ctx context.Context, id uint) (*domain.Inscription, error), managersOnly bool) {
+	current, ok := ctrl.authorizedInscription(c, managersOnly)
+	if !ok {
+		return
+	}
+
+	inscription, err := change(c.Request.Context(), current.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error: %s", err.Error())})
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, inscription)
+}
+
+func (ctrl *Controller) GetInscription(c *gin.Context) {
+	inscription, ok := ctrl.authorizedInscription(c, false)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, inscriptions)
+	c.JSON(http.StatusOK, inscription)
+}
+
+// authorizedInscription busca la inscripción de la ruta y verifica que el
+// usuario la pueda manejar: el propio alumno (salvo managersOnly) o quien
+// administra el curso
+func (ctrl *Controller) authorizedInscription(c *gin.Context, managersOnly bool) (*domain.Inscription, bool) {
+	id, ok := inscriptionID(c)
+	if !ok {
+		return nil, false
+	}
+
+	inscription, err := ctrl.service.GetInscription(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	var allowed bool
+	if managersOnly {
+		allowed, err = ctrl.canManageCourse(c.Request.Context(), caller(c), inscription.CourseID)
+	} else {
+		allowed, err = ctrl.canActFor(c.Request.Context(), caller(c), inscription.UserID, inscription.CourseID)
+	}
+	if !authorize(c, allowed, err) {
+		return nil, false
+	}
+	return inscription, true
+}
+
+// GetInscriptions lista todas las inscripciones a los administradores; al
+// resto le lista solo las propias. Como los demás listados, acepta los
+// parámetros de listQuery.
+func (ctrl *Controller) GetInscriptions(c *gin.Context) {
+	query, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	if caller := caller(c); !caller.Privileged() {
+		query.UserID = caller.UserID
+	}
+	ctrl.listInscriptions(c, query)
 }
 
 func (ctrl *Controller) GetInscriptionsByUser(c *gin.Context) {
-	userIDParam := strings.TrimSpace(c.Param("userID"))
-	userID, err := strconv.ParseUint(userIDParam, 10, 32)
+	userID, ok := pathID(c, "userID", "user")
+	if !ok {
+		return
+	}
+	if caller := caller(c); !caller.Privileged() && caller.UserID != userID {
+		authorize(c, false, nil)
+		return
+	}
+	query, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	query.UserID = userID
+	ctrl.listInscriptions(c, query)
+}
+
+func (ctrl *Controller) GetInscriptionsByCourse(c *gin.Context) {
+	courseID, ok := pathID(c, "courseID", "course")
+	if !ok {
+		return
+	}
+	allowed, err := ctrl.canManageCourse(c.Request.Context(), caller(c), courseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
+	query, ok := listQuery(c)
+	if !ok {
+		return
+	}
+	query.CourseID = courseID
+	ctrl.listInscriptions(c, query)
+}
+
+func (ctrl *Controller) listInscriptions(c *gin.Context, query domain.InscriptionQuery) {
+	page, err := ctrl.service.ListInscriptions(c.Request.Context(), query)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid user ID: %s", userIDParam)})
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Enlaces a las páginas vecinas, conservando los filtros
+	if int64(query.Offset+query.Limit) < page.NumFound {
+		page.Next = pageLink(c.Request.URL, query.Offset+query.Limit, query.Limit)
+	}
+	if query.Offset > 0 {
+		page.Prev = pageLink(c.Request.URL, max(query.Offset-query.Limit, 0), query.Limit)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// JoinWaitlist anota a un usuario en la lista de espera de un curso lleno.
+// Como en CreateInscription, sin user_id se anota al usuario del token.
+func (ctrl *Controller) JoinWaitlist(c *gin.Context) {
+	courseID, ok := pathID(c, "courseID", "course")
+	if !ok {
+		return
+	}
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid format: %s", err.Error())})
+		return
+	}
+	caller := caller(c)
+	if req.UserID == 0 {
+		req.UserID = caller.UserID
+	}
+	allowed, err := ctrl.canActFor(c.Request.Context(), caller, req.UserID, courseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
+
+	entry, err := ctrl.service.JoinWaitlist(c.Request.Context(), req.UserID, courseID)
+	if err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// LeaveWaitlist saca a un usuario de la lista de espera de un curso
+func (ctrl *Controller) LeaveWaitlist(c *gin.Context) {
+	courseID, ok := pathID(c, "courseID", "course")
+	if !ok {
+		return
+	}
+	userID, ok := pathID(c, "userID", "user")
+	if !ok {
+		return
+	}
+	allowed, err := ctrl.canActFor(c.Request.Context(), caller(c), userID, courseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
+
+	if err := ctrl.service.LeaveWaitlist(c.Request.Context(), userID, courseID); err != nil {
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
 		return
 	}
+	c.Status(http.StatusNoContent)
+}
 
-	inscriptions, err := ctrl.service.GetInscriptionsByUser(c.Request.Context(), uint(userID))
+// GetWaitlist devuelve la lista de espera de un curso en orden
+func (ctrl *Controller) GetWaitlist(c *gin.Context) {
+	courseID, ok := pathID(c, "courseID", "course")
+	if !ok {
+		return
+	}
+	allowed, err := ctrl.canManageCourse(c.Request.Context(), caller(c), courseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
+
+	waitlist, err := ctrl.service.GetWaitlist(c.Request.Context(), courseID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, inscriptions)
+	c.JSON(http.StatusOK, waitlist)
 }
 
-func (ctrl *Controller) GetInscriptionsByCourse(c *gin.Context) {
-	courseIDParam := c.Param("courseID")
-	courseID, err := strconv.ParseUint(courseIDParam, 10, 32)
+// GetWaitlistEntry devuelve la posición de un usuario en la lista de espera de un curso
+func (ctrl *Controller) GetWaitlistEntry(c *gin.Context) {
+	courseID, ok := pathID(c, "courseID", "course")
+	if !ok {
+		return
+	}
+	userID, ok := pathID(c, "userID", "user")
+	if !ok {
+		return
+	}
+	allowed, err := ctrl.canActFor(c.Request.Context(), caller(c), userID, courseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
+
+	entry, err := ctrl.service.GetWaitlistEntry(c.Request.Context(), userID, courseID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid course ID: %s", courseIDParam)})
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, entry)
+}
 
-	inscriptions, err := ctrl.service.GetInscriptionsByCourse(c.Request.Context(), uint(courseID))
+// PromoteWaitlist inscribe a los primeros de la lista de espera en los lugares
+// libres de un curso. Lo llama courses-api al aumentar la capacidad.
+func (ctrl *Controller) PromoteWaitlist(c *gin.Context) {
+	courseID, ok := pathID(c, "courseID", "course")
+	if !ok {
+		return
+	}
+	allowed, err := ctrl.canManageCourse(c.Request.Context(), caller(c), courseID)
+	if !authorize(c, allowed, err) {
+		return
+	}
+
+	promoted, err := ctrl.service.PromoteWaitlist(c.Request.Context(), courseID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(statusFor(err), gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, inscriptions)
+	c.JSON(http.StatusOK, promoted)
+}
+
+func pathID(c *gin.Context, param string, name string) (uint, bool) {
+	idParam := strings.TrimSpace(c.Param(param))
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s ID: %s", name, idParam)})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func inscriptionID(c *gin.Context) (uint, bool) {
+	return pathID(c, "id", "inscription")
+}
+
+// statusFilter lee el parámetro status: una lista de estados separados por
+// comas, o "all". Sin él se listan las inscripciones que ocupan un lugar, así
+// que las bajas no cuentan como inscriptos.
+func statusFilter(c *gin.Context) ([]string, bool) {
+	param := strings.TrimSpace(c.Query("status"))
+	switch param {
+	case "":
+		return domain.SeatHoldingStatuses, true
+	case "all":
+		return nil, true
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(param, ",") {
+		status = strings.TrimSpace(status)
+		if !domain.ValidStatus(status) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", domain.ErrInvalidStatus, status)})
+			return nil, false
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, true
+}
+
+// Tamaño de página de los listados
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// listQuery lee los parámetros de los listados: status (ver statusFilter),
+// from y to (fecha de creación, RFC 3339 o AAAA-MM-DD, inclusive), offset,
+// limit y expand (course, user o ambos separados por comas)
+func listQuery(c *gin.Context) (domain.InscriptionQuery, bool) {
+	var query domain.InscriptionQuery
+	statuses, ok := statusFilter(c)
+	if !ok {
+		return query, false
+	}
+	query.Statuses = statuses
+
+	if query.CreatedFrom, ok = dateParam(c, "from", false); !ok {
+		return query, false
+	}
+	if query.CreatedTo, ok = dateParam(c, "to", true); !ok {
+		return query, false
+	}
+	if query.CreatedFrom != nil && query.CreatedTo != nil && query.CreatedFrom.After(*query.CreatedTo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": domain.ErrInvalidDateRange.Error()})
+		return query, false
+	}
+
+	var err error
+	if query.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0")); err != nil || query.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid offset: %s", c.Query("offset"))})
+		return query, false
+	}
+	query.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)))
+	if err != nil || query.Limit <= 0 || query.Limit > maxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid limit: %s (1 to %d)", c.Query("limit"), maxPageSize)})
+		return query, false
+	}
+
+	if param := strings.TrimSpace(c.Query("expand")); param != "" {
+		for _, field := range strings.Split(param, ",") {
+			switch strings.TrimSpace(field) {
+			case domain.ExpandCourse:
+				query.ExpandCourse = true
+			case domain.ExpandUser:
+				query.ExpandUser = true
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid expand: %s (use %s or %s)", field, domain.ExpandCourse, domain.ExpandUser)})
+				return query, false
+			}
+		}
+	}
+	return query, true
+}
+
+// dateParam lee una fecha en RFC 3339 o AAAA-MM-DD (en UTC). Con endOfDay, una
+// fecha sin hora abarca todo ese día.
+func dateParam(c *gin.Context, name string, endOfDay bool) (*time.Time, bool) {
+	param := strings.TrimSpace(c.Query(name))
+	if param == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, param); err == nil {
+		return &t, true
+	}
+	t, err := time.Parse(time.DateOnly, param)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s: %s (use RFC 3339 or YYYY-MM-DD)", name, param)})
+		return nil, false
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, true
+}
+
+// pageLink devuelve la URL relativa del mismo listado con otro offset
+func pageLink(requestURL *url.URL, offset int, limit int) string {
+	params := requestURL.Query()
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("limit", strconv.Itoa(limit))
+	return requestURL.Path + "?" + params.Encode()
+}
+
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInscriptionNotFound), errors.Is(err, domain.ErrNotWaitlisted),
+		errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrCourseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidTransition), errors.Is(err, domain.ErrAlreadyEnrolled),
+		errors.Is(err, domain.ErrAlreadyWaitlisted), errors.Is(err, domain.ErrSeatsAvailable),
+		errors.Is(err, domain.ErrCourseFull):
+		return http.StatusConflict
+	// users-api o courses-api no responden
+	case errors.Is(err, httpclient.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
 }
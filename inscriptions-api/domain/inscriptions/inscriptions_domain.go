@@ -1,7 +1,261 @@
 package domain
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrCourseFull          = errors.New("course has no seats left")
+	ErrAlreadyEnrolled     = errors.New("inscription already exists")
+	ErrInscriptionNotFound = errors.New("inscription not found")
+	ErrInvalidTransition   = errors.New("inscription cannot change to the requested status")
+	ErrInvalidStatus       = errors.New("invalid inscription status")
+	ErrAlreadyWaitlisted   = errors.New("user is already on the waitlist")
+	ErrNotWaitlisted       = errors.New("user is not on the waitlist")
+	ErrSeatsAvailable      = errors.New("course has seats left, enroll instead")
+
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+
+	ErrForbidden = errors.New("not allowed to manage this user's inscriptions")
+
+	ErrUserNotFound   = errors.New("user does not exist")
+	ErrCourseNotFound = errors.New("course does not exist")
+
+	ErrInvalidDateRange = errors.New("from must not be after to")
+
+	ErrInvalidBulkRow    = errors.New("user_id and course_id must be positive integers")
+	ErrDuplicatedBulkRow = errors.New("row is repeated in the import")
+	ErrBulkRolledBack    = errors.New("not created because another row failed")
+
+	// Inscripciones repetidas que impiden crear el índice único por usuario y curso
+	ErrDuplicateInscriptions = errors.New("duplicate inscriptions must be resolved before migrating")
+)
+
+// Estados de una inscripción. Las pendientes, activas y completadas ocupan un
+// lugar del curso; las canceladas lo liberan.
+const (
+	StatusPending   = "pending"
+	StatusActive    = "active"
+	StatusCancelled = "cancelled"
+	StatusCompleted = "completed"
+)
+
+// SeatHoldingStatuses son los estados que ocupan un lugar. Es lo que se lista
+// si no se pide un estado, y lo que courses-api cuenta como inscriptos.
+var SeatHoldingStatuses = []string{StatusPending, StatusActive, StatusCompleted}
+
+// transitions indica a qué estados puede pasar una inscripción desde cada uno
+var transitions = map[string][]string{
+	StatusPending: {StatusActive, StatusCancelled},
+	StatusActive:  {StatusCancelled, StatusCompleted},
+}
+
+// CanTransition indica si una inscripción puede pasar del estado from al estado to
+func CanTransition(from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidStatus indica si status es uno de los estados de una inscripción
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusActive, StatusCancelled, StatusCompleted:
+		return true
+	}
+	return false
+}
+
 type Inscription struct {
-	ID       uint `json:"id"`
+	ID          uint       `json:"id"`
+	UserID      uint       `json:"user_id"`
+	CourseID    uint       `json:"course_id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	Version     uint64     `json:"version"` // Versión del último evento publicado sobre la inscripción
+
+	// Solo con expand=course o expand=user. Quedan vacíos si el curso o el
+	// usuario ya no existen.
+	Course *CourseSummary `json:"course,omitempty"`
+	User   *UserSummary   `json:"user,omitempty"`
+}
+
+// CourseSummary son los datos de courses-api que se agregan a una inscripción
+type CourseSummary struct {
+	ID           uint   `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Category     string `json:"category"`
+	InstructorID uint   `json:"instructor_id"`
+	Available    bool   `json:"available"`
+}
+
+// UserSummary son los datos de users-api que se agregan a una inscripción
+type UserSummary struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	UserType string `json:"user_type"`
+}
+
+// Valores del parámetro expand de los listados
+const (
+	ExpandCourse = "course"
+	ExpandUser   = "user"
+)
+
+// InscriptionQuery filtra y pagina un listado de inscripciones. Los filtros en
+// cero no se aplican.
+type InscriptionQuery struct {
+	UserID      uint
+	CourseID    uint
+	Statuses    []string
+	CreatedFrom *time.Time // Inclusive
+	CreatedTo   *time.Time // Inclusive
+	Offset      int
+	Limit       int
+
+	ExpandCourse bool // Agrega los datos de cada curso
+	ExpandUser   bool // Agrega los datos de cada usuario
+}
+
+// InscriptionPage es una página de un listado, ordenado por ID
+type InscriptionPage struct {
+	Results  []Inscription `json:"results"`
+	NumFound int64         `json:"num_found"` // Total de inscripciones que cumplen los filtros
+	Offset   int           `json:"offset"`
+	Limit    int           `json:"limit"`
+	Next     string        `json:"next"` // Enlace a la página siguiente (vacío si es la última)
+	Prev     string        `json:"prev"` // Enlace a la página anterior (vacío si es la primera)
+}
+
+// WaitlistEntry es el lugar de un alumno en la lista de espera de un curso
+type WaitlistEntry struct {
+	UserID    uint      `json:"user_id"`
+	CourseID  uint      `json:"course_id"`
+	Position  int       `json:"position"` // Empieza en 1
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Modos de una importación de inscripciones: con partial se crean las filas
+// válidas aunque otras fallen; con all_or_nothing, si falla una no se crea ninguna.
+const (
+	BulkPartial      = "partial"
+	BulkAllOrNothing = "all_or_nothing"
+)
+
+// Resultados de una fila de una importación
+const (
+	BulkCreated = "created"
+	BulkFailed  = "failed"
+	BulkSkipped = "skipped" // Válida, pero all_or_nothing no la creó porque falló otra
+)
+
+// BulkRow es una fila de una importación de inscripciones
+type BulkRow struct {
 	UserID   uint `json:"user_id"`
 	CourseID uint `json:"course_id"`
 }
+
+// BulkResult es el resultado de una fila de una importación
+type BulkResult struct {
+	Row           int    `json:"row"` // Empieza en 1, sin contar el encabezado del CSV
+	UserID        uint   `json:"user_id"`
+	CourseID      uint   `json:"course_id"`
+	Status        string `json:"status"`
+	InscriptionID uint   `json:"inscription_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// BulkReport es el informe de una importación, con el resultado de cada fila
+type BulkReport struct {
+	Mode    string       `json:"mode"`
+	Total   int          `json:"total"`
+	Created int          `json:"created"`
+	Failed  int          `json:"failed"`
+	Results []BulkResult `json:"results"`
+}
+
+// Tipos de los eventos que publica la API. courses-api cuenta los inscriptos
+// de cada curso con enrollment.created (la inscripción quedó activa, también
+// al salir de la lista de espera) y enrollment.cancelled (se dio de baja).
+// enrollment.snapshot es el estado actual de una inscripción, con su versión
+// sin cambiar, y se publica cuando courses-api pide las de un curso.
+const (
+	EventWaitlistPromoted    = "waitlist.promoted"
+	EventEnrollmentCreated   = "enrollment.created"
+	EventEnrollmentCancelled = "enrollment.cancelled"
+	EventEnrollmentSnapshot  = "enrollment.snapshot"
+)
+
+// Tipos de los eventos de courses-api que consume la API.
+// course.capacity_increased promueve la lista de espera del curso y
+// course.enrollments_requested publica sus inscripciones como enrollment.snapshot.
+const (
+	EventCapacityIncreased    = "course.capacity_increased"
+	EventEnrollmentsRequested = "course.enrollments_requested"
+)
+
+// Paso de la saga de inscripción que queda pendiente al iniciarla. Reservar el
+// lugar y guardar la inscripción (pendiente) se hacen en la misma transacción
+// que crea la saga, así que no tienen paso propio: si fallan, no queda nada que
+// retomar. publish_event activa la inscripción y guarda el evento en el outbox,
+// y se reintenta hasta lograrlo.
+const StepPublishEvent = "publish_event"
+
+// Estados de la saga de inscripción
+const (
+	SagaRunning     = "running"
+	SagaCompleted   = "completed"
+	SagaCompensated = "compensated"
+)
+
+// Event es lo que se publica en RabbitMQ cuando cambia una inscripción, para
+// que otros servicios reaccionen (por ejemplo, avisarle al alumno)
+type Event struct {
+	EventID       string    `json:"event_id"`
+	Type          string    `json:"type"`
+	InscriptionID uint      `json:"inscription_id"`
+	UserID        uint      `json:"user_id"`
+	CourseID      uint      `json:"course_id"`
+	Version       uint64    `json:"version"`          // Versión de la inscripción después del cambio
+	Status        string    `json:"status,omitempty"` // Estado de la inscripción, en enrollment.snapshot
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// CourseEvent es un evento de courses-api, que llega por la cola courses_events
+type CourseEvent struct {
+	EventID    string    `json:"event_id"`
+	Type       string    `json:"type"`
+	CourseID   uint      `json:"course_id"`
+	Capacity   int       `json:"capacity"` // Capacidad nueva, en course.capacity_increased
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// IdempotentResponse es lo guardado para una Idempotency-Key ya usada
+type IdempotentResponse struct {
+	Fingerprint string
+	StatusCode  int // 0 si el pedido original no terminó
+	Body        []byte
+}
+
+// Rol de administrador en el claim user_type del token. users-api solo lo
+// asigna a pedido de otro administrador.
+const RoleAdmin = "administrador"
+
+// Caller es el usuario autenticado que hace el pedido
+type Caller struct {
+	UserID uint
+	Role   string
+}
+
+// Privileged indica si puede ver y administrar las inscripciones de cualquiera
+func (c Caller) Privileged() bool {
+	return c.Role == RoleAdmin
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	dao "inscriptions-api/DAOs/inscriptions"
 	"inscriptions-api/clients"
+	"inscriptions-api/clients/rabbit"
 	controller "inscriptions-api/controllers/inscriptions"
+	"inscriptions-api/middleware"
 	repositories "inscriptions-api/repositories/inscriptions"
 	router "inscriptions-api/router/inscriptions"
+	"inscriptions-api/services/events"
 	service "inscriptions-api/services/inscriptions"
 	"log"
 	"os"
 	"time"
 
 	"gorm.io/gorm"
+	"httpclient"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +34,7 @@ func main() {
 	httpClient := clients.NewHTTPClient(
 		getEnv("USERS_API_URL", "http://localhost:8083"),
 		getEnv("COURSES_API_URL", "http://localhost:8080"), // Asegúrate de que esta URL sea correcta
+		httpclient.Config{},
 	)
 
 	// Inicialización de DAO, repositorio, servicio y controlador.
@@ -36,9 +43,45 @@ func main() {
 	inscriptionService := service.NewService(inscriptionRepository, httpClient)
 	inscriptionController := controller.NewController(inscriptionService)
 
+	// Las inscripciones que una réplica dejó a medias se completan o se deshacen
+	go inscriptionService.RunRecovery(context.Background())
+
+	// Los eventos (por ejemplo, alumnos que pasan de la lista de espera a
+	// inscriptos) se guardan en la base y se publican en RabbitMQ en segundo plano
+	if uri := os.Getenv("RABBITMQ_URI"); uri != "" {
+		publisher := rabbit.NewRabbit(rabbit.RabbitConfig{
+			URI:       uri,
+			QueueName: getEnv("EVENTS_QUEUE", "inscriptions_events"),
+		})
+		defer publisher.Close()
+		go events.NewRelay(inscriptionRepository, publisher, events.Config{}).Run(context.Background())
+
+		// Los eventos de courses-api: aumentos de capacidad, que promueven la
+		// lista de espera, y pedidos de las inscripciones de un curso
+		consumer := rabbit.NewConsumer(rabbit.ConsumerConfig{
+			URI:       uri,
+			QueueName: getEnv("COURSES_EVENTS_QUEUE", "courses_events"),
+		})
+		go consumer.Run(context.Background(), inscriptionService.HandleCourseEvent)
+	} else {
+		log.Println("RABBITMQ_URI no configurada: los eventos quedan pendientes en la base de datos y no se consumen los de courses-api")
+	}
+
 	// Configuración del router.
 	r := gin.Default()
-	router.MapRoutes(r, inscriptionController)
+	idempotencyTTL, err := time.ParseDuration(getEnv("IDEMPOTENCY_TTL", "24h"))
+	if err != nil {
+		log.Fatalf("IDEMPOTENCY_TTL inválido: %v", err)
+	}
+	idempotencyLease, err := time.ParseDuration(getEnv("IDEMPOTENCY_LEASE", "1m"))
+	if err != nil {
+		log.Fatalf("IDEMPOTENCY_LEASE inválido: %v", err)
+	}
+	go cleanIdempotencyKeys(inscriptionRepository, time.Hour)
+	// La clave JWT es la misma con la que users-api firma los tokens
+	jwtSecret := getEnv("JWT_SECRET", "ThisIsAnExampleJWTKey!")
+	router.MapRoutes(r, inscriptionController, middleware.Authenticate(jwtSecret), middleware.Idempotency(inscriptionRepository, idempotencyTTL, idempotencyLease))
+	r.GET("/debug/vars", gin.WrapH(expvar.Handler())) // Métricas de la publicación de eventos
 
 	// Asegúrate de que la aplicación use el puerto correcto
 	port := getEnv("PORT", "8081")
@@ -49,6 +92,20 @@ func main() {
 	}
 }
 
+// cleanIdempotencyKeys borra periódicamente las claves de idempotencia vencidas
+func cleanIdempotencyKeys(repository *repositories.InscriptionRepository, interval time.Duration) {
+	for range time.Tick(interval) {
+		deleted, err := repository.DeleteExpiredIdempotencyKeys(context.Background())
+		if err != nil {
+			log.Printf("Error al borrar claves de idempotencia vencidas: %v", err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Se borraron %d claves de idempotencia vencidas", deleted)
+		}
+	}
+}
+
 func connectWithRetry(attempts int, sleep time.Duration) (*gorm.DB, error) {
 	for i := 0; i < attempts; i++ {
 		db, err := repositories.Connect()
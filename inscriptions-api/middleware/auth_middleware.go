@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"fmt"
+	domain "inscriptions-api/domain/inscriptions"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// CallerKey es la clave del contexto donde Authenticate guarda el domain.Caller
+const CallerKey = "caller"
+
+// Authenticate exige un token firmado con la clave compartida por users-api y
+// guarda en el contexto quién hace el pedido. Los permisos sobre cada
+// inscripción los decide el controlador.
+func Authenticate(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization token required"})
+			return
+		}
+
+		token, err := jwt.Parse(strings.TrimPrefix(header, "Bearer "), signingKey(secret))
+		if err != nil || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+		if expired(claims) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+			return
+		}
+
+		userID, _ := claims["user_id"].(float64)
+		role, _ := claims["user_type"].(string)
+		if userID <= 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			return
+		}
+
+		c.Set(CallerKey, domain.Caller{UserID: uint(userID), Role: role})
+		c.Next()
+	}
+}
+
+// CallerFrom devuelve el usuario que guardó Authenticate en el contexto
+func CallerFrom(c *gin.Context) (domain.Caller, bool) {
+	value, ok := c.Get(CallerKey)
+	if !ok {
+		return domain.Caller{}, false
+	}
+	caller, ok := value.(domain.Caller)
+	return caller, ok
+}
+
+// expired revisa el claim expiration_date que pone users-api, que no es el
+// exp estándar y por eso jwt.Parse no lo valida. Un token sin vencimiento, o
+// con uno que no se puede leer, se trata como vencido.
+func expired(claims jwt.MapClaims) bool {
+	value, ok := claims["expiration_date"].(string)
+	if !ok {
+		return true
+	}
+	expiration, err := time.Parse(time.RFC3339Nano, value)
+	return err != nil || time.Now().After(expiration)
+}
+
+// signingKey verifica que el token esté firmado con HMAC y devuelve la clave compartida
+func signingKey(secret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(secret), nil
+	}
+}
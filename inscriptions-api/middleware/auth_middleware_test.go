@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"inscriptions-api/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("error signing token: %v", err)
+	}
+	return token
+}
+
+// authRouter arma un GET /whoami que devuelve el usuario autenticado
+func authRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", middleware.Authenticate(testSecret), func(c *gin.Context) {
+		caller, _ := middleware.CallerFrom(c)
+		c.JSON(http.StatusOK, caller)
+	})
+	return router
+}
+
+func whoami(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticateSetsCaller(t *testing.T) {
+	token := signToken(t, testSecret, jwt.MapClaims{
+		"user_id":         7,
+		"user_type":       "alumno",
+		"expiration_date": time.Now().UTC().Add(time.Hour),
+	})
+
+	w := whoami(authRouter(), token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if body := w.Body.String(); body != `{"UserID":7,"Role":"alumno"}` {
+		t.Errorf("unexpected caller %s", body)
+	}
+}
+
+func TestAuthenticateRejectsInvalidTokens(t *testing.T) {
+	valid := jwt.MapClaims{"user_id": 7, "user_type": "alumno"}
+	tests := map[string]string{
+		"missing":      "",
+		"wrong secret": signToken(t, "another-secret", valid),
+		"expired": signToken(t, testSecret, jwt.MapClaims{
+			"user_id":         7,
+			"user_type":       "alumno",
+			"expiration_date": time.Now().UTC().Add(-time.Minute),
+		}),
+		"without user": signToken(t, testSecret, jwt.MapClaims{
+			"user_type":       "alumno",
+			"expiration_date": time.Now().UTC().Add(time.Hour),
+		}),
+		"without expiration": signToken(t, testSecret, valid),
+		"unparsable expiration": signToken(t, testSecret, jwt.MapClaims{
+			"user_id":         7,
+			"user_type":       "alumno",
+			"expiration_date": "mañana",
+		}),
+		"numeric expiration": signToken(t, testSecret, jwt.MapClaims{
+			"user_id":         7,
+			"user_type":       "alumno",
+			"expiration_date": time.Now().Add(time.Hour).Unix(),
+		}),
+	}
+
+	router := authRouter()
+	for name, token := range tests {
+		if w := whoami(router, token); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, w.Code)
+		}
+	}
+}
+
+func TestAuthenticateRejectsServiceTokens(t *testing.T) {
+	// Ninguna API usa ya un rol de servicio: un token sin usuario no pasa aunque lo pida
+	token := signToken(t, testSecret, jwt.MapClaims{
+		"user_type":       "servicio",
+		"username":        "courses-api",
+		"expiration_date": time.Now().UTC().Add(time.Hour),
+	})
+	if w := whoami(authRouter(), token); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected tokens without user to be rejected, got %d", w.Code)
+	}
+}
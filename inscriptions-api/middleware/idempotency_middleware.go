@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	domain "inscriptions-api/domain/inscriptions"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	ReplayedHeader    = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 128
+)
+
+// IdempotencyStore guarda las claves y las respuestas de los pedidos idempotentes
+type IdempotencyStore interface {
+	ClaimIdempotencyKey(ctx context.Context, key string, fingerprint string, lease time.Duration, ttl time.Duration) (*domain.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, key string, statusCode int, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// Idempotency hace que los pedidos con el encabezado Idempotency-Key se
+// procesen una sola vez: si el frontend o nginx reintentan el pedido, se
+// devuelve la respuesta original, con el encabezado Idempotent-Replayed.
+// Las claves son de cada usuario, así que dos usuarios pueden usar la misma.
+// Las respuestas se guardan durante ttl; las de error del servidor no se
+// guardan, así que el pedido se puede reintentar. Mientras se procesa, la
+// clave queda reservada durante lease: si la réplica se cae sin responder, un
+// reintento la retoma cuando vence, sin esperar a ttl. Sin el encabezado, el
+// pedido se procesa normalmente.
+func Idempotency(store IdempotencyStore, ttl time.Duration, lease time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		// El usuario entra en la huella: otro usuario con la misma clave no
+		// recibe la respuesta guardada. También los parámetros, como el modo
+		// de una importación.
+		caller, _ := CallerFrom(c)
+		fingerprint := requestFingerprint(caller, c.Request.Method, c.Request.URL.RequestURI(), body)
+		key = fmt.Sprintf("%d:%s", caller.UserID, key)
+
+		ctx := c.Request.Context()
+		previous, err := store.ClaimIdempotencyKey(ctx, key, fingerprint, lease, ttl)
+		switch {
+		case errors.Is(err, domain.ErrIdempotencyKeyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		case previous != nil:
+			replay(c, previous, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// La respuesta se guarda aunque el cliente ya no espere, para el próximo reintento
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := store.ReleaseIdempotencyKey(ctx, key); err != nil {
+				log.Printf("failed to release idempotency key: %v", err)
+			}
+			return
+		}
+		if err := store.SaveIdempotentResponse(ctx, key, status, recorder.body.Bytes()); err != nil {
+			log.Printf("failed to save idempotent response: %v", err)
+		}
+	}
+}
+
+// replay devuelve la respuesta guardada para la clave, si el pedido es el mismo
+func replay(c *gin.Context, previous *domain.IdempotentResponse, fingerprint string) {
+	if previous.Fingerprint != fingerprint {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": domain.ErrIdempotencyKeyReused.Error()})
+		return
+	}
+	if previous.StatusCode == 0 {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": domain.ErrIdempotencyKeyInProgress.Error()})
+		return
+	}
+	c.Header(ReplayedHeader, "true")
+	c.Data(previous.StatusCode, "application/json; charset=utf-8", previous.Body)
+	c.Abort()
+}
+
+func requestFingerprint(caller domain.Caller, method string, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(fmt.Sprintf("%s:%d\n", caller.Role, caller.UserID)))
+	hash.Write([]byte(method + " " + uri + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder copia el cuerpo de la respuesta mientras se escribe
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
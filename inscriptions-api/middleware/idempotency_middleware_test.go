@@ -0,0 +1,200 @@
+package middleware_test
+
+import (
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"inscriptions-api/middleware"
+	repositories "inscriptions-api/repositories/inscriptions"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testUserHeader lleva el usuario que hace el pedido, en lugar del token
+const testUserHeader = "X-Test-User"
+
+// crashStatus hace que el handler entre en pánico, como una réplica que se cae
+// antes de responder: la clave queda reservada sin respuesta
+const crashStatus = -1
+
+// newRouter arma un POST /inscriptions que cuenta cuántas veces se procesa y
+// responde con status
+func newRouter(t *testing.T, ttl time.Duration, lease time.Duration, status *atomic.Int32, handled *atomic.Int32) *gin.Engine {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "inscriptions.db") + "?_busy_timeout=10000&_txlock=immediate&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), TranslateError: true})
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	if err := repositories.Migrate(db); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+	store := repositories.NewInscriptionRepository(dao.NewInscriptionDAO(db))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.RecoveryWithWriter(io.Discard))
+	authenticate := func(c *gin.Context) {
+		userID, _ := strconv.Atoi(c.GetHeader(testUserHeader))
+		c.Set(middleware.CallerKey, domain.Caller{UserID: uint(userID)})
+	}
+	router.POST("/inscriptions", authenticate, middleware.Idempotency(store, ttl, lease), func(c *gin.Context) {
+		n := handled.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		if status.Load() == crashStatus {
+			panic("replica caída")
+		}
+		c.JSON(int(status.Load()), gin.H{"id": n})
+	})
+	return router
+}
+
+func post(router *gin.Engine, key string, body string) *httptest.ResponseRecorder {
+	return postAs(router, 1, key, body)
+}
+
+func postAs(router *gin.Engine, userID uint, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/inscriptions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(testUserHeader, strconv.Itoa(int(userID)))
+	if key != "" {
+		req.Header.Set(middleware.IdempotencyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var status, handled atomic.Int32
+	status.Store(http.StatusCreated)
+	router := newRouter(t, time.Hour, time.Minute, &status, &handled)
+	body := `{"user_id":1,"course_id":2}`
+
+	first := post(router, "key-1", body)
+	second := post(router, "key-1", body)
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("expected 201 twice, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("expected the original response, got %s and %s", first.Body, second.Body)
+	}
+	if second.Header().Get(middleware.ReplayedHeader) != "true" {
+		t.Errorf("expected the replay to be marked")
+	}
+	if handled.Load() != 1 {
+		t.Errorf("expected the request to be processed once, got %d", handled.Load())
+	}
+
+	if w := post(router, "key-1", `{"user_id":1,"course_id":3}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 when reusing a key with another body, got %d", w.Code)
+	}
+	if post(router, "", body); handled.Load() != 2 {
+		t.Errorf("expected requests without key to be processed")
+	}
+}
+
+func TestIdempotencyConcurrentRetries(t *testing.T) {
+	var status, handled atomic.Int32
+	status.Store(http.StatusCreated)
+	router := newRouter(t, time.Hour, time.Minute, &status, &handled)
+
+	var (
+		wg    sync.WaitGroup
+		codes sync.Map
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes.Store(i, post(router, "key-2", `{"user_id":1,"course_id":2}`).Code)
+		}(i)
+	}
+	wg.Wait()
+
+	if handled.Load() != 1 {
+		t.Errorf("expected the request to be processed once, got %d", handled.Load())
+	}
+	codes.Range(func(_, code any) bool {
+		if code != http.StatusCreated && code != http.StatusConflict {
+			t.Errorf("expected 201 or 409 for a retry, got %v", code)
+		}
+		return true
+	})
+}
+
+func TestIdempotencyServerErrorsCanBeRetried(t *testing.T) {
+	var status, handled atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	router := newRouter(t, time.Hour, time.Minute, &status, &handled)
+
+	post(router, "key-3", `{}`)
+	status.Store(http.StatusCreated)
+	if w := post(router, "key-3", `{}`); w.Code != http.StatusCreated || handled.Load() != 2 {
+		t.Errorf("expected the retry to be processed, got %d after %d calls", w.Code, handled.Load())
+	}
+}
+
+func TestIdempotencyKeysExpire(t *testing.T) {
+	var status, handled atomic.Int32
+	status.Store(http.StatusCreated)
+	router := newRouter(t, time.Millisecond, time.Minute, &status, &handled)
+
+	post(router, "key-4", `{}`)
+	time.Sleep(10 * time.Millisecond)
+	if w := post(router, "key-4", `{}`); w.Header().Get(middleware.ReplayedHeader) != "" || handled.Load() != 2 {
+		t.Errorf("expected an expired key to be processed again")
+	}
+}
+
+func TestIdempotencyKeysAreScopedByUser(t *testing.T) {
+	var status, handled atomic.Int32
+	status.Store(http.StatusCreated)
+	router := newRouter(t, time.Hour, time.Minute, &status, &handled)
+
+	first := postAs(router, 1, "key-5", `{"course_id":2}`)
+	second := postAs(router, 2, "key-5", `{"course_id":2}`)
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for both users, got %d and %d", first.Code, second.Code)
+	}
+	if second.Header().Get(middleware.ReplayedHeader) != "" || handled.Load() != 2 {
+		t.Errorf("expected another user's request with the same key to be processed, got %d calls", handled.Load())
+	}
+}
+
+func TestIdempotencyReclaimsStaleKeys(t *testing.T) {
+	var status, handled atomic.Int32
+	status.Store(crashStatus)
+	router := newRouter(t, time.Hour, 50*time.Millisecond, &status, &handled)
+
+	post(router, "key-6", `{}`)
+	status.Store(http.StatusCreated)
+
+	// Mientras dura la reserva, el pedido sigue en curso
+	if w := post(router, "key-6", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 while the lease is held, got %d", w.Code)
+	}
+	// Otro pedido con la misma clave no la retoma
+	time.Sleep(60 * time.Millisecond)
+	if w := post(router, "key-6", `{"course_id":3}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a different request, got %d", w.Code)
+	}
+	if w := post(router, "key-6", `{}`); w.Code != http.StatusCreated || handled.Load() != 2 {
+		t.Errorf("expected the retry to reclaim the stale key, got %d after %d calls", w.Code, handled.Load())
+	}
+	if w := post(router, "key-6", `{}`); w.Header().Get(middleware.ReplayedHeader) != "true" || handled.Load() != 2 {
+		t.Errorf("expected the reclaimed response to be replayed")
+	}
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// EnrollAll inscribe a los usuarios de rows en sus cursos, ya activos y con su
+// evento enrollment.created, con la capacidad de cada curso en capacities. Los
+// lugares se reservan como en StartEnrollment, así que una importación tampoco
+// supera la capacidad, y la lista de espera de cada curso tiene prioridad.
+//
+// Devuelve la inscripción o el error de cada fila, en el orden de rows. Con
+// allOrNothing todo se hace en una transacción: si una fila falla no se crea
+// ninguna, y las demás quedan con domain.ErrBulkRolledBack. Si no, cada fila
+// tiene su transacción.
+func (r *InscriptionRepository) EnrollAll(ctx context.Context, rows []domain.BulkRow, capacities map[uint]int, allOrNothing bool) ([]*dao.InscriptionModel, []error, error) {
+	for courseID, capacity := range capacities {
+		if err := r.ensureSeats(ctx, courseID, capacity); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	inscriptions := make([]*dao.InscriptionModel, len(rows))
+	rowErrs := make([]error, len(rows))
+	db := r.dao.DB().WithContext(ctx)
+
+	if !allOrNothing {
+		for i, row := range rows {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if _, err := promote(tx, row.CourseID, capacities[row.CourseID]); err != nil {
+					return err
+				}
+				inscription, err := enrollActive(tx, row, capacities[row.CourseID])
+				inscriptions[i] = inscription
+				return err
+			})
+			if err != nil {
+				inscriptions[i], rowErrs[i] = nil, err
+			}
+		}
+		return inscriptions, rowErrs, nil
+	}
+
+	// Las filas de los cursos se bloquean siempre en el mismo orden, así dos
+	// importaciones simultáneas no se bloquean entre sí
+	order := make([]int, len(rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return rows[order[a]].CourseID < rows[order[b]].CourseID })
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		failed := false
+		promoted := map[uint]bool{}
+		for _, i := range order {
+			row := rows[i]
+			if !promoted[row.CourseID] {
+				if _, err := promote(tx, row.CourseID, capacities[row.CourseID]); err != nil {
+					return err
+				}
+				promoted[row.CourseID] = true
+			}
+
+			inscription, err := enrollActive(tx, row, capacities[row.CourseID])
+			switch {
+			case errors.Is(err, domain.ErrCourseFull), errors.Is(err, domain.ErrAlreadyEnrolled):
+				// Se sigue para informar todas las filas que fallan
+				rowErrs[i] = err
+				failed = true
+			case err != nil:
+				return err
+			default:
+				inscriptions[i] = inscription
+			}
+		}
+		if failed {
+			return domain.ErrBulkRolledBack
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, domain.ErrBulkRolledBack):
+		for i := range rows {
+			inscriptions[i] = nil
+			if rowErrs[i] == nil {
+				rowErrs[i] = domain.ErrBulkRolledBack
+			}
+		}
+		return inscriptions, rowErrs, nil
+	case err != nil:
+		return nil, nil, fmt.Errorf("error importing inscriptions: %w", err)
+	}
+	return inscriptions, rowErrs, nil
+}
+
+// enrollActive inscribe al usuario, ya activo, reserva su lugar y guarda el
+// evento enrollment.created en el outbox. Inscribe primero para que una fila
+// repetida no ocupe un lugar, que en all_or_nothing les faltaría a las siguientes.
+func enrollActive(tx *gorm.DB, row domain.BulkRow, capacity int) (*dao.InscriptionModel, error) {
+	inscription, err := enroll(tx, row.UserID, row.CourseID, domain.StatusActive)
+	if err != nil {
+		return nil, err
+	}
+	if err := reserveSeat(tx, row.CourseID, capacity); err != nil {
+		return nil, err
+	}
+	if err := addEvent(tx, domain.EventEnrollmentCreated, &inscription); err != nil {
+		return nil, err
+	}
+	return &inscription, nil
+}
+
+// ExportInscriptions recorre las inscripciones con alguno de los estados (sin
+// estados, todas) en orden de ID, de a batchSize, sin cargarlas todas a la vez
+func (r *InscriptionRepository) ExportInscriptions(ctx context.Context, statuses []string, batchSize int, fn func([]dao.InscriptionModel) error) error {
+	var batch []dao.InscriptionModel
+	result := withStatuses(r.dao.DB().WithContext(ctx), statuses).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error exporting inscriptions: %w", result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// addEvent guarda en el outbox un evento sobre una inscripción, dentro de la
+// transacción que la modifica. Cada evento incrementa la versión de la
+// inscripción y la lleva consigo: los eventos pueden llegar desordenados (las
+// réplicas publican el outbox a la vez) y los consumidores descartan los que
+// son más viejos que el último que aplicaron. El incremento bloquea la fila,
+// así que las versiones de una inscripción siguen el orden de los commits.
+func addEvent(tx *gorm.DB, eventType string, inscription *dao.InscriptionModel) error {
+	if err := tx.Model(&dao.InscriptionModel{}).Where("id = ?", inscription.ID).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return fmt.Errorf("error incrementing version of inscription %d: %w", inscription.ID, err)
+	}
+	var current dao.InscriptionModel
+	if err := tx.Select("version").First(&current, inscription.ID).Error; err != nil {
+		return fmt.Errorf("error reading version of inscription %d: %w", inscription.ID, err)
+	}
+	inscription.Version = current.Version
+
+	return saveEvent(tx, domain.Event{
+		Type:          eventType,
+		InscriptionID: inscription.ID,
+		UserID:        inscription.UserID,
+		CourseID:      inscription.CourseID,
+		Version:       inscription.Version,
+	})
+}
+
+// saveEvent guarda el evento en el outbox con un ID nuevo
+func saveEvent(tx *gorm.DB, event domain.Event) error {
+	eventID, err := newEventID()
+	if err != nil {
+		return fmt.Errorf("error generating event ID: %w", err)
+	}
+	event.EventID = eventID
+	event.OccurredAt = time.Now().UTC()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+
+	model := dao.EventModel{EventID: eventID, Type: event.Type, Payload: payload}
+	if err := tx.Create(&model).Error; err != nil {
+		return fmt.Errorf("error saving %s event: %w", event.Type, err)
+	}
+	return nil
+}
+
+// SnapshotEnrollments guarda en el outbox un evento enrollment.snapshot por
+// cada inscripción del curso, incluidas las dadas de baja, con su estado y su
+// versión actual. La versión no se incrementa: un consumidor que ya aplicó esa
+// versión lo descarta, y uno que se perdió eventos queda al día sin pisar los
+// posteriores. Devuelve cuántos eventos guardó.
+func (r *InscriptionRepository) SnapshotEnrollments(ctx context.Context, courseID uint) (int, error) {
+	var saved int
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var inscriptions []dao.InscriptionModel
+		if err := tx.Where("course_id = ?", courseID).Order("id").Find(&inscriptions).Error; err != nil {
+			return fmt.Errorf("error reading inscriptions of course %d: %w", courseID, err)
+		}
+		for _, inscription := range inscriptions {
+			if err := saveEvent(tx, domain.Event{
+				Type:          domain.EventEnrollmentSnapshot,
+				InscriptionID: inscription.ID,
+				UserID:        inscription.UserID,
+				CourseID:      inscription.CourseID,
+				Version:       inscription.Version,
+				Status:        inscription.Status,
+			}); err != nil {
+				return err
+			}
+		}
+		saved = len(inscriptions)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return saved, nil
+}
+
+// PublishPending publica hasta limit eventos del outbox en orden y los marca
+// como publicados. Si una publicación falla se detiene y devuelve cuántos
+// publicó. Las filas se toman con SKIP LOCKED: si las dos réplicas publican a
+// la vez, cada una toma eventos distintos, así que entre réplicas el orden no
+// se garantiza. Por eso cada evento lleva la versión de su inscripción.
+func (r *InscriptionRepository) PublishPending(ctx context.Context, limit int, publish func(dao.EventModel) error) (int, error) {
+	var (
+		published  int
+		publishErr error
+	)
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var events []dao.EventModel
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").
+			Order("id").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return fmt.Errorf("error reading pending events: %w", err)
+		}
+
+		ids := make([]uint, 0, len(events))
+		for _, event := range events {
+			if publishErr = publish(event); publishErr != nil {
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+		if len(ids) > 0 {
+			if err := tx.Model(&dao.EventModel{}).Where("id IN ?", ids).Update("published_at", time.Now()).Error; err != nil {
+				return fmt.Errorf("error marking events as published: %w", err)
+			}
+		}
+		published = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Los que se publicaron antes del error quedan marcados
+	if publishErr != nil {
+		return published, fmt.Errorf("error publishing event: %w", publishErr)
+	}
+	return published, nil
+}
+
+// newEventID genera un identificador aleatorio de 128 bits en hexadecimal
+func newEventID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
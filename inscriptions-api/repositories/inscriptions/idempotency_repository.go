@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClaimIdempotencyKey reserva la clave para un pedido nuevo durante lease. Si
+// la clave ya estaba reservada y no venció, no la toma y devuelve lo guardado
+// para ella. La clave primaria hace que, de dos pedidos simultáneos con la
+// misma clave, solo uno la reserve. Un pedido igual cuya reserva venció sin
+// respuesta (la réplica que lo procesaba se cayó) la retoma.
+func (r *InscriptionRepository) ClaimIdempotencyKey(ctx context.Context, key string, fingerprint string, lease time.Duration, ttl time.Duration) (*domain.IdempotentResponse, error) {
+	db := r.dao.DB().WithContext(ctx)
+	now := time.Now()
+
+	// Una clave vencida se puede volver a usar
+	if err := db.Where("idempotency_key = ? AND expires_at < ?", key, now).Delete(&dao.IdempotencyKeyModel{}).Error; err != nil {
+		return nil, fmt.Errorf("error removing expired idempotency key: %w", err)
+	}
+
+	lockedUntil := now.Add(lease)
+	record := dao.IdempotencyKeyModel{IdempotencyKey: key, Fingerprint: fingerprint, LockedUntil: &lockedUntil, ExpiresAt: now.Add(ttl)}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error saving idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	// La actualización es condicional, así que de dos reintentos solo uno la retoma
+	result = db.Model(&dao.IdempotencyKeyModel{}).
+		Where("idempotency_key = ? AND fingerprint = ? AND status_code = 0 AND (locked_until IS NULL OR locked_until < ?)", key, fingerprint, now).
+		Updates(map[string]interface{}{"locked_until": lockedUntil, "expires_at": now.Add(ttl)})
+	if result.Error != nil {
+		return nil, fmt.Errorf("error reclaiming idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing dao.IdempotencyKeyModel
+	if err := db.First(&existing, "idempotency_key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Se liberó mientras tanto: el pedido original falló y se puede reintentar
+			return nil, domain.ErrIdempotencyKeyInProgress
+		}
+		return nil, fmt.Errorf("error reading idempotency key: %w", err)
+	}
+	return &domain.IdempotentResponse{
+		Fingerprint: existing.Fingerprint,
+		StatusCode:  existing.StatusCode,
+		Body:        existing.Response,
+	}, nil
+}
+
+// SaveIdempotentResponse guarda la respuesta al pedido que reservó la clave
+func (r *InscriptionRepository) SaveIdempotentResponse(ctx context.Context, key string, statusCode int, body []byte) error {
+	if err := r.dao.DB().WithContext(ctx).Model(&dao.IdempotencyKeyModel{}).
+		Where("idempotency_key = ?", key).
+		Updates(map[string]interface{}{"status_code": statusCode, "response": body}).Error; err != nil {
+		return fmt.Errorf("error saving idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey libera la clave de un pedido que falló, para que se pueda reintentar
+func (r *InscriptionRepository) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if err := r.dao.DB().WithContext(ctx).Where("idempotency_key = ?", key).Delete(&dao.IdempotencyKeyModel{}).Error; err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys borra las claves vencidas y devuelve cuántas borró
+func (r *InscriptionRepository) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result := r.dao.DB().WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&dao.IdempotencyKeyModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error deleting expired idempotency keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
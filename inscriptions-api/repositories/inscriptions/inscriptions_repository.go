@@ -5,10 +5,15 @@ import (
 	"errors"
 	"fmt"
 	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"log"
 	"os"
+	"strings"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func getEnv(key, defaultValue string) string {
@@ -30,19 +35,71 @@ func Connect() (*gorm.DB, error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local&timeout=30s",
 		dbUser, dbPassword, dbHost, dbPort, dbName)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	// TranslateError convierte las violaciones de índices únicos en gorm.ErrDuplicatedKey
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to MySQL: %v", err)
 	}
 
-	err = db.AutoMigrate(&dao.InscriptionModel{})
-	if err != nil {
-		return nil, fmt.Errorf("error migrating database: %v", err)
+	if err := Migrate(db); err != nil {
+		return nil, err
 	}
 
 	return db, nil
 }
 
+// Migrate crea o actualiza las tablas de inscripciones
+func Migrate(db *gorm.DB) error {
+	if err := checkDuplicateInscriptions(db); err != nil {
+		return err
+	}
+	if err := db.AutoMigrate(&dao.InscriptionModel{}, &dao.CourseSeatsModel{}, &dao.WaitlistEntryModel{}, &dao.EventModel{}, &dao.IdempotencyKeyModel{}, &dao.EnrollmentSagaModel{}); err != nil {
+		return fmt.Errorf("error migrating database: %v", err)
+	}
+	return nil
+}
+
+// checkDuplicateInscriptions revisa, antes de crear el índice único, que las
+// bases de antes de que existiera no tengan a un usuario inscripto dos veces en
+// un curso. No borra nada: si las hay, las informa y la migración falla hasta
+// que se resuelvan a mano (y se borre el registro de lugares de esos cursos
+// para que se vuelva a contar).
+func checkDuplicateInscriptions(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&dao.InscriptionModel{}) || migrator.HasIndex(&dao.InscriptionModel{}, "idx_inscription_user_course") {
+		return nil
+	}
+	var groups []struct {
+		UserID   uint
+		CourseID uint
+	}
+	if err := db.Model(&dao.InscriptionModel{}).
+		Select("user_id, course_id").
+		Group("user_id, course_id").
+		Having("COUNT(*) > 1").
+		Order("user_id, course_id").
+		Scan(&groups).Error; err != nil {
+		return fmt.Errorf("error checking duplicate inscriptions: %v", err)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	duplicates := make([]string, 0, len(groups))
+	for _, group := range groups {
+		var ids []uint
+		if err := db.Model(&dao.InscriptionModel{}).
+			Where("user_id = ? AND course_id = ?", group.UserID, group.CourseID).
+			Order("id").
+			Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("error checking duplicate inscriptions: %v", err)
+		}
+		log.Printf("User %d has %d inscriptions in course %d: %v", group.UserID, len(ids), group.CourseID, ids)
+		duplicates = append(duplicates, fmt.Sprintf("user %d in course %d (inscriptions %v)", group.UserID, group.CourseID, ids))
+	}
+	return fmt.Errorf("%w: %s", domain.ErrDuplicateInscriptions, strings.Join(duplicates, "; "))
+}
+
 type InscriptionRepository struct {
 	dao *dao.InscriptionDAO
 }
@@ -51,46 +108,253 @@ func NewInscriptionRepository(dao *dao.InscriptionDAO) *InscriptionRepository {
 	return &InscriptionRepository{dao: dao}
 }
 
-func (r *InscriptionRepository) CreateInscription(ctx context.Context, userID, courseID uint) (*dao.InscriptionModel, error) {
+// StartEnrollment ejecuta los primeros pasos de la saga de inscripción:
+// reserva un lugar del curso y guarda la inscripción como pendiente, junto con
+// la saga que la va a completar, reservada por lease para esta réplica.
+//
+// El lugar se reserva con una actualización condicional sobre la fila del curso
+// en course_seats_models, que queda bloqueada hasta el final de la transacción:
+// las inscripciones concurrentes al mismo curso, desde cualquier réplica, se
+// ejecutan de a una y ninguna puede superar la capacidad. Si hay lugares y
+// alumnos en la lista de espera (la capacidad aumentó), ellos tienen prioridad.
+func (r *InscriptionRepository) StartEnrollment(ctx context.Context, userID, courseID uint, capacity int, lease time.Duration) (*dao.InscriptionModel, *dao.EnrollmentSagaModel, error) {
+	if err := r.ensureSeats(ctx, courseID, capacity); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		newInscription dao.InscriptionModel
+		saga           dao.EnrollmentSagaModel
+	)
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := promote(tx, courseID, capacity); err != nil {
+			return err
+		}
+
+		if err := reserveSeat(tx, courseID, capacity); err != nil {
+			return err
+		}
+
+		// Con la fila del curso bloqueada, la lectura ve las inscripciones confirmadas por otras réplicas
+		var err error
+		if newInscription, err = enroll(tx, userID, courseID, domain.StatusPending); err != nil {
+			return err
+		}
+
+		saga = dao.EnrollmentSagaModel{
+			InscriptionID: newInscription.ID,
+			CourseID:      courseID,
+			Step:          domain.StepPublishEvent,
+			Status:        domain.SagaRunning,
+			LockedUntil:   time.Now().Add(lease),
+		}
+		if err := tx.Create(&saga).Error; err != nil {
+			return fmt.Errorf("error saving enrollment saga: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &newInscription, &saga, nil
+}
+
+// reserveSeat ocupa un lugar del curso si queda alguno, con una actualización
+// condicional que bloquea su fila hasta el final de la transacción. La
+// capacidad se actualiza con la última informada por courses-api.
+func reserveSeat(tx *gorm.DB, courseID uint, capacity int) error {
+	result := tx.Model(&dao.CourseSeatsModel{}).
+		Where("course_id = ? AND reserved < ?", courseID, capacity).
+		Updates(map[string]interface{}{
+			"reserved": gorm.Expr("reserved + 1"),
+			"capacity": capacity,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error reserving seat: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrCourseFull
+	}
+	return nil
+}
+
+// enroll deja inscripto al usuario en el curso con el estado indicado: crea la
+// inscripción, o reactiva la que dio de baja. El índice único sobre
+// (user_id, course_id) garantiza una sola fila aunque dos pedidos lleguen a
+// insertarla a la vez.
+func enroll(tx *gorm.DB, userID, courseID uint, status string) (dao.InscriptionModel, error) {
 	var inscription dao.InscriptionModel
-	if err := r.dao.DB().WithContext(ctx).Where("user_id = ? AND course_id = ?", userID, courseID).
-		First(&inscription).Error; err == nil {
-		return nil, errors.New("inscription already exists")
-	} else if err != gorm.ErrRecordNotFound {
-		return nil, err
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND course_id = ?", userID, courseID).
+		First(&inscription).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		inscription = dao.InscriptionModel{UserID: userID, CourseID: courseID, Status: status}
+		if err := tx.Create(&inscription).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return dao.InscriptionModel{}, domain.ErrAlreadyEnrolled
+			}
+			return dao.InscriptionModel{}, fmt.Errorf("error creating inscription: %w", err)
+		}
+		return inscription, nil
+	case err != nil:
+		return dao.InscriptionModel{}, fmt.Errorf("error reading inscription: %w", err)
+	case inscription.Status != domain.StatusCancelled:
+		return dao.InscriptionModel{}, domain.ErrAlreadyEnrolled
 	}
 
-	newInscription := dao.InscriptionModel{UserID: userID, CourseID: courseID}
-	if err := r.dao.DB().WithContext(ctx).Create(&newInscription).Error; err != nil {
-		return nil, err
+	if err := tx.Model(&inscription).Updates(map[string]interface{}{
+		"status":       status,
+		"cancelled_at": nil,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		return dao.InscriptionModel{}, fmt.Errorf("error reactivating inscription: %w", err)
 	}
+	if err := tx.First(&inscription, inscription.ID).Error; err != nil {
+		return dao.InscriptionModel{}, err
+	}
+	return inscription, nil
+}
 
-	return &newInscription, nil
+// ensureSeats crea el registro de lugares de un curso la primera vez que
+// alguien se inscribe, contando las inscripciones anteriores a él
+func (r *InscriptionRepository) ensureSeats(ctx context.Context, courseID uint, capacity int) error {
+	db := r.dao.DB().WithContext(ctx)
+
+	var found int64
+	if err := db.Model(&dao.CourseSeatsModel{}).Where("course_id = ?", courseID).Count(&found).Error; err != nil {
+		return fmt.Errorf("error reading course seats: %w", err)
+	}
+	if found > 0 {
+		return nil
+	}
+
+	var reserved int64
+	if err := db.Model(&dao.InscriptionModel{}).
+		Where("course_id = ? AND status IN ?", courseID, domain.SeatHoldingStatuses).
+		Count(&reserved).Error; err != nil {
+		return fmt.Errorf("error counting inscriptions: %w", err)
+	}
+	// Si otra réplica lo creó mientras tanto, se conserva el suyo
+	seats := dao.CourseSeatsModel{CourseID: courseID, Capacity: capacity, Reserved: int(reserved)}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&seats).Error; err != nil {
+		return fmt.Errorf("error creating course seats: %w", err)
+	}
+	return nil
 }
 
-func (r *InscriptionRepository) GetInscriptions(ctx context.Context) ([]dao.InscriptionModel, error) {
-	var inscriptionsModel []dao.InscriptionModel
-	if err := r.dao.DB().WithContext(ctx).Find(&inscriptionsModel).Error; err != nil {
+// UpdateStatus cambia el estado de una inscripción si la transición es válida.
+// El cambio es condicional al estado leído, así que de dos pedidos simultáneos
+// (por ejemplo, dos bajas) solo uno se aplica. Al cancelar se libera el lugar
+// en el registro del curso dentro de la misma transacción, y pasa al primero
+// de la lista de espera si lo hay.
+func (r *InscriptionRepository) UpdateStatus(ctx context.Context, id uint, status string) (*dao.InscriptionModel, error) {
+	var inscription dao.InscriptionModel
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&inscription, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return domain.ErrInscriptionNotFound
+			}
+			return fmt.Errorf("error reading inscription %d: %w", id, err)
+		}
+		if err := transition(tx, &inscription, status); err != nil {
+			return err
+		}
+		return tx.First(&inscription, id).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return inscriptionsModel, nil
+	return &inscription, nil
 }
 
-func (r *InscriptionRepository) GetInscriptionsByUser(ctx context.Context, userID uint) ([]dao.InscriptionModel, error) {
-	var inscriptionsModel []dao.InscriptionModel
-	if err := r.dao.DB().WithContext(ctx).Where("user_id = ?", userID).Find(&inscriptionsModel).Error; err != nil {
-		return nil, err
+// transition cambia el estado de la inscripción leída, si sigue en ese estado.
+// Al cancelar, libera el lugar, guarda el evento enrollment.cancelled y le da
+// el lugar al primero de la lista de espera.
+func transition(tx *gorm.DB, inscription *dao.InscriptionModel, status string) error {
+	if !domain.CanTransition(inscription.Status, status) {
+		return fmt.Errorf("%w: %s to %s", domain.ErrInvalidTransition, inscription.Status, status)
+	}
+
+	now := time.Now()
+	changes := map[string]interface{}{"status": status, "updated_at": now}
+	if status == domain.StatusCancelled {
+		changes["cancelled_at"] = now
+	}
+	result := tx.Model(&dao.InscriptionModel{}).
+		Where("id = ? AND status = ?", inscription.ID, inscription.Status).
+		Updates(changes)
+	if result.Error != nil {
+		return fmt.Errorf("error updating inscription %d: %w", inscription.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: inscription %d changed concurrently", domain.ErrInvalidTransition, inscription.ID)
 	}
 
-	return inscriptionsModel, nil
+	if status == domain.StatusCancelled {
+		if err := tx.Model(&dao.CourseSeatsModel{}).
+			Where("course_id = ? AND reserved > 0", inscription.CourseID).
+			Update("reserved", gorm.Expr("reserved - 1")).Error; err != nil {
+			return fmt.Errorf("error releasing seat: %w", err)
+		}
+		if err := addEvent(tx, domain.EventEnrollmentCancelled, inscription); err != nil {
+			return err
+		}
+		if _, err := promote(tx, inscription.CourseID, 0); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *InscriptionRepository) GetInscriptionsByCourse(ctx context.Context, courseID uint) ([]dao.InscriptionModel, error) {
-	var inscriptionsModel []dao.InscriptionModel
-	if err := r.dao.DB().WithContext(ctx).Where("course_id = ?", courseID).Find(&inscriptionsModel).Error; err != nil {
+func (r *InscriptionRepository) GetInscription(ctx context.Context, id uint) (*dao.InscriptionModel, error) {
+	var inscription dao.InscriptionModel
+	if err := r.dao.DB().WithContext(ctx).First(&inscription, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrInscriptionNotFound
+		}
 		return nil, err
 	}
 
-	return inscriptionsModel, nil
+	return &inscription, nil
+}
+
+// withStatuses filtra por estado; sin estados no filtra
+func withStatuses(db *gorm.DB, statuses []string) *gorm.DB {
+	if len(statuses) == 0 {
+		return db
+	}
+	return db.Where("status IN ?", statuses)
+}
+
+// ListInscriptions devuelve una página de las inscripciones que cumplen los
+// filtros de query, ordenadas por ID, y cuántas los cumplen en total
+func (r *InscriptionRepository) ListInscriptions(ctx context.Context, query domain.InscriptionQuery) ([]dao.InscriptionModel, int64, error) {
+	db := withStatuses(r.dao.DB().WithContext(ctx).Model(&dao.InscriptionModel{}), query.Statuses)
+	if query.UserID != 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.CourseID != 0 {
+		db = db.Where("course_id = ?", query.CourseID)
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("created_at <= ?", *query.CreatedTo)
+	}
+	// La misma consulta sirve para contar y para leer la página
+	db = db.Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("error counting inscriptions: %w", err)
+	}
+	var inscriptionsModel []dao.InscriptionModel
+	if err := db.Order("id").Offset(query.Offset).Limit(query.Limit).Find(&inscriptionsModel).Error; err != nil {
+		return nil, 0, fmt.Errorf("error listing inscriptions: %w", err)
+	}
+	return inscriptionsModel, total, nil
 }
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxSagaErrorLength = 512
+
+// RecordSagaError registra un intento fallido del paso actual y devuelve cuántos van
+func (r *InscriptionRepository) RecordSagaError(ctx context.Context, sagaID uint, cause error) (int, error) {
+	message := cause.Error()
+	if len(message) > maxSagaErrorLength {
+		message = message[:maxSagaErrorLength]
+	}
+
+	var saga dao.EnrollmentSagaModel
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&dao.EnrollmentSagaModel{}).
+			Where("id = ?", sagaID).
+			Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "last_error": message}).Error; err != nil {
+			return err
+		}
+		return tx.First(&saga, sagaID).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error recording saga %d error: %w", sagaID, err)
+	}
+	return saga.Attempts, nil
+}
+
+// CompleteEnrollment ejecuta el último paso de la saga: activa la inscripción
+// y guarda el evento enrollment.created en el outbox, en una transacción. Si
+// la inscripción se dio de baja mientras tanto, la saga termina compensada.
+func (r *InscriptionRepository) CompleteEnrollment(ctx context.Context, sagaID uint) (*dao.InscriptionModel, error) {
+	var inscription dao.InscriptionModel
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		saga, err := runningSaga(tx, sagaID)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&inscription, saga.InscriptionID).Error; err != nil {
+			return fmt.Errorf("error reading inscription %d: %w", saga.InscriptionID, err)
+		}
+
+		status := domain.SagaCompleted
+		err = transition(tx, &inscription, domain.StatusActive)
+		switch {
+		case errors.Is(err, domain.ErrInvalidTransition):
+			status = domain.SagaCompensated
+		case err != nil:
+			return err
+		default:
+			inscription.Status = domain.StatusActive
+			if err := addEvent(tx, domain.EventEnrollmentCreated, &inscription); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&saga).Updates(map[string]interface{}{"step": domain.StepPublishEvent, "status": status}).Error; err != nil {
+			return fmt.Errorf("error completing saga %d: %w", sagaID, err)
+		}
+		return tx.First(&inscription, saga.InscriptionID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &inscription, nil
+}
+
+// ClaimStaleSagas reserva por lease hasta limit sagas sin terminar cuya
+// réplica dejó de ejecutarlas (se cayó, o el pedido se cortó). La reserva es
+// condicional a que el plazo siga vencido, así que cada saga la retoma una
+// sola réplica.
+func (r *InscriptionRepository) ClaimStaleSagas(ctx context.Context, lease time.Duration, limit int) ([]dao.EnrollmentSagaModel, error) {
+	db := r.dao.DB().WithContext(ctx)
+	now := time.Now()
+
+	var stale []dao.EnrollmentSagaModel
+	if err := db.Where("status = ? AND locked_until < ?", domain.SagaRunning, now).
+		Order("id").
+		Limit(limit).
+		Find(&stale).Error; err != nil {
+		return nil, fmt.Errorf("error reading stale sagas: %w", err)
+	}
+
+	claimed := make([]dao.EnrollmentSagaModel, 0, len(stale))
+	for _, saga := range stale {
+		result := db.Model(&dao.EnrollmentSagaModel{}).
+			Where("id = ? AND status = ? AND locked_until < ?", saga.ID, domain.SagaRunning, now).
+			Update("locked_until", now.Add(lease))
+		if result.Error != nil {
+			return nil, fmt.Errorf("error claiming saga %d: %w", saga.ID, result.Error)
+		}
+		if result.RowsAffected == 1 {
+			saga.LockedUntil = now.Add(lease)
+			claimed = append(claimed, saga)
+		}
+	}
+	return claimed, nil
+}
+
+// runningSaga lee y bloquea la saga, que tiene que seguir en curso: si dos
+// réplicas llegan a terminarla a la vez, la segunda ve que ya terminó
+func runningSaga(tx *gorm.DB, sagaID uint) (dao.EnrollmentSagaModel, error) {
+	var saga dao.EnrollmentSagaModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&saga, sagaID).Error; err != nil {
+		return saga, fmt.Errorf("error reading saga %d: %w", sagaID, err)
+	}
+	if saga.Status != domain.SagaRunning {
+		return saga, fmt.Errorf("%w: saga %d is %s", domain.ErrInvalidTransition, sagaID, saga.Status)
+	}
+	return saga, nil
+}
@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JoinWaitlist agrega al usuario al final de la lista de espera de un curso
+// lleno y devuelve su posición. Bloquea la fila del curso igual que una
+// inscripción, así no se anota en la lista alguien que podría inscribirse, ni
+// se pierde a alguien anotado mientras otro se da de baja.
+func (r *InscriptionRepository) JoinWaitlist(ctx context.Context, userID, courseID uint, capacity int) (*dao.WaitlistEntryModel, int, error) {
+	if err := r.ensureSeats(ctx, courseID, capacity); err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		entry    dao.WaitlistEntryModel
+		position int
+	)
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := promote(tx, courseID, capacity); err != nil {
+			return err
+		}
+
+		var seats dao.CourseSeatsModel
+		if err := tx.First(&seats, "course_id = ?", courseID).Error; err != nil {
+			return fmt.Errorf("error reading course seats: %w", err)
+		}
+
+		var enrolled int64
+		if err := tx.Model(&dao.InscriptionModel{}).
+			Where("user_id = ? AND course_id = ? AND status IN ?", userID, courseID, domain.SeatHoldingStatuses).
+			Count(&enrolled).Error; err != nil {
+			return err
+		}
+		if enrolled > 0 {
+			return domain.ErrAlreadyEnrolled
+		}
+		if seats.Reserved < seats.Capacity {
+			return domain.ErrSeatsAvailable
+		}
+
+		var waiting int64
+		if err := tx.Model(&dao.WaitlistEntryModel{}).
+			Where("user_id = ? AND course_id = ?", userID, courseID).
+			Count(&waiting).Error; err != nil {
+			return err
+		}
+		if waiting > 0 {
+			return domain.ErrAlreadyWaitlisted
+		}
+
+		entry = dao.WaitlistEntryModel{UserID: userID, CourseID: courseID}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+		var err error
+		position, err = waitlistPosition(tx, entry)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &entry, position, nil
+}
+
+// LeaveWaitlist saca al usuario de la lista de espera de un curso
+func (r *InscriptionRepository) LeaveWaitlist(ctx context.Context, userID, courseID uint) error {
+	result := r.dao.DB().WithContext(ctx).
+		Where("user_id = ? AND course_id = ?", userID, courseID).
+		Delete(&dao.WaitlistEntryModel{})
+	if result.Error != nil {
+		return fmt.Errorf("error leaving waitlist: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrNotWaitlisted
+	}
+	return nil
+}
+
+// GetWaitlist devuelve la lista de espera de un curso en orden de llegada
+func (r *InscriptionRepository) GetWaitlist(ctx context.Context, courseID uint) ([]dao.WaitlistEntryModel, error) {
+	var entries []dao.WaitlistEntryModel
+	if err := r.dao.DB().WithContext(ctx).Where("course_id = ?", courseID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetWaitlistEntry devuelve el lugar del usuario en la lista de espera de un curso y su posición
+func (r *InscriptionRepository) GetWaitlistEntry(ctx context.Context, userID, courseID uint) (*dao.WaitlistEntryModel, int, error) {
+	db := r.dao.DB().WithContext(ctx)
+
+	var entry dao.WaitlistEntryModel
+	if err := db.Where("user_id = ? AND course_id = ?", userID, courseID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, domain.ErrNotWaitlisted
+		}
+		return nil, 0, err
+	}
+	position, err := waitlistPosition(db, entry)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &entry, position, nil
+}
+
+// PromoteWaitlist inscribe a los primeros de la lista de espera en los lugares
+// libres, con la capacidad actual del curso. Devuelve las inscripciones creadas
+// y los lugares que quedan libres.
+func (r *InscriptionRepository) PromoteWaitlist(ctx context.Context, courseID uint, capacity int) ([]dao.InscriptionModel, int, error) {
+	if err := r.ensureSeats(ctx, courseID, capacity); err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		promoted  []dao.InscriptionModel
+		remaining int
+	)
+	err := r.dao.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if promoted, err = promote(tx, courseID, capacity); err != nil {
+			return err
+		}
+
+		var seats dao.CourseSeatsModel
+		if err := tx.First(&seats, "course_id = ?", courseID).Error; err != nil {
+			return fmt.Errorf("error reading course seats: %w", err)
+		}
+		remaining = seats.Capacity - seats.Reserved
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return promoted, remaining, nil
+}
+
+// promote inscribe en orden de llegada a los alumnos de la lista de espera
+// mientras el curso tenga lugares, y guarda por cada uno los eventos
+// waitlist.promoted y enrollment.created. Bloquea la fila del curso en course_seats_models hasta el final de la transacción; si
+// capacity es mayor que cero, la capacidad se actualiza con ella.
+func promote(tx *gorm.DB, courseID uint, capacity int) ([]dao.InscriptionModel, error) {
+	var seats dao.CourseSeatsModel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seats, "course_id = ?", courseID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Nadie se inscribió todavía, así que tampoco hay lista de espera
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading course seats: %w", err)
+	}
+	if capacity > 0 {
+		seats.Capacity = capacity
+	}
+
+	var promoted []dao.InscriptionModel
+	for seats.Reserved < seats.Capacity {
+		var entry dao.WaitlistEntryModel
+		if err := tx.Where("course_id = ?", courseID).Order("id").First(&entry).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, fmt.Errorf("error reading waitlist: %w", err)
+		}
+		if err := tx.Delete(&entry).Error; err != nil {
+			return nil, fmt.Errorf("error removing waitlist entry: %w", err)
+		}
+
+		inscription, err := enroll(tx, entry.UserID, courseID, domain.StatusActive)
+		if errors.Is(err, domain.ErrAlreadyEnrolled) {
+			// Ya se inscribió por otro camino, solo deja la lista
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error promoting user %d: %w", entry.UserID, err)
+		}
+		if err := addEvent(tx, domain.EventWaitlistPromoted, &inscription); err != nil {
+			return nil, err
+		}
+		if err := addEvent(tx, domain.EventEnrollmentCreated, &inscription); err != nil {
+			return nil, err
+		}
+		seats.Reserved++
+		promoted = append(promoted, inscription)
+	}
+
+	if err := tx.Model(&dao.CourseSeatsModel{}).
+		Where("course_id = ?", courseID).
+		Updates(map[string]interface{}{"reserved": seats.Reserved, "capacity": seats.Capacity}).Error; err != nil {
+		return nil, fmt.Errorf("error updating course seats: %w", err)
+	}
+	return promoted, nil
+}
+
+// waitlistPosition cuenta cuántos llegaron antes a la lista, incluido el propio lugar
+func waitlistPosition(db *gorm.DB, entry dao.WaitlistEntryModel) (int, error) {
+	var position int64
+	if err := db.Model(&dao.WaitlistEntryModel{}).
+		Where("course_id = ? AND id <= ?", entry.CourseID, entry.ID).
+		Count(&position).Error; err != nil {
+		return 0, fmt.Errorf("error computing waitlist position: %w", err)
+	}
+	return int(position), nil
+}
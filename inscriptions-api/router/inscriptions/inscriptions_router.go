@@ -9,21 +9,37 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// MapRoutes mapea las rutas del controlador de inscripciones.
-func MapRoutes(r *gin.Engine, ctrl *controller.Controller) {
+// MapRoutes mapea las rutas del controlador de inscripciones. authenticate se
+// aplica a todas (ver middleware.Authenticate) e idempotency a la creación e
+// importación de inscripciones (ver middleware.Idempotency).
+func MapRoutes(r *gin.Engine, ctrl *controller.Controller, authenticate gin.HandlerFunc, idempotency gin.HandlerFunc) {
 	// Configuración de CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://localhost:8085"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// Rutas
-	r.POST("/inscriptions", ctrl.CreateInscription)
-	r.GET("/inscriptions", ctrl.GetInscriptions)
-	r.GET("/users/:userID/inscriptions", ctrl.GetInscriptionsByUser)
-	r.GET("/courses/:courseID/inscriptions", ctrl.GetInscriptionsByCourse)
+	api := r.Group("/", authenticate)
+	api.POST("/inscriptions", idempotency, ctrl.CreateInscription)
+	api.GET("/inscriptions", ctrl.GetInscriptions)
+	api.POST("/inscriptions/bulk", idempotency, ctrl.BulkCreateInscriptions)
+	api.GET("/inscriptions/export", ctrl.ExportInscriptions)
+	api.GET("/inscriptions/:id", ctrl.GetInscription)
+	api.DELETE("/inscriptions/:id", ctrl.CancelInscription)
+	api.POST("/inscriptions/:id/cancel", ctrl.CancelInscription)
+	api.POST("/inscriptions/:id/complete", ctrl.CompleteInscription)
+	api.GET("/users/:userID/inscriptions", ctrl.GetInscriptionsByUser)
+	api.GET("/courses/:courseID/inscriptions", ctrl.GetInscriptionsByCourse)
+
+	// Lista de espera
+	api.POST("/courses/:courseID/waitlist", ctrl.JoinWaitlist)
+	api.GET("/courses/:courseID/waitlist", ctrl.GetWaitlist)
+	api.GET("/courses/:courseID/waitlist/:userID", ctrl.GetWaitlistEntry)
+	api.DELETE("/courses/:courseID/waitlist/:userID", ctrl.LeaveWaitlist)
+	api.POST("/courses/:courseID/waitlist/promote", ctrl.PromoteWaitlist)
 }
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"context"
+	"expvar"
+	dao "inscriptions-api/DAOs/inscriptions"
+	"log"
+	"time"
+)
+
+// Repository define el outbox de eventos
+type Repository interface {
+	PublishPending(ctx context.Context, limit int, publish func(dao.EventModel) error) (int, error)
+}
+
+// Publisher envía un evento al broker
+type Publisher interface {
+	Publish(eventID string, eventType string, body []byte) error
+}
+
+// Valores por defecto del publicador de eventos
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 100
+)
+
+// metrics expone en /debug/vars cuántos eventos se publicaron y cuántas veces falló
+var metrics = expvar.NewMap("inscriptions_events")
+
+type Config struct {
+	Interval  time.Duration // Cada cuánto se buscan eventos pendientes
+	BatchSize int           // Eventos por transacción
+}
+
+// Relay publica en RabbitMQ los eventos guardados en el outbox. Un evento se
+// marca como publicado después del ack del broker, así que ante un corte puede
+// publicarse dos veces: los consumidores descartan duplicados por event_id.
+type Relay struct {
+	repository Repository
+	publisher  Publisher
+	config     Config
+}
+
+func NewRelay(repository Repository, publisher Publisher, config Config) *Relay {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	return &Relay{repository: repository, publisher: publisher, config: config}
+}
+
+// Run publica los eventos pendientes hasta que se cancele ctx
+func (relay *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relay.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		relay.Flush(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publica todos los eventos pendientes, por lotes
+func (relay *Relay) Flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := relay.repository.PublishPending(ctx, relay.config.BatchSize, func(event dao.EventModel) error {
+			return relay.publisher.Publish(event.EventID, event.Type, event.Payload)
+		})
+		metrics.Add("published", int64(published))
+		if err != nil {
+			log.Printf("Error al publicar eventos de inscripciones: %v", err)
+			metrics.Add("failed", 1)
+			return
+		}
+		if published < relay.config.BatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	domain "inscriptions-api/domain/inscriptions"
+	"sync"
+)
+
+// Parámetros de la importación y la exportación de inscripciones
+const (
+	bulkLookupWorkers = 8   // Consultas simultáneas a users-api y courses-api
+	exportBatchSize   = 500 // Inscripciones que se leen de la base por vez
+)
+
+// BulkCreateInscriptions importa inscripciones. Primero valida cada fila:
+// IDs válidos, sin repetir, y que el usuario y el curso existan (consultando
+// cada uno una sola vez). Después inscribe las filas válidas respetando la
+// capacidad de los cursos (ver Repository.EnrollAll). Con domain.BulkPartial se
+// crean las filas que se puedan; con domain.BulkAllOrNothing, si falla alguna
+// no se crea ninguna. El informe tiene el resultado de cada fila.
+func (s *Service) BulkCreateInscriptions(ctx context.Context, rows []domain.BulkRow, mode string) (*domain.BulkReport, error) {
+	rowErrs := make([]error, len(rows))
+	seen := make(map[domain.BulkRow]bool, len(rows))
+	var userIDs, courseIDs []uint
+	for i, row := range rows {
+		switch {
+		case row.UserID == 0 || row.CourseID == 0:
+			rowErrs[i] = domain.ErrInvalidBulkRow
+		case seen[row]:
+			rowErrs[i] = domain.ErrDuplicatedBulkRow
+		default:
+			seen[row] = true
+			userIDs = append(userIDs, row.UserID)
+			courseIDs = append(courseIDs, row.CourseID)
+		}
+	}
+
+	userErrs := lookup(ctx, userIDs, func(ctx context.Context, id uint) error {
+		return s.httpClient.CheckUserExists(ctx, id)
+	})
+	var (
+		mu         sync.Mutex
+		capacities = map[uint]int{}
+	)
+	courseErrs := lookup(ctx, courseIDs, func(ctx context.Context, id uint) error {
+		course, err := s.httpClient.GetCourseDetails(ctx, id)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		capacities[id] = course.Capacity
+		mu.Unlock()
+		return nil
+	})
+
+	var valid []int // Índices de las filas que pasaron la validación
+	for i, row := range rows {
+		if rowErrs[i] != nil {
+			continue
+		}
+		if err := userErrs[row.UserID]; err != nil {
+			rowErrs[i] = fmt.Errorf("failed to verify user: %w", err)
+		} else if err := courseErrs[row.CourseID]; err != nil {
+			rowErrs[i] = fmt.Errorf("failed to verify course: %w", err)
+		} else {
+			valid = append(valid, i)
+		}
+	}
+
+	inscriptions := make([]*dao.InscriptionModel, len(rows))
+	allOrNothing := mode == domain.BulkAllOrNothing
+	if allOrNothing && len(valid) < len(rows) {
+		for _, i := range valid {
+			rowErrs[i] = domain.ErrBulkRolledBack
+		}
+	} else if len(valid) > 0 {
+		validRows := make([]domain.BulkRow, len(valid))
+		for j, i := range valid {
+			validRows[j] = rows[i]
+		}
+		created, errs, err := s.repository.EnrollAll(ctx, validRows, capacities, allOrNothing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import inscriptions: %w", err)
+		}
+		for j, i := range valid {
+			inscriptions[i], rowErrs[i] = created[j], errs[j]
+		}
+	}
+
+	return bulkReport(rows, mode, inscriptions, rowErrs), nil
+}
+
+// lookup consulta cada ID una sola vez, con a lo sumo bulkLookupWorkers
+// consultas a la vez, y devuelve el error de cada uno
+func lookup(ctx context.Context, ids []uint, check func(ctx context.Context, id uint) error) map[uint]error {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = map[uint]error{}
+		started = map[uint]bool{}
+		slots   = make(chan struct{}, bulkLookupWorkers)
+	)
+	for _, id := range ids {
+		if started[id] {
+			continue
+		}
+		started[id] = true
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(id uint) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := check(ctx, id); err != nil {
+				mu.Lock()
+				results[id] = err
+				mu.Unlock()
+			}
+		}(id)
+	}
+	wg.Wait()
+	return results
+}
+
+func bulkReport(rows []domain.BulkRow, mode string, inscriptions []*dao.InscriptionModel, rowErrs []error) *domain.BulkReport {
+	report := &domain.BulkReport{Mode: mode, Total: len(rows), Results: make([]domain.BulkResult, len(rows))}
+	for i, row := range rows {
+		result := domain.BulkResult{Row: i + 1, UserID: row.UserID, CourseID: row.CourseID}
+		switch err := rowErrs[i]; {
+		case errors.Is(err, domain.ErrBulkRolledBack):
+			result.Status = domain.BulkSkipped
+			result.Error = err.Error()
+		case err != nil:
+			result.Status = domain.BulkFailed
+			result.Error = err.Error()
+			report.Failed++
+		default:
+			result.Status = domain.BulkCreated
+			result.InscriptionID = inscriptions[i].ID
+			report.Created++
+		}
+		report.Results[i] = result
+	}
+	return report
+}
+
+// ExportInscriptions pasa a fn las inscripciones con alguno de los estados, en
+// orden de ID y de a lotes, así se pueden escribir sin tenerlas todas en memoria
+func (s *Service) ExportInscriptions(ctx context.Context, statuses []string, fn func([]domain.Inscription) error) error {
+	return s.repository.ExportInscriptions(ctx, statuses, exportBatchSize, func(models []dao.InscriptionModel) error {
+		return fn(s.mapModelsToDomain(models))
+	})
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	dao "inscriptions-api/DAOs/inscriptions"
+	"log"
+	"time"
+)
+
+// Parámetros de la saga de inscripción
+const (
+	sagaLease          = 30 * time.Second // Tiempo que una réplica se reserva una saga
+	sagaRecoveryBatch  = 50               // Sagas que retoma el recuperador por pasada
+	sagaRecoveryPeriod = 15 * time.Second // Cada cuánto busca sagas abandonadas
+)
+
+// sagaMetrics expone en /debug/vars cómo terminan las sagas de inscripción
+var sagaMetrics = expvar.NewMap("enrollment_sagas")
+
+// runEnrollment ejecuta el paso que le falta a la saga. Reservar el lugar y
+// guardar la inscripción pendiente ya se hicieron al iniciarla, en una
+// transacción; publish_event activa la inscripción y guarda el evento
+// enrollment.created en el outbox, en otra. courses-api actualiza el cupo del
+// curso cuando recibe el evento, así que no hace falta llamarlo.
+//
+// Las sagas guardadas en el paso update_course, de versiones anteriores, se
+// completan igual. Si falla la base de datos, la saga queda como está (con el
+// error registrado) y el recuperador la retoma cuando vence su reserva.
+func (s *Service) runEnrollment(ctx context.Context, saga dao.EnrollmentSagaModel) (*dao.InscriptionModel, error) {
+	inscription, err := s.repository.CompleteEnrollment(ctx, saga.ID)
+	if err != nil {
+		if _, recordErr := s.repository.RecordSagaError(ctx, saga.ID, err); recordErr != nil {
+			log.Printf("failed to record enrollment saga %d error: %v", saga.ID, recordErr)
+		}
+		return nil, err
+	}
+	sagaMetrics.Add("completed", 1)
+	return inscription, nil
+}
+
+// RecoverEnrollments retoma las sagas que una réplica dejó sin terminar, por
+// ejemplo porque se cayó, y las completa. Devuelve cuántas retomó.
+func (s *Service) RecoverEnrollments(ctx context.Context) (int, error) {
+	sagas, err := s.repository.ClaimStaleSagas(ctx, sagaLease, sagaRecoveryBatch)
+	if err != nil {
+		return 0, err
+	}
+	for _, saga := range sagas {
+		if _, err := s.runEnrollment(ctx, saga); err != nil {
+			log.Printf("recovered enrollment saga %d: %v", saga.ID, err)
+		}
+		sagaMetrics.Add("recovered", 1)
+	}
+	return len(sagas), nil
+}
+
+// RunRecovery busca sagas abandonadas periódicamente hasta que se cancele ctx
+func (s *Service) RunRecovery(ctx context.Context) {
+	ticker := time.NewTicker(sagaRecoveryPeriod)
+	defer ticker.Stop()
+	for {
+		if _, err := s.RecoverEnrollments(ctx); err != nil {
+			log.Printf("failed to recover enrollment sagas: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -7,13 +7,27 @@ import (
 	dao "inscriptions-api/DAOs/inscriptions"
 	"inscriptions-api/clients"
 	domain "inscriptions-api/domain/inscriptions"
+	"log"
+	"sync"
+	"time"
 )
 
 type Repository interface {
-	CreateInscription(ctx context.Context, userID, courseID uint) (*dao.InscriptionModel, error)
-	GetInscriptions(ctx context.Context) ([]dao.InscriptionModel, error)
-	GetInscriptionsByUser(ctx context.Context, userID uint) ([]dao.InscriptionModel, error)
-	GetInscriptionsByCourse(ctx context.Context, courseID uint) ([]dao.InscriptionModel, error)
+	StartEnrollment(ctx context.Context, userID, courseID uint, capacity int, lease time.Duration) (*dao.InscriptionModel, *dao.EnrollmentSagaModel, error)
+	RecordSagaError(ctx context.Context, sagaID uint, cause error) (int, error)
+	CompleteEnrollment(ctx context.Context, sagaID uint) (*dao.InscriptionModel, error)
+	EnrollAll(ctx context.Context, rows []domain.BulkRow, capacities map[uint]int, allOrNothing bool) ([]*dao.InscriptionModel, []error, error)
+	ExportInscriptions(ctx context.Context, statuses []string, batchSize int, fn func([]dao.InscriptionModel) error) error
+	ClaimStaleSagas(ctx context.Context, lease time.Duration, limit int) ([]dao.EnrollmentSagaModel, error)
+	UpdateStatus(ctx context.Context, id uint, status string) (*dao.InscriptionModel, error)
+	GetInscription(ctx context.Context, id uint) (*dao.InscriptionModel, error)
+	ListInscriptions(ctx context.Context, query domain.InscriptionQuery) ([]dao.InscriptionModel, int64, error)
+	JoinWaitlist(ctx context.Context, userID, courseID uint, capacity int) (*dao.WaitlistEntryModel, int, error)
+	LeaveWaitlist(ctx context.Context, userID, courseID uint) error
+	GetWaitlist(ctx context.Context, courseID uint) ([]dao.WaitlistEntryModel, error)
+	GetWaitlistEntry(ctx context.Context, userID, courseID uint) (*dao.WaitlistEntryModel, int, error)
+	PromoteWaitlist(ctx context.Context, courseID uint, capacity int) ([]dao.InscriptionModel, int, error)
+	SnapshotEnrollments(ctx context.Context, courseID uint) (int, error)
 }
 
 type Service struct {
@@ -25,72 +39,290 @@ func NewService(repository Repository, httpClient *clients.HTTPClient) *Service
 	return &Service{repository: repository, httpClient: httpClient}
 }
 
+// CreateInscription inscribe a un usuario en un curso con la saga de
+// inscripción (ver enrollment_saga.go). La capacidad se controla en la base de
+// datos al reservar el lugar (ver Repository.StartEnrollment), no con la
+// disponibilidad que informa courses-api, que puede estar desactualizada cuando
+// varias réplicas inscriben a la vez. Si la saga no llega a terminar, devuelve
+// la inscripción pendiente: el recuperador la completa después.
 func (s *Service) CreateInscription(ctx context.Context, userID, courseID uint) (*domain.Inscription, error) {
-	if err := s.httpClient.CheckUserExists(userID); err != nil {
-		return nil, fmt.Errorf("failed to verify user: %v", err)
+	if err := s.httpClient.CheckUserExists(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to verify user: %w", err)
 	}
 
-	course, err := s.httpClient.GetCourseDetails(courseID)
+	course, err := s.httpClient.GetCourseDetails(ctx, courseID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to verify course: %v", err)
+		return nil, fmt.Errorf("failed to verify course: %w", err)
 	}
 
-	if !course.Available {
-		return nil, errors.New("course is not available for enrollment")
+	inscriptionModel, saga, err := s.repository.StartEnrollment(ctx, userID, courseID, course.Capacity, sagaLease)
+	if err != nil {
+		if errors.Is(err, domain.ErrCourseFull) || errors.Is(err, domain.ErrAlreadyEnrolled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create inscription: %w", err)
 	}
 
-	inscriptionModel, err := s.repository.CreateInscription(ctx, userID, courseID)
+	// La saga termina aunque el cliente corte el pedido
+	completed, err := s.runEnrollment(context.WithoutCancel(ctx), *saga)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create inscription: %v", err)
+		log.Printf("enrollment saga %d left pending: %v", saga.ID, err)
+	} else {
+		inscriptionModel = completed
 	}
 
-	if err := s.httpClient.UpdateCourseAvailability(int64(inscriptionModel.CourseID)); err != nil {
-		return nil, fmt.Errorf("failed to update course availability: %v", err)
+	inscription := mapModelToDomain(*inscriptionModel)
+	return &inscription, nil
+}
+
+// CancelInscription da de baja una inscripción pendiente o activa y libera su
+// lugar, que pasa al primero de la lista de espera si la hay. courses-api se
+// entera por el evento enrollment.cancelled: si quedó un lugar libre, marca el
+// curso disponible y search-api lo vuelve a indexar.
+func (s *Service) CancelInscription(ctx context.Context, id uint) (*domain.Inscription, error) {
+	inscriptionModel, err := s.repository.UpdateStatus(ctx, id, domain.StatusCancelled)
+	if err != nil {
+		if errors.Is(err, domain.ErrInscriptionNotFound) || errors.Is(err, domain.ErrInvalidTransition) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to cancel inscription: %w", err)
 	}
 
-	return &domain.Inscription{
-		ID:       inscriptionModel.ID,
-		UserID:   inscriptionModel.UserID,
-		CourseID: inscriptionModel.CourseID,
-	}, nil
+	inscription := mapModelToDomain(*inscriptionModel)
+	return &inscription, nil
 }
 
-func (s *Service) GetInscriptions(ctx context.Context) ([]domain.Inscription, error) {
-	models, err := s.repository.GetInscriptions(ctx)
+// CompleteInscription marca como completada una inscripción activa. El lugar
+// sigue ocupado: el alumno cursó el curso.
+func (s *Service) CompleteInscription(ctx context.Context, id uint) (*domain.Inscription, error) {
+	inscriptionModel, err := s.repository.UpdateStatus(ctx, id, domain.StatusCompleted)
+	if err != nil {
+		if errors.Is(err, domain.ErrInscriptionNotFound) || errors.Is(err, domain.ErrInvalidTransition) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to complete inscription: %w", err)
+	}
+
+	inscription := mapModelToDomain(*inscriptionModel)
+	return &inscription, nil
+}
+
+func (s *Service) GetInscription(ctx context.Context, id uint) (*domain.Inscription, error) {
+	model, err := s.repository.GetInscription(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	return s.mapModelsToDomain(models), nil
+	inscription := mapModelToDomain(*model)
+	return &inscription, nil
 }
 
-func (s *Service) GetInscriptionsByUser(ctx context.Context, userID uint) ([]domain.Inscription, error) {
-	models, err := s.repository.GetInscriptionsByUser(ctx, userID)
+// ListInscriptions devuelve una página de inscripciones. Con query.ExpandCourse
+// y query.ExpandUser agrega los datos de cada curso y usuario, pidiéndolos a
+// courses-api y users-api de una vez para toda la página en vez de uno por uno.
+func (s *Service) ListInscriptions(ctx context.Context, query domain.InscriptionQuery) (*domain.InscriptionPage, error) {
+	if query.CourseID != 0 {
+		if err := s.httpClient.CheckCourseExists(ctx, query.CourseID); err != nil {
+			return nil, fmt.Errorf("failed to verify course: %w", err)
+		}
+	}
+
+	models, total, err := s.repository.ListInscriptions(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	return s.mapModelsToDomain(models), nil
+	inscriptions := s.mapModelsToDomain(models)
+	if err := s.expand(ctx, inscriptions, query.ExpandCourse, query.ExpandUser); err != nil {
+		return nil, err
+	}
+
+	return &domain.InscriptionPage{
+		Results:  inscriptions,
+		NumFound: total,
+		Offset:   query.Offset,
+		Limit:    query.Limit,
+	}, nil
+}
+
+// expand agrega a las inscripciones los datos de sus cursos y usuarios. Las dos
+// APIs se consultan a la vez, cada una con los IDs sin repetir.
+func (s *Service) expand(ctx context.Context, inscriptions []domain.Inscription, courses bool, users bool) error {
+	var courseIDs, userIDs []uint
+	seenCourses, seenUsers := map[uint]bool{}, map[uint]bool{}
+	for _, inscription := range inscriptions {
+		if courses && !seenCourses[inscription.CourseID] {
+			seenCourses[inscription.CourseID] = true
+			courseIDs = append(courseIDs, inscription.CourseID)
+		}
+		if users && !seenUsers[inscription.UserID] {
+			seenUsers[inscription.UserID] = true
+			userIDs = append(userIDs, inscription.UserID)
+		}
+	}
+
+	var (
+		wg                   sync.WaitGroup
+		courseDetails        map[uint]domain.CourseSummary
+		userDetails          map[uint]domain.UserSummary
+		coursesErr, usersErr error
+	)
+	if len(courseIDs) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			courseDetails, coursesErr = s.httpClient.GetCoursesByIDs(ctx, courseIDs)
+		}()
+	}
+	if len(userIDs) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			userDetails, usersErr = s.httpClient.GetUsersByIDs(ctx, userIDs)
+		}()
+	}
+	wg.Wait()
+	if coursesErr != nil {
+		return fmt.Errorf("failed to get courses: %w", coursesErr)
+	}
+	if usersErr != nil {
+		return fmt.Errorf("failed to get users: %w", usersErr)
+	}
+
+	for i := range inscriptions {
+		if course, ok := courseDetails[inscriptions[i].CourseID]; ok {
+			inscriptions[i].Course = &course
+		}
+		if user, ok := userDetails[inscriptions[i].UserID]; ok {
+			inscriptions[i].User = &user
+		}
+	}
+	return nil
+}
+
+// JoinWaitlist anota al usuario en la lista de espera de un curso lleno
+func (s *Service) JoinWaitlist(ctx context.Context, userID, courseID uint) (*domain.WaitlistEntry, error) {
+	if err := s.httpClient.CheckUserExists(ctx, userID); err != nil {
+		return nil, fmt.Errorf("failed to verify user: %w", err)
+	}
+
+	course, err := s.httpClient.GetCourseDetails(ctx, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify course: %w", err)
+	}
+
+	entry, position, err := s.repository.JoinWaitlist(ctx, userID, courseID, course.Capacity)
+	if err != nil {
+		if errors.Is(err, domain.ErrAlreadyEnrolled) || errors.Is(err, domain.ErrAlreadyWaitlisted) || errors.Is(err, domain.ErrSeatsAvailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to join waitlist: %w", err)
+	}
+
+	waitlistEntry := mapWaitlistEntryToDomain(*entry, position)
+	return &waitlistEntry, nil
 }
 
-func (s *Service) GetInscriptionsByCourse(ctx context.Context, courseID uint) ([]domain.Inscription, error) {
-	if err := s.httpClient.CheckCourseExists(courseID); err != nil {
-		return nil, fmt.Errorf("failed to verify course: %v", err)
+func (s *Service) LeaveWaitlist(ctx context.Context, userID, courseID uint) error {
+	return s.repository.LeaveWaitlist(ctx, userID, courseID)
+}
+
+func (s *Service) GetWaitlist(ctx context.Context, courseID uint) ([]domain.WaitlistEntry, error) {
+	entries, err := s.repository.GetWaitlist(ctx, courseID)
+	if err != nil {
+		return nil, err
+	}
+	waitlist := make([]domain.WaitlistEntry, len(entries))
+	for i, entry := range entries {
+		waitlist[i] = mapWaitlistEntryToDomain(entry, i+1)
 	}
+	return waitlist, nil
+}
 
-	models, err := s.repository.GetInscriptionsByCourse(ctx, courseID)
+func (s *Service) GetWaitlistEntry(ctx context.Context, userID, courseID uint) (*domain.WaitlistEntry, error) {
+	entry, position, err := s.repository.GetWaitlistEntry(ctx, userID, courseID)
 	if err != nil {
 		return nil, err
 	}
-	return s.mapModelsToDomain(models), nil
+	waitlistEntry := mapWaitlistEntryToDomain(*entry, position)
+	return &waitlistEntry, nil
+}
+
+// PromoteWaitlist ocupa los lugares libres de un curso con su lista de espera,
+// con la capacidad que informa courses-api
+func (s *Service) PromoteWaitlist(ctx context.Context, courseID uint) ([]domain.Inscription, error) {
+	course, err := s.httpClient.GetCourseDetails(ctx, courseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify course: %w", err)
+	}
+
+	promoted, _, err := s.repository.PromoteWaitlist(ctx, courseID, course.Capacity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to promote waitlist: %w", err)
+	}
+
+	return s.mapModelsToDomain(promoted), nil
+}
+
+// HandleCourseEvent aplica un evento de la cola courses_events. Con un aumento
+// de capacidad, los lugares nuevos son primero para la lista de espera; con un
+// pedido de inscripciones, se publican las del curso como enrollment.snapshot.
+// Los demás tipos se ignoran. Aplicar un evento repetido no cambia nada: la
+// lista ya está promovida, y courses-api descarta los snapshots que ya tiene.
+func (s *Service) HandleCourseEvent(ctx context.Context, event domain.CourseEvent) error {
+	switch event.Type {
+	case domain.EventCapacityIncreased:
+		promoted, _, err := s.repository.PromoteWaitlist(ctx, event.CourseID, event.Capacity)
+		if err != nil {
+			return fmt.Errorf("failed to promote waitlist of course %d: %w", event.CourseID, err)
+		}
+		if len(promoted) > 0 {
+			log.Printf("Curso %d: %d inscriptos desde la lista de espera", event.CourseID, len(promoted))
+		}
+	case domain.EventEnrollmentsRequested:
+		saved, err := s.repository.SnapshotEnrollments(ctx, event.CourseID)
+		if err != nil {
+			return fmt.Errorf("failed to publish inscriptions of course %d: %w", event.CourseID, err)
+		}
+		log.Printf("Curso %d: %d inscripciones enviadas a courses-api", event.CourseID, saved)
+	}
+	return nil
+}
+
+// GetCourseInstructor devuelve el ID del instructor del curso, que puede
+// administrar sus inscripciones y su lista de espera
+func (s *Service) GetCourseInstructor(ctx context.Context, courseID uint) (uint, error) {
+	course, err := s.httpClient.GetCourseDetails(ctx, courseID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify course: %w", err)
+	}
+	return course.InstructorID, nil
 }
 
 func (s *Service) mapModelsToDomain(models []dao.InscriptionModel) []domain.Inscription {
 	inscriptions := make([]domain.Inscription, len(models))
 	for i, model := range models {
-		inscriptions[i] = domain.Inscription{
-			ID:       model.ID,
-			UserID:   model.UserID,
-			CourseID: model.CourseID,
-		}
+		inscriptions[i] = mapModelToDomain(model)
 	}
 	return inscriptions
 }
+
+func mapModelToDomain(model dao.InscriptionModel) domain.Inscription {
+	return domain.Inscription{
+		ID:          model.ID,
+		UserID:      model.UserID,
+		CourseID:    model.CourseID,
+		Status:      model.Status,
+		CreatedAt:   model.CreatedAt,
+		UpdatedAt:   model.UpdatedAt,
+		CancelledAt: model.CancelledAt,
+		Version:     model.Version,
+	}
+}
+
+func mapWaitlistEntryToDomain(entry dao.WaitlistEntryModel, position int) domain.WaitlistEntry {
+	return domain.WaitlistEntry{
+		UserID:    entry.UserID,
+		CourseID:  entry.CourseID,
+		Position:  position,
+		CreatedAt: entry.CreatedAt,
+	}
+}
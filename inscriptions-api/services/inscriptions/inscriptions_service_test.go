@@ -0,0 +1,967 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	dao "inscriptions-api/DAOs/inscriptions"
+	"inscriptions-api/clients"
+	domain "inscriptions-api/domain/inscriptions"
+	repositories "inscriptions-api/repositories/inscriptions"
+	service "inscriptions-api/services/inscriptions"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"httpclient"
+)
+
+// missingUser es el único usuario que no existe en users-api
+const missingUser = 404
+
+// upstreams simula users-api y courses-api: todos los usuarios salvo
+// missingUser existen y los cursos tienen la capacidad configurada. Solo se
+// pueden consultar: courses-api se entera de las inscripciones por los eventos.
+type upstreams struct {
+	capacity int
+	writes   atomic.Int32 // Pedidos que no son consultas
+	batches  atomic.Int32 // Consultas de varios usuarios o cursos (?ids=)
+}
+
+func (u *upstreams) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method != http.MethodGet:
+		u.writes.Add(1)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	case r.URL.Query().Has("ids"):
+		u.batches.Add(1)
+		u.batch(w, r)
+	case r.URL.Path == fmt.Sprintf("/users/%d", missingUser):
+		w.WriteHeader(http.StatusNotFound)
+	case strings.HasPrefix(r.URL.Path, "/users/"):
+		w.WriteHeader(http.StatusOK)
+	case strings.HasPrefix(r.URL.Path, "/courses/"):
+		var id uint
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/courses/"), "%d", &id)
+		json.NewEncoder(w).Encode(clients.CourseDetails{ID: id, Capacity: u.capacity, Available: true})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// batch responde GET /users?ids= y GET /courses?ids= con los que existen
+func (u *upstreams) batch(w http.ResponseWriter, r *http.Request) {
+	var users []domain.UserSummary
+	var courses []domain.CourseSummary
+	for _, param := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		var id uint
+		fmt.Sscanf(param, "%d", &id)
+		switch {
+		case r.URL.Path == "/users" && id != missingUser:
+			users = append(users, domain.UserSummary{ID: id, Username: fmt.Sprintf("user%d", id)})
+		case r.URL.Path == "/courses":
+			courses = append(courses, domain.CourseSummary{ID: id, Name: fmt.Sprintf("course %d", id)})
+		}
+	}
+	if r.URL.Path == "/users" {
+		json.NewEncoder(w).Encode(users)
+		return
+	}
+	json.NewEncoder(w).Encode(courses)
+}
+
+// upstreamConfig acorta las esperas del cliente HTTP para que las pruebas no tarden
+var upstreamConfig = httpclient.Config{
+	BaseBackoff: time.Millisecond,
+	MaxBackoff:  5 * time.Millisecond,
+	OpenTimeout: 10 * time.Millisecond,
+}
+
+// replicas crea n instancias del servicio, cada una con su propia conexión a la
+// misma base de datos, como inscriptions-api1 e inscriptions-api2 detrás de nginx
+func replicas(t *testing.T, n int, capacity int) ([]*service.Service, *gorm.DB, *upstreams) {
+	t.Helper()
+	api := &upstreams{capacity: capacity}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	// BEGIN IMMEDIATE y busy_timeout hacen que SQLite espere el bloqueo como MySQL espera el de la fila
+	dsn := filepath.Join(t.TempDir(), "inscriptions.db") + "?_busy_timeout=10000&_txlock=immediate&_journal_mode=WAL"
+
+	var (
+		services []*service.Service
+		first    *gorm.DB
+	)
+	for i := 0; i < n; i++ {
+		db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), TranslateError: true})
+		if err != nil {
+			t.Fatalf("error opening database: %v", err)
+		}
+		if err := repositories.Migrate(db); err != nil {
+			t.Fatalf("error migrating database: %v", err)
+		}
+		if first == nil {
+			first = db
+		}
+		repository := repositories.NewInscriptionRepository(dao.NewInscriptionDAO(db))
+		services = append(services, service.NewService(repository, clients.NewHTTPClient(server.URL, server.URL, upstreamConfig)))
+	}
+	return services, first, api
+}
+
+// enrollConcurrently lanza todas las inscripciones a la vez, repartidas entre las réplicas
+func enrollConcurrently(services []*service.Service, userIDs []uint, courseID uint) (created int32, full int32, duplicated int32, failed []error) {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		start = make(chan struct{})
+	)
+	for i, userID := range userIDs {
+		wg.Add(1)
+		go func(svc *service.Service, userID uint) {
+			defer wg.Done()
+			<-start
+			_, err := svc.CreateInscription(context.Background(), userID, courseID)
+			switch {
+			case err == nil:
+				atomic.AddInt32(&created, 1)
+			case errors.Is(err, domain.ErrCourseFull):
+				atomic.AddInt32(&full, 1)
+			case errors.Is(err, domain.ErrAlreadyEnrolled):
+				atomic.AddInt32(&duplicated, 1)
+			default:
+				mu.Lock()
+				failed = append(failed, err)
+				mu.Unlock()
+			}
+		}(services[i%len(services)], userID)
+	}
+	close(start)
+	wg.Wait()
+	return created, full, duplicated, failed
+}
+
+// events devuelve los eventos del outbox en orden, como "tipo:usuario"
+func events(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var models []dao.EventModel
+	if err := db.Order("id").Find(&models).Error; err != nil {
+		t.Fatalf("error reading events: %v", err)
+	}
+	events := make([]string, len(models))
+	for i, model := range models {
+		var event domain.Event
+		if err := json.Unmarshal(model.Payload, &event); err != nil {
+			t.Fatalf("error decoding event: %v", err)
+		}
+		events[i] = fmt.Sprintf("%s:%d", event.Type, event.UserID)
+	}
+	return events
+}
+
+func countInscriptions(t *testing.T, db *gorm.DB, courseID uint) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&dao.InscriptionModel{}).Where("course_id = ?", courseID).Count(&count).Error; err != nil {
+		t.Fatalf("error counting inscriptions: %v", err)
+	}
+	return count
+}
+
+func TestCreateInscriptionNeverExceedsCapacity(t *testing.T) {
+	const (
+		capacity = 5
+		students = 40
+		courseID = 7
+	)
+	services, db, api := replicas(t, 2, capacity)
+
+	userIDs := make([]uint, students)
+	for i := range userIDs {
+		userIDs[i] = uint(i + 1)
+	}
+	created, full, _, failed := enrollConcurrently(services, userIDs, courseID)
+
+	if len(failed) > 0 {
+		t.Fatalf("unexpected errors: %v", failed)
+	}
+	if created != capacity {
+		t.Errorf("expected %d inscriptions, got %d", capacity, created)
+	}
+	if full != students-capacity {
+		t.Errorf("expected %d rejections for a full course, got %d", students-capacity, full)
+	}
+	if count := countInscriptions(t, db, courseID); count != capacity {
+		t.Errorf("expected %d stored inscriptions, got %d", capacity, count)
+	}
+
+	var seats dao.CourseSeatsModel
+	if err := db.First(&seats, "course_id = ?", courseID).Error; err != nil {
+		t.Fatalf("error reading course seats: %v", err)
+	}
+	if seats.Reserved != capacity {
+		t.Errorf("expected %d reserved seats, got %d", capacity, seats.Reserved)
+	}
+	var published int64
+	db.Model(&dao.EventModel{}).Where("type = ?", domain.EventEnrollmentCreated).Count(&published)
+	if published != capacity {
+		t.Errorf("expected %d enrollment.created events, got %d", capacity, published)
+	}
+	if got := api.writes.Load(); got != 0 {
+		t.Errorf("expected no calls updating courses-api, got %d", got)
+	}
+}
+
+func TestCreateInscriptionSameUserConcurrently(t *testing.T) {
+	const courseID = 3
+	services, db, _ := replicas(t, 2, 10)
+
+	userIDs := make([]uint, 20)
+	for i := range userIDs {
+		userIDs[i] = 42
+	}
+	created, _, duplicated, failed := enrollConcurrently(services, userIDs, courseID)
+
+	if len(failed) > 0 {
+		t.Fatalf("unexpected errors: %v", failed)
+	}
+	if created != 1 || duplicated != 19 {
+		t.Errorf("expected 1 inscription and 19 duplicates, got %d and %d", created, duplicated)
+	}
+
+	// Los intentos duplicados no deben quedarse con lugares
+	var seats dao.CourseSeatsModel
+	if err := db.First(&seats, "course_id = ?", courseID).Error; err != nil {
+		t.Fatalf("error reading course seats: %v", err)
+	}
+	if seats.Reserved != 1 || countInscriptions(t, db, courseID) != 1 {
+		t.Errorf("expected 1 reserved seat and 1 inscription, got %d reserved", seats.Reserved)
+	}
+}
+
+func TestCreateInscriptionCountsPreviousInscriptions(t *testing.T) {
+	const courseID = 9
+	services, db, _ := replicas(t, 1, 2)
+
+	// Inscripciones anteriores al registro de lugares
+	for _, userID := range []uint{1, 2} {
+		if err := db.Create(&dao.InscriptionModel{UserID: userID, CourseID: courseID}).Error; err != nil {
+			t.Fatalf("error creating inscription: %v", err)
+		}
+	}
+
+	if _, err := services[0].CreateInscription(context.Background(), 3, courseID); !errors.Is(err, domain.ErrCourseFull) {
+		t.Errorf("expected the course to be full, got %v", err)
+	}
+}
+
+func TestCancelInscriptionFreesSeat(t *testing.T) {
+	const courseID = 5
+	services, db, api := replicas(t, 1, 1)
+	svc := services[0]
+	ctx := context.Background()
+
+	inscription, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if inscription.Status != domain.StatusActive {
+		t.Errorf("expected a new inscription to be active, got %q", inscription.Status)
+	}
+	if _, err := svc.CreateInscription(ctx, 2, courseID); !errors.Is(err, domain.ErrCourseFull) {
+		t.Fatalf("expected the course to be full, got %v", err)
+	}
+
+	cancelled, err := svc.CancelInscription(ctx, inscription.ID)
+	if err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+	if cancelled.Status != domain.StatusCancelled || cancelled.CancelledAt == nil {
+		t.Errorf("expected a cancelled inscription with cancelled_at, got %+v", cancelled)
+	}
+	if got := api.writes.Load(); got != 0 {
+		t.Errorf("expected no calls updating courses-api, got %d", got)
+	}
+
+	// El lugar liberado lo puede ocupar otro alumno, y la baja no cuenta como inscripto
+	if _, err := svc.CreateInscription(ctx, 2, courseID); err != nil {
+		t.Fatalf("expected the freed seat to be available, got %v", err)
+	}
+	page, err := svc.ListInscriptions(ctx, domain.InscriptionQuery{CourseID: courseID, Statuses: domain.SeatHoldingStatuses, Limit: 10})
+	if err != nil {
+		t.Fatalf("error listing inscriptions: %v", err)
+	}
+	enrolled := page.Results
+	if len(enrolled) != 1 || enrolled[0].UserID != 2 {
+		t.Errorf("expected only user 2 to be enrolled, got %+v", enrolled)
+	}
+
+	var seats dao.CourseSeatsModel
+	if err := db.First(&seats, "course_id = ?", courseID).Error; err != nil {
+		t.Fatalf("error reading course seats: %v", err)
+	}
+	if seats.Reserved != 1 {
+		t.Errorf("expected 1 reserved seat, got %d", seats.Reserved)
+	}
+
+	if _, err := svc.CancelInscription(ctx, inscription.ID); !errors.Is(err, domain.ErrInvalidTransition) {
+		t.Errorf("expected cancelling twice to fail, got %v", err)
+	}
+	if _, err := svc.CancelInscription(ctx, 999); !errors.Is(err, domain.ErrInscriptionNotFound) {
+		t.Errorf("expected a missing inscription, got %v", err)
+	}
+}
+
+func TestCancelInscriptionConcurrently(t *testing.T) {
+	const courseID = 6
+	services, db, _ := replicas(t, 2, 3)
+	ctx := context.Background()
+
+	inscription, err := services[0].CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		cancelled atomic.Int32
+		start     = make(chan struct{})
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(svc *service.Service) {
+			defer wg.Done()
+			<-start
+			if _, err := svc.CancelInscription(ctx, inscription.ID); err == nil {
+				cancelled.Add(1)
+			} else if !errors.Is(err, domain.ErrInvalidTransition) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(services[i%len(services)])
+	}
+	close(start)
+	wg.Wait()
+
+	if got := cancelled.Load(); got != 1 {
+		t.Errorf("expected exactly one cancellation, got %d", got)
+	}
+	// Cada baja libera un solo lugar, aunque se pida varias veces
+	var seats dao.CourseSeatsModel
+	if err := db.First(&seats, "course_id = ?", courseID).Error; err != nil {
+		t.Fatalf("error reading course seats: %v", err)
+	}
+	if seats.Reserved != 0 {
+		t.Errorf("expected 0 reserved seats, got %d", seats.Reserved)
+	}
+}
+
+func TestWaitlistPromotesInOrder(t *testing.T) {
+	const courseID = 11
+	services, db, api := replicas(t, 1, 1)
+	svc := services[0]
+	ctx := context.Background()
+
+	first, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if _, err := svc.JoinWaitlist(ctx, 1, courseID); !errors.Is(err, domain.ErrAlreadyEnrolled) {
+		t.Errorf("expected an enrolled user not to join the waitlist, got %v", err)
+	}
+	for i, userID := range []uint{2, 3, 4} {
+		entry, err := svc.JoinWaitlist(ctx, userID, courseID)
+		if err != nil {
+			t.Fatalf("error joining waitlist: %v", err)
+		}
+		if entry.Position != i+1 {
+			t.Errorf("expected user %d at position %d, got %d", userID, i+1, entry.Position)
+		}
+	}
+	if _, err := svc.JoinWaitlist(ctx, 2, courseID); !errors.Is(err, domain.ErrAlreadyWaitlisted) {
+		t.Errorf("expected joining twice to fail, got %v", err)
+	}
+
+	// Al salir de la lista, los que estaban detrás avanzan
+	if err := svc.LeaveWaitlist(ctx, 2, courseID); err != nil {
+		t.Fatalf("error leaving waitlist: %v", err)
+	}
+	entry, err := svc.GetWaitlistEntry(ctx, 3, courseID)
+	if err != nil || entry.Position != 1 {
+		t.Fatalf("expected user 3 to be first, got %+v, %v", entry, err)
+	}
+
+	// La baja le da el lugar al primero de la lista
+	if _, err := svc.CancelInscription(ctx, first.ID); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+	page, err := svc.ListInscriptions(ctx, domain.InscriptionQuery{CourseID: courseID, Statuses: domain.SeatHoldingStatuses, Limit: 10})
+	if err != nil {
+		t.Fatalf("error listing inscriptions: %v", err)
+	}
+	enrolled := page.Results
+	if len(enrolled) != 1 || enrolled[0].UserID != 3 {
+		t.Fatalf("expected user 3 to be promoted, got %+v", enrolled)
+	}
+	if _, err := svc.GetWaitlistEntry(ctx, 3, courseID); !errors.Is(err, domain.ErrNotWaitlisted) {
+		t.Errorf("expected user 3 to leave the waitlist, got %v", err)
+	}
+
+	// Nadie puede quedarse con el lugar de alguien que está esperando
+	if _, err := svc.CreateInscription(ctx, 9, courseID); !errors.Is(err, domain.ErrCourseFull) {
+		t.Errorf("expected the course to be full, got %v", err)
+	}
+
+	// Con más capacidad, los que esperan se inscriben antes que nadie
+	api.capacity = 3
+	promoted, err := svc.PromoteWaitlist(ctx, courseID)
+	if err != nil {
+		t.Fatalf("error promoting waitlist: %v", err)
+	}
+	if len(promoted) != 1 || promoted[0].UserID != 4 {
+		t.Errorf("expected user 4 to be promoted, got %+v", promoted)
+	}
+	waitlist, err := svc.GetWaitlist(ctx, courseID)
+	if err != nil || len(waitlist) != 0 {
+		t.Errorf("expected an empty waitlist, got %+v, %v", waitlist, err)
+	}
+	if _, err := svc.JoinWaitlist(ctx, 5, courseID); !errors.Is(err, domain.ErrSeatsAvailable) {
+		t.Errorf("expected joining with seats left to fail, got %v", err)
+	}
+
+	var events []dao.EventModel
+	if err := db.Where("type = ?", domain.EventWaitlistPromoted).Order("id").Find(&events).Error; err != nil {
+		t.Fatalf("error reading events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 promotion events, got %d", len(events))
+	}
+	for i, userID := range []uint{3, 4} {
+		var event domain.Event
+		if err := json.Unmarshal(events[i].Payload, &event); err != nil {
+			t.Fatalf("error decoding event: %v", err)
+		}
+		if event.Type != domain.EventWaitlistPromoted || event.UserID != userID || event.CourseID != courseID {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestCourseEvents(t *testing.T) {
+	const courseID = 12
+	services, db, _ := replicas(t, 1, 1)
+	svc := services[0]
+	ctx := context.Background()
+
+	first, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if _, err := svc.JoinWaitlist(ctx, 2, courseID); err != nil {
+		t.Fatalf("error joining waitlist: %v", err)
+	}
+
+	// El aumento de capacidad llega como evento, con la capacidad nueva
+	if err := svc.HandleCourseEvent(ctx, domain.CourseEvent{Type: domain.EventCapacityIncreased, CourseID: courseID, Capacity: 2}); err != nil {
+		t.Fatalf("error handling capacity event: %v", err)
+	}
+	if _, err := svc.GetWaitlistEntry(ctx, 2, courseID); !errors.Is(err, domain.ErrNotWaitlisted) {
+		t.Errorf("expected user 2 to be promoted, got %v", err)
+	}
+	if _, err := svc.CancelInscription(ctx, first.ID); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+
+	// El pedido de inscripciones publica el estado actual de cada una, sin
+	// cambiar su versión
+	before := len(events(t, db))
+	if err := svc.HandleCourseEvent(ctx, domain.CourseEvent{Type: domain.EventEnrollmentsRequested, CourseID: courseID}); err != nil {
+		t.Fatalf("error handling enrollments request: %v", err)
+	}
+	var models []dao.EventModel
+	if err := db.Order("id").Offset(before).Find(&models).Error; err != nil {
+		t.Fatalf("error reading events: %v", err)
+	}
+	var got []string
+	for _, model := range models {
+		var event domain.Event
+		if err := json.Unmarshal(model.Payload, &event); err != nil {
+			t.Fatalf("error decoding event: %v", err)
+		}
+		got = append(got, fmt.Sprintf("%s:%d:%s:%d", event.Type, event.UserID, event.Status, event.Version))
+	}
+	want := []string{
+		domain.EventEnrollmentSnapshot + ":1:" + domain.StatusCancelled + ":2",
+		domain.EventEnrollmentSnapshot + ":2:" + domain.StatusActive + ":2",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+	cancelled, err := svc.GetInscription(ctx, first.ID)
+	if err != nil || cancelled.Version != 2 {
+		t.Errorf("expected the snapshot to keep version 2, got %+v, %v", cancelled, err)
+	}
+}
+
+func TestPublishPendingEvents(t *testing.T) {
+	_, db, _ := replicas(t, 1, 1)
+	repository := repositories.NewInscriptionRepository(dao.NewInscriptionDAO(db))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		event := dao.EventModel{EventID: fmt.Sprintf("event-%d", i), Type: domain.EventWaitlistPromoted, Payload: []byte("{}")}
+		if err := db.Create(&event).Error; err != nil {
+			t.Fatalf("error creating event: %v", err)
+		}
+	}
+
+	// Un error de publicación detiene el lote, pero los ya publicados quedan marcados
+	var sent []string
+	published, err := repository.PublishPending(ctx, 10, func(event dao.EventModel) error {
+		if event.EventID == "event-1" {
+			return errors.New("broker unavailable")
+		}
+		sent = append(sent, event.EventID)
+		return nil
+	})
+	if err == nil || published != 1 {
+		t.Fatalf("expected 1 event published and an error, got %d, %v", published, err)
+	}
+
+	published, err = repository.PublishPending(ctx, 10, func(event dao.EventModel) error {
+		sent = append(sent, event.EventID)
+		return nil
+	})
+	if err != nil || published != 2 {
+		t.Fatalf("expected the other 2 events to be published, got %d, %v", published, err)
+	}
+	if strings.Join(sent, ",") != "event-0,event-1,event-2" {
+		t.Errorf("expected events in order, got %v", sent)
+	}
+}
+
+func TestReenrollAfterCancelReusesInscription(t *testing.T) {
+	const courseID = 13
+	services, db, _ := replicas(t, 1, 2)
+	svc := services[0]
+	ctx := context.Background()
+
+	first, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if _, err := svc.CancelInscription(ctx, first.ID); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+
+	again, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error enrolling again: %v", err)
+	}
+	if again.ID != first.ID || again.Status != domain.StatusActive || again.CancelledAt != nil {
+		t.Errorf("expected inscription %d to be reactivated, got %+v", first.ID, again)
+	}
+	if count := countInscriptions(t, db, courseID); count != 1 {
+		t.Errorf("expected 1 stored inscription, got %d", count)
+	}
+
+	// La base rechaza una segunda fila para el mismo usuario y curso
+	err = db.Create(&dao.InscriptionModel{UserID: 1, CourseID: courseID, Status: domain.StatusActive}).Error
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Errorf("expected a duplicated key error, got %v", err)
+	}
+}
+
+func TestMigrateReportsDuplicateInscriptions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "legacy.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("error opening database: %v", err)
+	}
+	// Una base de antes del índice único, con el usuario 1 inscripto dos veces
+	if err := db.Exec("CREATE TABLE inscription_models (id integer PRIMARY KEY AUTOINCREMENT, user_id integer NOT NULL, course_id integer NOT NULL)").Error; err != nil {
+		t.Fatalf("error creating legacy table: %v", err)
+	}
+	if err := db.Exec("INSERT INTO inscription_models (user_id, course_id) VALUES (1, 2), (3, 2), (1, 2)").Error; err != nil {
+		t.Fatalf("error inserting inscriptions: %v", err)
+	}
+
+	err = repositories.Migrate(db)
+	if !errors.Is(err, domain.ErrDuplicateInscriptions) || !strings.Contains(err.Error(), "user 1 in course 2 (inscriptions [1 3])") {
+		t.Fatalf("expected the duplicates to be reported, got %v", err)
+	}
+	var count int64
+	if err := db.Table("inscription_models").Count(&count).Error; err != nil || count != 3 {
+		t.Errorf("expected no inscription to be deleted, got %d (%v)", count, err)
+	}
+
+	// Resueltas a mano, la migración crea el índice
+	if err := db.Exec("DELETE FROM inscription_models WHERE id = 3").Error; err != nil {
+		t.Fatalf("error resolving duplicates: %v", err)
+	}
+	if err := repositories.Migrate(db); err != nil {
+		t.Errorf("expected the migration to succeed, got %v", err)
+	}
+}
+
+func TestEnrollmentEventsCarryInscriptionVersion(t *testing.T) {
+	const courseID = 14
+	services, db, _ := replicas(t, 1, 2)
+	svc := services[0]
+	ctx := context.Background()
+
+	first, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if _, err := svc.CancelInscription(ctx, first.ID); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+	again, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error enrolling again: %v", err)
+	}
+	if again.Version != 3 {
+		t.Errorf("expected the reactivated inscription at version 3, got %d", again.Version)
+	}
+
+	// Aunque courses-api reciba la baja después de la reinscripción, la
+	// versión le indica cuál es la última
+	var models []dao.EventModel
+	if err := db.Order("id").Find(&models).Error; err != nil {
+		t.Fatalf("error reading events: %v", err)
+	}
+	var got []string
+	for _, model := range models {
+		var event domain.Event
+		if err := json.Unmarshal(model.Payload, &event); err != nil {
+			t.Fatalf("error decoding event: %v", err)
+		}
+		if event.InscriptionID == first.ID {
+			got = append(got, fmt.Sprintf("%s:%d", event.Type, event.Version))
+		}
+	}
+	want := []string{
+		domain.EventEnrollmentCreated + ":1",
+		domain.EventEnrollmentCancelled + ":2",
+		domain.EventEnrollmentCreated + ":3",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestEnrollmentEventsFollowSeats(t *testing.T) {
+	const courseID = 15
+	services, db, _ := replicas(t, 1, 1)
+	svc := services[0]
+	ctx := context.Background()
+
+	first, err := svc.CreateInscription(ctx, 1, courseID)
+	if err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if _, err := svc.JoinWaitlist(ctx, 2, courseID); err != nil {
+		t.Fatalf("error joining waitlist: %v", err)
+	}
+
+	// La baja libera el lugar y el que espera lo ocupa: courses-api tiene que
+	// ver una baja y un alta, en ese orden, para que el curso siga lleno
+	if _, err := svc.CancelInscription(ctx, first.ID); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+	second, err := svc.CreateInscription(ctx, 1, courseID)
+	if !errors.Is(err, domain.ErrCourseFull) {
+		t.Fatalf("expected the course to be full, got %+v, %v", second, err)
+	}
+
+	want := []string{
+		domain.EventEnrollmentCreated + ":1",
+		domain.EventEnrollmentCancelled + ":1",
+		domain.EventWaitlistPromoted + ":2",
+		domain.EventEnrollmentCreated + ":2",
+	}
+	if got := events(t, db); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestRecoverEnrollmentsResumesAbandonedSagas(t *testing.T) {
+	const courseID = 17
+	services, db, api := replicas(t, 2, 1)
+	repository := repositories.NewInscriptionRepository(dao.NewInscriptionDAO(db))
+	ctx := context.Background()
+
+	// Una réplica reservó el lugar y se cayó antes de terminar
+	inscription, _, err := repository.StartEnrollment(ctx, 1, courseID, 1, 0)
+	if err != nil {
+		t.Fatalf("error starting enrollment: %v", err)
+	}
+	if inscription.Status != domain.StatusPending {
+		t.Fatalf("expected a pending inscription, got %q", inscription.Status)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Las dos réplicas buscan sagas abandonadas a la vez; solo una la retoma
+	var (
+		wg        sync.WaitGroup
+		recovered atomic.Int32
+	)
+	for _, svc := range services {
+		wg.Add(1)
+		go func(svc *service.Service) {
+			defer wg.Done()
+			n, err := svc.RecoverEnrollments(ctx)
+			if err != nil {
+				t.Errorf("error recovering enrollments: %v", err)
+			}
+			recovered.Add(int32(n))
+		}(svc)
+	}
+	wg.Wait()
+
+	if recovered.Load() != 1 {
+		t.Errorf("expected 1 recovered saga, got %d", recovered.Load())
+	}
+	got, err := services[0].GetInscription(ctx, inscription.ID)
+	if err != nil || got.Status != domain.StatusActive {
+		t.Fatalf("expected the inscription to be active, got %+v, %v", got, err)
+	}
+	if api.writes.Load() != 0 {
+		t.Errorf("expected no calls updating courses-api, got %d", api.writes.Load())
+	}
+	var created int64
+	db.Model(&dao.EventModel{}).Where("type = ?", domain.EventEnrollmentCreated).Count(&created)
+	if created != 1 {
+		t.Errorf("expected 1 enrollment.created event, got %d", created)
+	}
+
+	// Una saga terminada no se vuelve a retomar
+	if n, err := services[0].RecoverEnrollments(ctx); err != nil || n != 0 {
+		t.Errorf("expected nothing to recover, got %d, %v", n, err)
+	}
+}
+
+// bulkStatuses resume el informe de una importación como "estado:usuario"
+func bulkStatuses(report *domain.BulkReport) string {
+	statuses := make([]string, len(report.Results))
+	for i, result := range report.Results {
+		statuses[i] = fmt.Sprintf("%s:%d", result.Status, result.UserID)
+	}
+	return strings.Join(statuses, ",")
+}
+
+func TestBulkCreateInscriptionsPartial(t *testing.T) {
+	const courseID = 20
+	services, db, api := replicas(t, 1, 2)
+	svc := services[0]
+	ctx := context.Background()
+
+	if _, err := svc.CreateInscription(ctx, 1, courseID); err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+
+	rows := []domain.BulkRow{
+		{UserID: 1, CourseID: courseID},           // Ya inscripto
+		{UserID: 2, CourseID: courseID},           // Ocupa el último lugar
+		{UserID: 2, CourseID: courseID},           // Repetida
+		{UserID: 0, CourseID: courseID},           // Inválida
+		{UserID: missingUser, CourseID: courseID}, // No existe
+		{UserID: 3, CourseID: courseID},           // Curso lleno
+		{UserID: 3, CourseID: courseID + 1},       // Otro curso
+	}
+	report, err := svc.BulkCreateInscriptions(ctx, rows, domain.BulkPartial)
+	if err != nil {
+		t.Fatalf("error importing inscriptions: %v", err)
+	}
+
+	want := "failed:1,created:2,failed:2,failed:0,failed:404,failed:3,created:3"
+	if got := bulkStatuses(report); got != want {
+		t.Errorf("expected results %s, got %s", want, got)
+	}
+	if report.Total != len(rows) || report.Created != 2 || report.Failed != 5 {
+		t.Errorf("unexpected totals %+v", report)
+	}
+	for i, wantErr := range map[int]error{0: domain.ErrAlreadyEnrolled, 2: domain.ErrDuplicatedBulkRow, 3: domain.ErrInvalidBulkRow, 4: domain.ErrUserNotFound, 5: domain.ErrCourseFull} {
+		if !strings.Contains(report.Results[i].Error, wantErr.Error()) {
+			t.Errorf("expected row %d to fail with %q, got %q", i+1, wantErr, report.Results[i].Error)
+		}
+	}
+	if count := countInscriptions(t, db, courseID); count != 2 {
+		t.Errorf("expected the course to stay at its capacity of 2, got %d inscriptions", count)
+	}
+	if got := len(events(t, db)); got != 3 {
+		t.Errorf("expected an enrollment.created event per inscription, got %d", got)
+	}
+	if writes := api.writes.Load(); writes != 0 {
+		t.Errorf("expected no writes to the other APIs, got %d", writes)
+	}
+}
+
+func TestBulkCreateInscriptionsAllOrNothing(t *testing.T) {
+	const courseID = 21
+	services, db, _ := replicas(t, 1, 2)
+	svc := services[0]
+	ctx := context.Background()
+
+	// La tercera fila no entra: no se crea ninguna
+	rows := []domain.BulkRow{{UserID: 1, CourseID: courseID}, {UserID: 2, CourseID: courseID}, {UserID: 3, CourseID: courseID}}
+	report, err := svc.BulkCreateInscriptions(ctx, rows, domain.BulkAllOrNothing)
+	if err != nil {
+		t.Fatalf("error importing inscriptions: %v", err)
+	}
+	if got := bulkStatuses(report); got != "skipped:1,skipped:2,failed:3" {
+		t.Errorf("unexpected results %s", got)
+	}
+	if count := countInscriptions(t, db, courseID); count != 0 {
+		t.Errorf("expected no inscriptions after the rollback, got %d", count)
+	}
+	if got := events(t, db); len(got) != 0 {
+		t.Errorf("expected no events after the rollback, got %v", got)
+	}
+
+	// Los lugares que reservó la transacción también se liberaron
+	report, err = svc.BulkCreateInscriptions(ctx, rows[:2], domain.BulkAllOrNothing)
+	if err != nil {
+		t.Fatalf("error importing inscriptions: %v", err)
+	}
+	if report.Created != 2 {
+		t.Errorf("expected both inscriptions to be created, got %s", bulkStatuses(report))
+	}
+
+	// Una fila inválida también impide crear las demás, sin tocar la base
+	report, err = svc.BulkCreateInscriptions(ctx, []domain.BulkRow{{UserID: 4, CourseID: courseID + 1}, {UserID: missingUser, CourseID: courseID + 1}}, domain.BulkAllOrNothing)
+	if err != nil {
+		t.Fatalf("error importing inscriptions: %v", err)
+	}
+	if got := bulkStatuses(report); got != "skipped:4,failed:404" {
+		t.Errorf("unexpected results %s", got)
+	}
+	if count := countInscriptions(t, db, courseID+1); count != 0 {
+		t.Errorf("expected no inscriptions, got %d", count)
+	}
+}
+
+func TestExportInscriptions(t *testing.T) {
+	const courseID = 22
+	services, _, _ := replicas(t, 1, 10)
+	svc := services[0]
+	ctx := context.Background()
+
+	var rows []domain.BulkRow
+	for userID := uint(1); userID <= 7; userID++ {
+		rows = append(rows, domain.BulkRow{UserID: userID, CourseID: courseID})
+	}
+	report, err := svc.BulkCreateInscriptions(ctx, rows, domain.BulkPartial)
+	if err != nil || report.Created != len(rows) {
+		t.Fatalf("error importing inscriptions: %+v, %v", report, err)
+	}
+	if _, err := svc.CancelInscription(ctx, report.Results[0].InscriptionID); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+
+	var exported []uint
+	err = svc.ExportInscriptions(ctx, []string{domain.StatusActive}, func(inscriptions []domain.Inscription) error {
+		for _, inscription := range inscriptions {
+			exported = append(exported, inscription.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error exporting inscriptions: %v", err)
+	}
+	if got := fmt.Sprint(exported); got != "[2 3 4 5 6 7]" {
+		t.Errorf("expected the active inscriptions in order, got %s", got)
+	}
+}
+
+func TestListInscriptions(t *testing.T) {
+	services, db, api := replicas(t, 1, 10)
+	svc := services[0]
+	ctx := context.Background()
+
+	// Cinco inscripciones de dos usuarios en tres cursos, más una de un usuario
+	// que ya no existe en users-api
+	rows := []domain.BulkRow{
+		{UserID: 1, CourseID: 30}, {UserID: 1, CourseID: 31}, {UserID: 1, CourseID: 32},
+		{UserID: 2, CourseID: 30}, {UserID: 2, CourseID: 31},
+	}
+	if report, err := svc.BulkCreateInscriptions(ctx, rows, domain.BulkPartial); err != nil || report.Created != len(rows) {
+		t.Fatalf("error importing inscriptions: %+v, %v", report, err)
+	}
+	if err := db.Create(&dao.InscriptionModel{UserID: missingUser, CourseID: 30, Status: domain.StatusActive}).Error; err != nil {
+		t.Fatalf("error creating inscription: %v", err)
+	}
+	if _, err := svc.CancelInscription(ctx, 2); err != nil {
+		t.Fatalf("error cancelling inscription: %v", err)
+	}
+	old := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := db.Model(&dao.InscriptionModel{}).Where("id = ?", 3).Update("created_at", old).Error; err != nil {
+		t.Fatalf("error updating inscription: %v", err)
+	}
+
+	ids := func(page *domain.InscriptionPage) string {
+		var ids []string
+		for _, inscription := range page.Results {
+			ids = append(ids, fmt.Sprint(inscription.ID))
+		}
+		return strings.Join(ids, ",")
+	}
+	since := old.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		query domain.InscriptionQuery
+		want  string
+		total int64
+	}{
+		{"first page", domain.InscriptionQuery{Limit: 4}, "1,2,3,4", 6},
+		{"last page", domain.InscriptionQuery{Offset: 4, Limit: 4}, "5,6", 6},
+		{"user and status", domain.InscriptionQuery{UserID: 1, Statuses: domain.SeatHoldingStatuses, Limit: 10}, "1,3", 2},
+		{"course", domain.InscriptionQuery{CourseID: 31, Limit: 10}, "2,5", 2},
+		{"created since", domain.InscriptionQuery{UserID: 1, CreatedFrom: &since, Limit: 10}, "1,2", 2},
+		{"created until", domain.InscriptionQuery{CreatedTo: &old, Limit: 10}, "3", 1},
+	}
+	for _, test := range tests {
+		page, err := svc.ListInscriptions(ctx, test.query)
+		if err != nil {
+			t.Fatalf("%s: error listing inscriptions: %v", test.name, err)
+		}
+		if got := ids(page); got != test.want || page.NumFound != test.total {
+			t.Errorf("%s: expected inscriptions %s of %d, got %s of %d", test.name, test.want, test.total, got, page.NumFound)
+		}
+		if page.Results[0].Course != nil || page.Results[0].User != nil {
+			t.Errorf("%s: expected no details without expand", test.name)
+		}
+	}
+	if got := api.batches.Load(); got != 0 {
+		t.Errorf("expected no lookups without expand, got %d", got)
+	}
+
+	// Con expand, una sola consulta a cada API para toda la página
+	page, err := svc.ListInscriptions(ctx, domain.InscriptionQuery{Limit: 10, ExpandCourse: true, ExpandUser: true})
+	if err != nil {
+		t.Fatalf("error listing inscriptions: %v", err)
+	}
+	if got := api.batches.Load(); got != 2 {
+		t.Errorf("expected one lookup per API, got %d", got)
+	}
+	for _, inscription := range page.Results {
+		if inscription.Course == nil || inscription.Course.Name != fmt.Sprintf("course %d", inscription.CourseID) {
+			t.Errorf("expected inscription %d to include its course, got %+v", inscription.ID, inscription.Course)
+		}
+		switch {
+		case inscription.UserID == missingUser && inscription.User != nil:
+			t.Errorf("expected no details for a missing user, got %+v", inscription.User)
+		case inscription.UserID != missingUser && (inscription.User == nil || inscription.User.Username != fmt.Sprintf("user%d", inscription.UserID)):
+			t.Errorf("expected inscription %d to include its user, got %+v", inscription.ID, inscription.User)
+		}
+	}
+}
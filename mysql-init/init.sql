@@ -1,2 +1,3 @@
    CREATE DATABASE IF NOT EXISTS users;
-   CREATE DATABASE IF NOT EXISTS inscriptions;
\ No newline at end of file
+   CREATE DATABASE IF NOT EXISTS inscriptions;
+   CREATE DATABASE IF NOT EXISTS search;
\ No newline at end of file
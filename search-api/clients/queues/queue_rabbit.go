@@ -2,67 +2,398 @@ package queues
 
 import (
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
 	"search-api/domain/courses"
+	"sync"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// Cabeceras que acompañan a un mensaje en sus reintentos y en la cola de mensajes muertos
+const (
+	headerRetryCount = "x-retry-count"
+	headerLastError  = "x-last-error"
+	headerFailedAt   = "x-failed-at"
+)
+
+// Valores por defecto de la política de reintentos, del pool de workers y de la reconexión
+const (
+	defaultMaxRetries      = 5
+	defaultRetryDelay      = 10 * time.Second
+	defaultWorkers         = 4
+	defaultPrefetch        = 32
+	defaultMetricsInterval = 15 * time.Second
+	defaultMinBackoff      = 500 * time.Millisecond
+	defaultMaxBackoff      = 30 * time.Second
+)
+
+// errClosed indica que el consumidor se cerró y no hay que reconectarse
+var errClosed = errors.New("el consumidor de RabbitMQ está cerrado")
+
+// metrics expone en /debug/vars la profundidad de las colas y los contadores del consumidor
+var (
+	metrics    = expvar.NewMap("rabbitmq")
+	queueDepth = new(expvar.Map).Init()
+)
+
+func init() {
+	metrics.Set("queue_depth", queueDepth)
+}
+
 // RabbitConfig define la configuración para conectarse a RabbitMQ
 type RabbitConfig struct {
-	Host      string
-	Port      string
-	Username  string
-	Password  string
-	QueueName string
+	Host       string
+	Port       string
+	Username   string
+	Password   string
+	QueueName  string
+	MaxRetries int           // Reintentos antes de enviar el mensaje a la cola de mensajes muertos
+	RetryDelay time.Duration // Tiempo que espera un mensaje en la cola de reintentos
+
+	Workers         int           // Goroutines que procesan eventos en paralelo
+	Prefetch        int           // Mensajes sin confirmar que el broker entrega por adelantado (QoS)
+	MetricsInterval time.Duration // Cada cuánto se consulta la profundidad de las colas
+	MinBackoff      time.Duration // Espera inicial entre reconexiones
+	MaxBackoff      time.Duration // Espera máxima entre reconexiones
 }
 
-// Rabbit representa una conexión de RabbitMQ
-type Rabbit struct {
+// session es una conexión con RabbitMQ y sus canales. Si se cierra la conexión
+// o cualquiera de los canales, se reemplaza entera por una nueva.
+type session struct {
 	connection *amqp.Connection
-	channel    *amqp.Channel
-	queue      amqp.Queue
+	channel    *amqp.Channel // Consumo de la cola principal
+
+	// publisher publica reintentos y mensajes muertos con confirmación del broker
+	publisher *amqp.Channel
+	confirms  chan amqp.Confirmation
+
+	// admin atiende las operaciones administrativas sobre la cola de mensajes muertos
+	admin *amqp.Channel
 }
 
-// NewRabbit crea una nueva conexión a RabbitMQ y declara la cola
-func NewRabbit(config RabbitConfig) Rabbit {
-	connStr := fmt.Sprintf("amqp://%s:%s@%s:%s/", config.Username, config.Password, config.Host, config.Port)
-	connection, err := amqp.Dial(connStr)
-	if err != nil {
+// close cierra los canales y la conexión que sigan abiertos
+func (s *session) close() {
+	for _, channel := range []*amqp.Channel{s.admin, s.publisher, s.channel} {
+		if channel != nil {
+			channel.Close()
+		}
+	}
+	s.connection.Close()
+}
+
+// Rabbit representa una conexión de RabbitMQ.
+//
+// La cola principal la declara también courses-api, por eso no lleva argumentos
+// de dead-lettering: los reintentos y los mensajes muertos se publican de forma
+// explícita. La cola <cola>.retry devuelve los mensajes a la principal al vencer
+// su TTL y el exchange <cola>.dlx enruta los descartados a <cola>.dlq.
+//
+// Si se pierde la conexión, se reconecta con backoff exponencial, vuelve a
+// declarar la topología y reinicia los workers sobre el nuevo canal.
+type Rabbit struct {
+	uri         string
+	queueName   string
+	retryQueue  string
+	retryDelay  time.Duration
+	deadLetterX string
+	deadLetterQ string
+	maxRetries  int
+
+	workers         int
+	prefetch        int
+	metricsInterval time.Duration
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+	done            chan struct{}
+
+	// connectMu ordena los reemplazos de la sesión y el inicio del consumo
+	connectMu sync.Mutex
+	mu        sync.RWMutex
+	session   *session
+	handler   func(courses.CourseUpdate) error
+	backlog   []chan delivery
+
+	// working cuenta los workers de la sesión actual: los de la nueva no
+	// arrancan hasta que terminen, así dos eventos de un curso nunca se
+	// procesan a la vez
+	working sync.WaitGroup
+
+	publisherMu sync.Mutex
+	adminMu     sync.Mutex
+}
+
+// NewRabbit crea una nueva conexión a RabbitMQ y declara la cola junto con su topología de reintentos
+func NewRabbit(config RabbitConfig) *Rabbit {
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultWorkers
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = defaultPrefetch
+	}
+	if config.Prefetch < config.Workers {
+		config.Prefetch = config.Workers // Con menos mensajes en vuelo habría workers ociosos
+	}
+	if config.MetricsInterval <= 0 {
+		config.MetricsInterval = defaultMetricsInterval
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = defaultMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+
+	rabbit := &Rabbit{
+		uri:         fmt.Sprintf("amqp://%s:%s@%s:%s/", config.Username, config.Password, config.Host, config.Port),
+		queueName:   config.QueueName,
+		retryQueue:  config.QueueName + ".retry",
+		retryDelay:  config.RetryDelay,
+		deadLetterX: config.QueueName + ".dlx",
+		deadLetterQ: config.QueueName + ".dlq",
+		maxRetries:  config.MaxRetries,
+
+		workers:         config.Workers,
+		prefetch:        config.Prefetch,
+		metricsInterval: config.MetricsInterval,
+		minBackoff:      config.MinBackoff,
+		maxBackoff:      config.MaxBackoff,
+		done:            make(chan struct{}),
+	}
+
+	if err := rabbit.connect(); err != nil {
 		log.Fatalf("Error al conectar con RabbitMQ: %v", err)
 	}
+	return rabbit
+}
+
+// connect abre una sesión nueva, reinicia el consumo sobre ella si ya había
+// empezado y la deja vigilando el cierre de la conexión
+func (rabbit *Rabbit) connect() error {
+	rabbit.connectMu.Lock()
+	defer rabbit.connectMu.Unlock()
 
-	channel, err := connection.Channel()
+	s, err := rabbit.open()
 	if err != nil {
-		log.Fatalf("Error al crear el canal de RabbitMQ: %v", err)
+		return err
 	}
 
-	queue, err := channel.QueueDeclare(
-		config.QueueName,
-		false, // No persistente
+	rabbit.mu.RLock()
+	handler := rabbit.handler
+	rabbit.mu.RUnlock()
+	if handler != nil {
+		if err := rabbit.consume(s, handler); err != nil {
+			s.close()
+			return err
+		}
+	}
+
+	// Se registran antes de publicar la sesión para no perder un cierre temprano
+	closed := []chan *amqp.Error{
+		s.connection.NotifyClose(make(chan *amqp.Error, 1)),
+		s.channel.NotifyClose(make(chan *amqp.Error, 1)),
+		s.publisher.NotifyClose(make(chan *amqp.Error, 1)),
+		s.admin.NotifyClose(make(chan *amqp.Error, 1)),
+	}
+
+	rabbit.mu.Lock()
+	select {
+	case <-rabbit.done:
+		rabbit.mu.Unlock()
+		s.close()
+		return errClosed
+	default:
+	}
+	rabbit.session = s
+	rabbit.mu.Unlock()
+
+	go rabbit.watch(s, closed)
+	return nil
+}
+
+// open conecta con RabbitMQ, declara la topología y abre los canales de la sesión
+func (rabbit *Rabbit) open() (*session, error) {
+	connection, err := amqp.Dial(rabbit.uri)
+	if err != nil {
+		return nil, fmt.Errorf("error al conectar con RabbitMQ: %w", err)
+	}
+	s := &session{connection: connection}
+
+	if s.channel, err = connection.Channel(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("error al crear el canal de RabbitMQ: %w", err)
+	}
+	if err := rabbit.declare(s.channel); err != nil {
+		s.close()
+		return nil, err
+	}
+
+	if s.publisher, err = connection.Channel(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("error al crear el canal de publicación de RabbitMQ: %w", err)
+	}
+	if err := s.publisher.Confirm(false); err != nil {
+		s.close()
+		return nil, fmt.Errorf("error al activar las confirmaciones de RabbitMQ: %w", err)
+	}
+	s.confirms = s.publisher.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	if s.admin, err = connection.Channel(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("error al crear el canal de administración de RabbitMQ: %w", err)
+	}
+	return s, nil
+}
+
+// declare declara la cola principal, la de reintentos y la de mensajes muertos
+func (rabbit *Rabbit) declare(channel *amqp.Channel) error {
+	if _, err := channel.QueueDeclare(
+		rabbit.queueName,
+		true,  // Durable, igual que la declaración del publicador en courses-api
 		false, // No autoeliminada
 		false, // No exclusiva
 		false, // No espera
 		nil,
-	)
-	if err != nil {
-		log.Fatalf("Error al declarar la cola: %v", err)
+	); err != nil {
+		return fmt.Errorf("error al declarar la cola: %w", err)
+	}
+
+	// Cola de reintentos: al vencer el TTL el mensaje vuelve a la cola principal
+	if _, err := channel.QueueDeclare(rabbit.retryQueue, true, false, false, false, amqp.Table{
+		"x-message-ttl":             int32(rabbit.retryDelay / time.Millisecond),
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": rabbit.queueName,
+	}); err != nil {
+		return fmt.Errorf("error al declarar la cola de reintentos: %w", err)
+	}
+
+	// Exchange y cola de mensajes muertos
+	if err := channel.ExchangeDeclare(rabbit.deadLetterX, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("error al declarar el exchange de mensajes muertos: %w", err)
 	}
+	if _, err := channel.QueueDeclare(rabbit.deadLetterQ, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("error al declarar la cola de mensajes muertos: %w", err)
+	}
+	if err := channel.QueueBind(rabbit.deadLetterQ, rabbit.queueName, rabbit.deadLetterX, false, nil); err != nil {
+		return fmt.Errorf("error al enlazar la cola de mensajes muertos: %w", err)
+	}
+	return nil
+}
+
+// watch espera el cierre de la conexión o de alguno de sus canales, descarta
+// la sesión y dispara la reconexión
+func (rabbit *Rabbit) watch(s *session, closed []chan *amqp.Error) {
+	var cause *amqp.Error
+	select {
+	case cause = <-closed[0]:
+	case cause = <-closed[1]:
+	case cause = <-closed[2]:
+	case cause = <-closed[3]:
+	case <-rabbit.done:
+		return
+	}
+	log.Printf("Conexión con RabbitMQ perdida: %v", cause)
+
+	// Si solo se cerró un canal, cerrar el resto detiene también el consumo
+	s.close()
+	rabbit.reconnect()
+}
+
+// reconnect reintenta la conexión con backoff exponencial hasta lograrlo o hasta que se cierre el consumidor
+func (rabbit *Rabbit) reconnect() {
+	delay := rabbit.minBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-rabbit.done:
+			return
+		case <-time.After(delay):
+		}
+
+		err := rabbit.connect()
+		if err == nil {
+			log.Printf("Reconectado a RabbitMQ tras %d intentos", attempt)
+			return
+		}
+		if errors.Is(err, errClosed) {
+			return
+		}
+		log.Printf("Intento %d de reconexión a RabbitMQ fallido: %v", attempt, err)
+
+		delay *= 2
+		if delay > rabbit.maxBackoff {
+			delay = rabbit.maxBackoff
+		}
+	}
+}
+
+// current devuelve la sesión vigente
+func (rabbit *Rabbit) current() *session {
+	rabbit.mu.RLock()
+	defer rabbit.mu.RUnlock()
+	return rabbit.session
+}
+
+// delivery es un mensaje ya deserializado a la espera de un worker
+type delivery struct {
+	msg    amqp.Delivery
+	update courses.CourseUpdate
+}
+
+// StartConsumer inicia la escucha de mensajes en la cola de RabbitMQ con acuse manual.
+// Los eventos se reparten entre los workers según el ID del curso, de modo que los
+// de un mismo curso se procesan en el orden en que llegan y los de cursos
+// distintos en paralelo. Un evento reintentado vuelve a la cola detrás de los
+// posteriores de su curso: el manejador descarta los obsoletos por su versión.
+// Tras una reconexión el consumo se reinicia solo.
+func (rabbit *Rabbit) StartConsumer(handler func(courses.CourseUpdate) error) error {
+	rabbit.connectMu.Lock()
+	defer rabbit.connectMu.Unlock()
 
-	return Rabbit{
-		connection: connection,
-		channel:    channel,
-		queue:      queue,
+	if err := rabbit.consume(rabbit.current(), handler); err != nil {
+		return err
 	}
+	rabbit.mu.Lock()
+	rabbit.handler = handler
+	rabbit.mu.Unlock()
+
+	metrics.Set("worker_backlog", expvar.Func(func() any {
+		rabbit.mu.RLock()
+		defer rabbit.mu.RUnlock()
+		backlog := make([]int, len(rabbit.backlog))
+		for i, partition := range rabbit.backlog {
+			backlog[i] = len(partition)
+		}
+		return backlog
+	}))
+
+	go rabbit.monitor()
+
+	return nil
 }
 
-// StartConsumer inicia la escucha de mensajes en la cola de RabbitMQ
-func (rabbit Rabbit) StartConsumer(handler func(courses.CourseUpdate)) error {
-	messages, err := rabbit.channel.Consume(
-		rabbit.queue.Name,
+// consume registra el consumidor en el canal de la sesión y arranca sus
+// workers, una vez que terminaron los de la sesión anterior. Los mensajes que
+// esos workers no llegaron a confirmar el broker los vuelve a entregar.
+func (rabbit *Rabbit) consume(s *session, handler func(courses.CourseUpdate) error) error {
+	rabbit.working.Wait()
+
+	if err := s.channel.Qos(rabbit.prefetch, 0, false); err != nil {
+		return fmt.Errorf("error al configurar el prefetch del consumidor: %v", err)
+	}
+
+	messages, err := s.channel.Consume(
+		rabbit.queueName,
 		"",
-		true,  // Auto-acuse de recibo (auto-acknowledge)
+		false, // Acuse manual: solo se confirma una vez procesado el mensaje
 		false, // No exclusivo
 		false, // No espera
 		false, // No local
@@ -72,30 +403,292 @@ func (rabbit Rabbit) StartConsumer(handler func(courses.CourseUpdate)) error {
 		return fmt.Errorf("error al registrar el consumidor: %v", err)
 	}
 
-	go func() {
-		for msg := range messages {
-			var courseUpdate courses.CourseUpdate
-			if err := json.Unmarshal(msg.Body, &courseUpdate); err != nil {
-				log.Printf("Error al deserializar el mensaje: %v", err)
+	// Cada partición admite todo el prefetch para que el reparto nunca se bloquee
+	partitions := make([]chan delivery, rabbit.workers)
+	rabbit.working.Add(len(partitions))
+	for i := range partitions {
+		partitions[i] = make(chan delivery, rabbit.prefetch)
+		go func(partition <-chan delivery) {
+			defer rabbit.working.Done()
+			rabbit.work(partition, handler)
+		}(partitions[i])
+	}
+	rabbit.mu.Lock()
+	rabbit.backlog = partitions
+	rabbit.mu.Unlock()
+
+	go rabbit.dispatch(messages, partitions)
+	return nil
+}
+
+// dispatch reparte los mensajes entre las particiones hasta que se cierre el canal
+func (rabbit *Rabbit) dispatch(messages <-chan amqp.Delivery, partitions []chan delivery) {
+	for msg := range messages {
+		var courseUpdate courses.CourseUpdate
+		if err := json.Unmarshal(msg.Body, &courseUpdate); err != nil {
+			log.Printf("Error al deserializar el mensaje: %v", err)
+			metrics.Add("failed", 1)
+			rabbit.deadLetter(msg, fmt.Errorf("%w: %v", courses.ErrInvalidEvent, err))
+			continue
+		}
+
+		log.Printf("Mensaje recibido en el consumidor: %+v", courseUpdate)
+		partitions[partition(courseUpdate.ID, len(partitions))] <- delivery{msg: msg, update: courseUpdate}
+	}
+	for _, partition := range partitions {
+		close(partition)
+	}
+}
+
+// partition asigna siempre el mismo worker a un mismo curso
+func partition(courseID int64, workers int) int {
+	return int(uint64(courseID) % uint64(workers))
+}
+
+// work procesa en orden los eventos de su partición
+func (rabbit *Rabbit) work(deliveries <-chan delivery, handler func(courses.CourseUpdate) error) {
+	for d := range deliveries {
+		metrics.Add("in_flight", 1)
+		rabbit.process(d.msg, d.update, handler)
+		metrics.Add("in_flight", -1)
+	}
+}
+
+// process entrega el evento al manejador y decide si confirmarlo, reintentarlo o descartarlo
+func (rabbit *Rabbit) process(msg amqp.Delivery, courseUpdate courses.CourseUpdate, handler func(courses.CourseUpdate) error) {
+	// Pasar el mensaje al manejador (handler)
+	err := handler(courseUpdate)
+	if err == nil {
+		metrics.Add("processed", 1)
+		if err := msg.Ack(false); err != nil {
+			log.Printf("Error al confirmar el mensaje: %v", err)
+		}
+		return
+	}
+
+	metrics.Add("failed", 1)
+	retries := retryCount(msg)
+	if errors.Is(err, courses.ErrInvalidEvent) || retries >= rabbit.maxRetries {
+		rabbit.deadLetter(msg, err)
+		return
+	}
+	rabbit.retry(msg, retries+1, err)
+}
+
+// monitor publica periódicamente la profundidad de la cola principal, la de reintentos y la de mensajes muertos
+func (rabbit *Rabbit) monitor() {
+	ticker := time.NewTicker(rabbit.metricsInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, name := range []string{rabbit.queueName, rabbit.retryQueue, rabbit.deadLetterQ} {
+			depth, err := rabbit.QueueDepth(name)
+			if err != nil {
+				log.Printf("Error al consultar la profundidad de la cola %s: %v", name, err)
 				continue
 			}
+			value := new(expvar.Int)
+			value.Set(int64(depth))
+			queueDepth.Set(name, value)
+		}
+
+		select {
+		case <-rabbit.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// QueueDepth devuelve la cantidad de mensajes listos en la cola indicada
+func (rabbit *Rabbit) QueueDepth(name string) (int, error) {
+	rabbit.adminMu.Lock()
+	defer rabbit.adminMu.Unlock()
 
-			log.Printf("Mensaje recibido en el consumidor: %+v", courseUpdate)
+	queue, err := rabbit.current().admin.QueueInspect(name)
+	if err != nil {
+		return 0, err
+	}
+	return queue.Messages, nil
+}
 
-			// Pasar el mensaje al manejador (handler)
-			handler(courseUpdate)
+// retry republica el mensaje en la cola de reintentos y confirma el original
+func (rabbit *Rabbit) retry(msg amqp.Delivery, attempt int, cause error) {
+	log.Printf("Reintento %d/%d del mensaje: %v", attempt, rabbit.maxRetries, cause)
+	headers := amqp.Table{
+		headerRetryCount: int32(attempt),
+		headerLastError:  cause.Error(),
+	}
+	metrics.Add("retried", 1)
+	rabbit.forward(msg, "", rabbit.retryQueue, headers)
+}
+
+// deadLetter envía el mensaje al exchange de mensajes muertos y confirma el original
+func (rabbit *Rabbit) deadLetter(msg amqp.Delivery, cause error) {
+	log.Printf("Mensaje enviado a la cola de mensajes muertos: %v", cause)
+	headers := amqp.Table{
+		headerRetryCount: int32(retryCount(msg)),
+		headerLastError:  cause.Error(),
+		headerFailedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	metrics.Add("dead_lettered", 1)
+	rabbit.forward(msg, rabbit.deadLetterX, rabbit.queueName, headers)
+}
+
+// forward publica una copia del mensaje y solo entonces confirma el original.
+// Si la publicación falla, el original vuelve a la cola para no perderlo.
+func (rabbit *Rabbit) forward(msg amqp.Delivery, exchange, key string, headers amqp.Table) {
+	if err := rabbit.publish(exchange, key, msg.Body, headers); err != nil {
+		log.Printf("Error al republicar el mensaje, se devuelve a la cola: %v", err)
+		if err := msg.Nack(false, true); err != nil {
+			log.Printf("Error al devolver el mensaje a la cola: %v", err)
 		}
-	}()
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		log.Printf("Error al confirmar el mensaje: %v", err)
+	}
+}
+
+// publish envía un mensaje persistente y espera la confirmación del broker
+func (rabbit *Rabbit) publish(exchange, key string, body []byte, headers amqp.Table) error {
+	rabbit.publisherMu.Lock()
+	defer rabbit.publisherMu.Unlock()
 
+	s := rabbit.current()
+	if err := s.publisher.Publish(exchange, key, false, false, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Headers:      headers,
+		Body:         body,
+	}); err != nil {
+		return fmt.Errorf("error al publicar en RabbitMQ: %w", err)
+	}
+
+	confirmation, ok := <-s.confirms
+	if !ok {
+		return amqp.ErrClosed
+	}
+	if !confirmation.Ack {
+		return errors.New("RabbitMQ rechazó el mensaje (nack)")
+	}
 	return nil
 }
 
-// Close cierra la conexión y el canal de RabbitMQ
-func (rabbit Rabbit) Close() {
-	if err := rabbit.channel.Close(); err != nil {
-		log.Printf("Error al cerrar el canal de RabbitMQ: %v", err)
+// DeadLetters devuelve hasta limit mensajes de la cola de mensajes muertos sin retirarlos
+func (rabbit *Rabbit) DeadLetters(limit int) ([]courses.DeadLetter, error) {
+	rabbit.adminMu.Lock()
+	defer rabbit.adminMu.Unlock()
+
+	var (
+		admin   = rabbit.current().admin
+		results []courses.DeadLetter
+		last    *amqp.Delivery
+	)
+	for len(results) < limit {
+		msg, ok, err := admin.Get(rabbit.deadLetterQ, false)
+		if err != nil {
+			return nil, fmt.Errorf("error al leer la cola de mensajes muertos: %w", err)
+		}
+		if !ok {
+			break
+		}
+		last = &msg
+		results = append(results, toDeadLetter(msg))
+	}
+
+	// Devolver todo lo leído a la cola: listar no consume los mensajes
+	if last != nil {
+		if err := last.Nack(true, true); err != nil {
+			return nil, fmt.Errorf("error al devolver los mensajes a la cola de mensajes muertos: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// ReplayDeadLetters reenvía hasta limit mensajes muertos a la cola principal con el contador de reintentos en cero
+func (rabbit *Rabbit) ReplayDeadLetters(limit int) (int, error) {
+	rabbit.adminMu.Lock()
+	defer rabbit.adminMu.Unlock()
+
+	admin := rabbit.current().admin
+	replayed := 0
+	for replayed < limit {
+		msg, ok, err := admin.Get(rabbit.deadLetterQ, false)
+		if err != nil {
+			return replayed, fmt.Errorf("error al leer la cola de mensajes muertos: %w", err)
+		}
+		if !ok {
+			break
+		}
+		if err := rabbit.publish("", rabbit.queueName, msg.Body, nil); err != nil {
+			if nackErr := msg.Nack(false, true); nackErr != nil {
+				log.Printf("Error al devolver el mensaje a la cola de mensajes muertos: %v", nackErr)
+			}
+			return replayed, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, fmt.Errorf("error al confirmar el mensaje reenviado: %w", err)
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// PurgeDeadLetters elimina todos los mensajes de la cola de mensajes muertos
+func (rabbit *Rabbit) PurgeDeadLetters() (int, error) {
+	rabbit.adminMu.Lock()
+	defer rabbit.adminMu.Unlock()
+
+	purged, err := rabbit.current().admin.QueuePurge(rabbit.deadLetterQ, false)
+	if err != nil {
+		return 0, fmt.Errorf("error al vaciar la cola de mensajes muertos: %w", err)
+	}
+	return purged, nil
+}
+
+// retryCount obtiene cuántas veces se reintentó el mensaje
+func retryCount(msg amqp.Delivery) int {
+	switch value := msg.Headers[headerRetryCount].(type) {
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	}
+	return 0
+}
+
+func toDeadLetter(msg amqp.Delivery) courses.DeadLetter {
+	deadLetter := courses.DeadLetter{
+		Body:    string(msg.Body),
+		Retries: retryCount(msg),
+	}
+	if value, ok := msg.Headers[headerLastError].(string); ok {
+		deadLetter.Error = value
+	}
+	if value, ok := msg.Headers[headerFailedAt].(string); ok {
+		if failedAt, err := time.Parse(time.RFC3339, value); err == nil {
+			deadLetter.FailedAt = failedAt
+		}
+	}
+	return deadLetter
+}
+
+// Close cierra la conexión y los canales de RabbitMQ y detiene la reconexión
+func (rabbit *Rabbit) Close() {
+	rabbit.mu.Lock()
+	close(rabbit.done)
+	s := rabbit.session
+	rabbit.mu.Unlock()
+
+	for _, channel := range []*amqp.Channel{s.admin, s.publisher, s.channel} {
+		if err := channel.Close(); err != nil {
+			log.Printf("Error al cerrar el canal de RabbitMQ: %v", err)
+		}
 	}
-	if err := rabbit.connection.Close(); err != nil {
+	if err := s.connection.Close(); err != nil {
 		log.Printf("Error al cerrar la conexión de RabbitMQ: %v", err)
 	}
 }
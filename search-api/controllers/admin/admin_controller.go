@@ -0,0 +1,177 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"search-api/domain/courses"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeadLetterQueue define las operaciones administrativas sobre los eventos descartados
+type DeadLetterQueue interface {
+	DeadLetters(limit int) ([]courses.DeadLetter, error)
+	ReplayDeadLetters(limit int) (int, error)
+	PurgeDeadLetters() (int, error)
+}
+
+// SynonymStore define la administración de los sinónimos del motor de búsqueda.
+// Los cambios se aplican recargando el índice, sin interrumpir las búsquedas.
+type SynonymStore interface {
+	Synonyms(ctx context.Context) (map[string][]string, error)
+	AddSynonyms(ctx context.Context, terms []string) ([]string, error)
+	DeleteSynonym(ctx context.Context, term string) error
+}
+
+// Reindexer define la reconstrucción completa del índice de búsqueda
+type Reindexer interface {
+	Start() (courses.ReindexStatus, error)
+	Status() courses.ReindexStatus
+}
+
+// Reconciler define la comparación periódica entre courses-api y el índice
+type Reconciler interface {
+	Report() courses.DriftReport
+	Trigger()
+}
+
+// Controller representa el controlador de administración
+type Controller struct {
+	deadLetters DeadLetterQueue
+	synonyms    SynonymStore
+	reindexer   Reindexer
+	reconciler  Reconciler
+}
+
+// NewController crea una nueva instancia del controlador de administración
+func NewController(deadLetters DeadLetterQueue, synonyms SynonymStore, reindexer Reindexer, reconciler Reconciler) Controller {
+	return Controller{
+		deadLetters: deadLetters,
+		synonyms:    synonyms,
+		reindexer:   reindexer,
+		reconciler:  reconciler,
+	}
+}
+
+// parseLimit lee el parámetro "limit" de la URL con un valor por defecto
+func parseLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = 50 // Valor por defecto si no se proporciona o es inválido
+	}
+	return limit
+}
+
+// ListDeadLetters maneja las solicitudes GET en el endpoint /admin/dead-letters
+func (controller Controller) ListDeadLetters(c *gin.Context) {
+	deadLetters, err := controller.deadLetters.DeadLetters(parseLimit(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al listar los mensajes muertos: %v", err)})
+		return
+	}
+	if deadLetters == nil {
+		deadLetters = []courses.DeadLetter{}
+	}
+	c.JSON(http.StatusOK, deadLetters)
+}
+
+// ReplayDeadLetters maneja las solicitudes POST en el endpoint /admin/dead-letters/replay
+func (controller Controller) ReplayDeadLetters(c *gin.Context) {
+	replayed, err := controller.deadLetters.ReplayDeadLetters(parseLimit(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":    fmt.Sprintf("Error al reenviar los mensajes muertos: %v", err),
+			"replayed": replayed,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"replayed": replayed})
+}
+
+// PurgeDeadLetters maneja las solicitudes DELETE en el endpoint /admin/dead-letters
+func (controller Controller) PurgeDeadLetters(c *gin.Context) {
+	purged, err := controller.deadLetters.PurgeDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al vaciar los mensajes muertos: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// ListSynonyms maneja las solicitudes GET en el endpoint /admin/synonyms
+func (controller Controller) ListSynonyms(c *gin.Context) {
+	synonyms, err := controller.synonyms.Synonyms(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al listar los sinónimos: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"synonyms": synonyms})
+}
+
+// AddSynonyms maneja las solicitudes POST en el endpoint /admin/synonyms
+func (controller Controller) AddSynonyms(c *gin.Context) {
+	var set courses.SynonymSet
+	if err := c.ShouldBindJSON(&set); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Se espera {\"terms\": [...]} con al menos dos términos"})
+		return
+	}
+
+	terms, err := controller.synonyms.AddSynonyms(c.Request.Context(), set.Terms)
+	if errors.Is(err, courses.ErrInvalidSynonyms) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al guardar los sinónimos: %v", err)})
+		return
+	}
+	c.JSON(http.StatusCreated, courses.SynonymSet{Terms: terms})
+}
+
+// DeleteSynonym maneja las solicitudes DELETE en el endpoint /admin/synonyms/:term
+func (controller Controller) DeleteSynonym(c *gin.Context) {
+	term := c.Param("term")
+	err := controller.synonyms.DeleteSynonym(c.Request.Context(), term)
+	if errors.Is(err, courses.ErrSynonymNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al eliminar el sinónimo: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": term})
+}
+
+// StartReindex maneja las solicitudes POST en el endpoint /admin/reindex
+func (controller Controller) StartReindex(c *gin.Context) {
+	status, err := controller.reindexer.Start()
+	if errors.Is(err, courses.ErrReindexRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": status})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al iniciar la reindexación: %v", err)})
+		return
+	}
+	c.JSON(http.StatusAccepted, status)
+}
+
+// ReindexStatus maneja las solicitudes GET en el endpoint /admin/reindex
+func (controller Controller) ReindexStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, controller.reindexer.Status())
+}
+
+// DriftReport maneja las solicitudes GET en el endpoint /admin/reconcile
+func (controller Controller) DriftReport(c *gin.Context) {
+	c.JSON(http.StatusOK, controller.reconciler.Report())
+}
+
+// TriggerReconcile maneja las solicitudes POST en el endpoint /admin/reconcile
+func (controller Controller) TriggerReconcile(c *gin.Context) {
+	controller.reconciler.Trigger()
+	c.JSON(http.StatusAccepted, gin.H{"message": "Reconciliación programada"})
+}
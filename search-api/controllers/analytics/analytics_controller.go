@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"search-api/controllers/search"
+	"search-api/domain/analytics"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDays  = 7   // Período por defecto del informe
+	maxDays      = 90  // Período máximo del informe
+	defaultLimit = 20  // Consultas por lista del informe
+	maxLimit     = 100 // Máximo de consultas por lista
+)
+
+// Service define el registro de clics y los informes de búsqueda
+type Service interface {
+	RecordClick(ctx context.Context, click analytics.Click, user string) error
+	Report(ctx context.Context, days int, limit int) (analytics.Report, error)
+}
+
+// Controller representa el controlador de analítica de búsquedas
+type Controller struct {
+	service Service
+}
+
+// NewController crea una nueva instancia del controlador de analítica
+func NewController(service Service) Controller {
+	return Controller{
+		service: service,
+	}
+}
+
+// Click maneja las solicitudes POST en /search/click: el frontend informa qué
+// resultado eligió el usuario, con el query_id de la respuesta de /search
+func (controller Controller) Click(c *gin.Context) {
+	var click analytics.Click
+	if err := c.ShouldBindJSON(&click); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Clic inválido: %v", err)})
+		return
+	}
+
+	if err := controller.service.RecordClick(c.Request.Context(), click, search.RequestUser(c)); err != nil {
+		if errors.Is(err, analytics.ErrInvalidClick) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Report maneja las solicitudes GET en /search/analytics: consultas más
+// buscadas, consultas sin resultados y tasa de clics de los últimos días
+func (controller Controller) Report(c *gin.Context) {
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days <= 0 {
+		days = defaultDays
+	}
+	if days > maxDays {
+		days = maxDays
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	report, err := controller.service.Report(c.Request.Context(), days, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
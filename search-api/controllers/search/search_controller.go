@@ -2,29 +2,53 @@ package search
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"search-api/domain/analytics"
 	"search-api/domain/courses"
+	"search-api/middleware"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	maxLimit        = 100 // Tamaño máximo de página que se puede pedir
+	similarLimit    = 5   // Cursos parecidos por defecto
+	suggestLimit    = 5   // Sugerencias por defecto
+	maxSuggestLimit = 10  // Máximo de sugerencias por pedido
+)
+
 // Service define la interfaz del servicio de búsqueda
 type Service interface {
-	Search(ctx context.Context, query string, offset int, limit int) ([]courses.CourseUpdate, error)
+	Search(ctx context.Context, query string, filters courses.SearchFilters, sort string, offset int, limit int) (courses.SearchResponse, error)
+	Suggest(ctx context.Context, prefix string, limit int) ([]courses.Suggestion, error)
+	Similar(ctx context.Context, id int64, offset int, limit int) (courses.SimilarResponse, error)
+}
+
+// Recorder registra las búsquedas para los informes de analítica
+type Recorder interface {
+	RecordSearch(event analytics.SearchEvent) string
+	VerifyQueryID(queryID string, query string) bool
 }
 
 // Controller representa el controlador de búsqueda
 type Controller struct {
-	service Service
+	service  Service
+	recorder Recorder // nil si la analítica está deshabilitada
 }
 
 // NewController crea una nueva instancia del controlador de búsqueda
-func NewController(service Service) Controller {
+func NewController(service Service, recorder Recorder) Controller {
 	return Controller{
-		service: service,
+		service:  service,
+		recorder: recorder,
 	}
 }
 
@@ -39,8 +63,8 @@ func (controller Controller) Search(c *gin.Context) {
 
 	// Parsear el parámetro "offset" de la URL
 	offset, err := strconv.Atoi(c.Query("offset"))
-	if err != nil {
-		offset = 0 // Valor por defecto si no se proporciona
+	if err != nil || offset < 0 {
+		offset = 0 // Valor por defecto si no se proporciona o es inválido
 	}
 
 	// Parsear el parámetro "limit" de la URL
@@ -48,17 +72,186 @@ func (controller Controller) Search(c *gin.Context) {
 	if err != nil || limit <= 0 {
 		limit = 10 // Valor por defecto si no se proporciona o es inválido
 	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	// Parsear el criterio de orden
+	sort := c.DefaultQuery("sort", courses.SortRelevance)
+	switch sort {
+	case courses.SortRelevance, courses.SortRating, courses.SortName:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El parámetro 'sort' debe ser relevance, rating o name"})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Llamar al servicio de búsqueda
-	results, err := controller.service.Search(c.Request.Context(), query, offset, limit)
+	started := time.Now()
+	results, err := controller.service.Search(c.Request.Context(), query, filters, sort, offset, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error en la búsqueda: %v", err)})
 		return
 	}
 
 	// Log para ver los resultados de la búsqueda
-	log.Printf("Resultados de la búsqueda para la consulta '%s': %+v", query, results)
+	log.Printf("Resultados de la búsqueda para la consulta '%s': %d de %d", query, len(results.Results), results.NumFound)
+
+	// Registrar la búsqueda. Las páginas siguientes traen el ID de la primera
+	// (qid), así una búsqueda paginada cuenta una sola vez. Un qid que no fue
+	// emitido para esta consulta no evita el registro.
+	if controller.recorder != nil {
+		results.QueryID = c.Query("qid")
+		if offset == 0 || !controller.recorder.VerifyQueryID(results.QueryID, query) {
+			results.QueryID = controller.recorder.RecordSearch(analytics.SearchEvent{
+				Query:    query,
+				User:     RequestUser(c),
+				Sort:     sort,
+				Filtered: filters != (courses.SearchFilters{}),
+				Results:  results.NumFound,
+				Latency:  time.Since(started),
+			})
+		}
+	}
+
+	// Enlaces a las páginas vecinas, conservando la consulta, los filtros y el orden
+	if offset+limit < results.NumFound {
+		results.Next = pageLink(c.Request.URL, offset+limit, limit, results.QueryID)
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		results.Prev = pageLink(c.Request.URL, prev, limit, results.QueryID)
+	}
 
 	// Enviar los resultados como respuesta JSON
 	c.JSON(http.StatusOK, results)
 }
+
+// Suggest maneja las solicitudes GET en el endpoint /search/suggest
+func (controller Controller) Suggest(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("q"))
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El parámetro 'q' es obligatorio"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = suggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	suggestions, err := controller.service.Suggest(c.Request.Context(), prefix, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al obtener sugerencias: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+// Similar maneja las solicitudes GET en el endpoint /search/courses/:id/similar
+func (controller Controller) Similar(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El ID del curso debe ser un entero positivo"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = similarLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	results, err := controller.service.Similar(c.Request.Context(), id, offset, limit)
+	if err != nil {
+		if errors.Is(err, courses.ErrCourseNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("El curso %d no está en el índice de búsqueda", id)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Error al buscar cursos parecidos: %v", err)})
+		return
+	}
+
+	if offset+limit < results.NumFound {
+		results.Next = pageLink(c.Request.URL, offset+limit, limit, "")
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		results.Prev = pageLink(c.Request.URL, prev, limit, "")
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// pageLink devuelve la URL relativa de la misma búsqueda con otro offset
+func pageLink(requestURL *url.URL, offset int, limit int, queryID string) string {
+	params := requestURL.Query()
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("limit", strconv.Itoa(limit))
+	if queryID != "" {
+		params.Set("qid", queryID)
+	}
+	return requestURL.Path + "?" + params.Encode()
+}
+
+// RequestUser identifica al autor de una petición para la analítica: el ID del
+// token si lo hay y, si no, la dirección del cliente. Se anonimiza al guardarlo.
+func RequestUser(c *gin.Context) string {
+	if userID := c.GetString(middleware.UserKey); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// parseFilters lee los filtros opcionales category, min_rating, available e instructor_id
+func parseFilters(c *gin.Context) (courses.SearchFilters, error) {
+	filters := courses.SearchFilters{Category: c.Query("category")}
+
+	if value := c.Query("min_rating"); value != "" {
+		minRating, err := strconv.ParseFloat(value, 64)
+		// ParseFloat acepta "NaN" e "Inf", que no son calificaciones
+		if err != nil || math.IsNaN(minRating) || math.IsInf(minRating, 0) || minRating < 0 {
+			return filters, fmt.Errorf("el parámetro 'min_rating' debe ser un número no negativo")
+		}
+		filters.MinRating = &minRating
+	}
+
+	if value := c.Query("available"); value != "" {
+		available, err := strconv.ParseBool(value)
+		if err != nil {
+			return filters, fmt.Errorf("el parámetro 'available' debe ser true o false")
+		}
+		filters.Available = &available
+	}
+
+	if value := c.Query("instructor_id"); value != "" {
+		instructorID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || instructorID <= 0 {
+			return filters, fmt.Errorf("el parámetro 'instructor_id' debe ser un entero positivo")
+		}
+		filters.InstructorID = instructorID
+	}
+
+	return filters, nil
+}
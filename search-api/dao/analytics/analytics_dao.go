@@ -0,0 +1,46 @@
+package analytics
+
+import "time"
+
+// SearchEvent es una búsqueda registrada. Las páginas siguientes de la misma
+// búsqueda no generan eventos nuevos: comparten el QueryID de la primera.
+type SearchEvent struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	QueryID   string    `gorm:"size:32;not null;uniqueIndex"`
+	Query     string    `gorm:"size:255;not null;index"` // Consulta normalizada (minúsculas, espacios simples)
+	UserHash  string    `gorm:"size:16;not null;index"`  // Usuario anonimizado
+	Sort      string    `gorm:"size:16;not null"`
+	Filtered  bool      `gorm:"not null"` // Si la búsqueda tenía filtros
+	Results   int       `gorm:"not null"` // Cantidad total de resultados
+	LatencyMs int       `gorm:"not null"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+// SearchClick es un resultado elegido por el usuario en una búsqueda
+type SearchClick struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	QueryID   string    `gorm:"size:32;not null;index"`
+	CourseID  int64     `gorm:"not null"`
+	Position  int       `gorm:"not null"` // Posición del resultado, empezando en 1
+	UserHash  string    `gorm:"size:16;not null"`
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+// QueryStat agrupa las búsquedas de una misma consulta
+type QueryStat struct {
+	Query           string
+	Searches        int
+	Users           int
+	AvgResults      float64
+	ClickedSearches int
+	LastSearchedAt  time.Time
+}
+
+// Totals resume todas las búsquedas de un período
+type Totals struct {
+	Searches        int
+	Users           int
+	ZeroResults     int
+	ClickedSearches int
+	AvgLatencyMs    float64
+}
@@ -2,8 +2,65 @@ package courses
 
 // Course representa la estructura de un curso en la base de datos
 type Course struct {
-	ID          int64  `json:"id"`          // Cambiar a int64
-	Name        string `json:"name"`        // Nombre del curso
-	Category    string `json:"category"`    // Categoría del curso
-	Description string `json:"description"` // Descripción del curso
+	ID           int64   `json:"id"`              // Cambiar a int64
+	Name         string  `json:"name"`            // Nombre del curso
+	Category     string  `json:"category"`        // Categoría del curso
+	Description  string  `json:"description"`     // Descripción del curso
+	Duration     string  `json:"duration"`        // Duración del curso
+	InstructorID int64   `json:"instructor_id"`   // Instructor a cargo del curso
+	Capacity     int     `json:"capacity"`        // Cupo máximo de alumnos
+	Available    bool    `json:"available"`       // Si el curso todavía acepta inscripciones
+	Rating       float64 `json:"rating"`          // Calificación promedio del curso
+	Version      int64   `json:"version"`         // Versión del curso en courses-api al momento de indexarlo
+	Deleted      bool    `json:"-"`               // Baja que solo conserva la versión en el índice
+	Score        float64 `json:"score,omitempty"` // Relevancia devuelta por SolR (solo en búsquedas)
+
+	// Fragmentos resaltados (solo en búsquedas; vacíos si el campo no coincidió)
+	HighlightedName        string `json:"-"`
+	HighlightedDescription string `json:"-"`
+}
+
+// SearchFilters restringe una búsqueda sin afectar la relevancia de los resultados
+type SearchFilters struct {
+	Category     string   // Categoría exacta
+	MinRating    *float64 // Calificación mínima (nil = sin filtro)
+	Available    *bool    // Disponibilidad (nil = sin filtro)
+	InstructorID int64    // Instructor (0 = sin filtro)
+}
+
+// FacetCount es la cantidad de resultados para un valor de una faceta
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// Facets agrupa los conteos de las facetas de una búsqueda
+type Facets struct {
+	Categories   []FacetCount // Conteo por categoría
+	Ratings      []FacetCount // Conteo por rango de calificación ("0-1", ..., "4-5")
+	Availability []FacetCount // Conteo por disponibilidad ("true" / "false")
+}
+
+// SearchSort es el orden de los resultados en el índice
+type SearchSort int
+
+const (
+	SortByScore  SearchSort = iota // Relevancia, desempatando por ID
+	SortByRating                   // Calificación descendente, luego relevancia
+	SortByName                     // Nombre ascendente (sin distinguir mayúsculas ni acentos)
+)
+
+// Collation es una consulta corregida por el corrector ortográfico
+type Collation struct {
+	Query string // Consulta corregida, tal como la escribiría el usuario
+	Hits  int    // Resultados que devolvería
+}
+
+// SearchResult es una página de resultados junto con sus facetas
+type SearchResult struct {
+	Courses    []Course
+	NumFound   int // Total de documentos que coinciden, sin paginar
+	QTime      int // Milisegundos que tardó la consulta en el motor
+	Facets     Facets
+	Collations []Collation // Correcciones propuestas cuando hubo pocos o ningún resultado
 }
@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidClick indica un clic que no corresponde a una búsqueda válida
+var ErrInvalidClick = errors.New("clic inválido")
+
+// SearchEvent es una búsqueda realizada, tal como la informa el controlador
+type SearchEvent struct {
+	Query    string
+	User     string // Usuario sin anonimizar: ID del token o dirección del cliente
+	Sort     string
+	Filtered bool
+	Results  int
+	Latency  time.Duration
+}
+
+// Click es el resultado elegido por el usuario en una búsqueda
+type Click struct {
+	QueryID  string `json:"query_id" binding:"required"`
+	CourseID int64  `json:"course_id" binding:"required,min=1"`
+	Position int    `json:"position" binding:"min=0"` // Posición en la lista de resultados, empezando en 1
+}
+
+// QueryStat resume las búsquedas de una consulta
+type QueryStat struct {
+	Query           string    `json:"query"`
+	Searches        int       `json:"searches"`
+	Users           int       `json:"users"`
+	AvgResults      float64   `json:"avg_results"`
+	ClickedSearches int       `json:"clicked_searches"`
+	CTR             float64   `json:"ctr"` // Proporción de búsquedas con al menos un clic
+	LastSearchedAt  time.Time `json:"last_searched_at"`
+}
+
+// Report es el informe de búsquedas de un período
+type Report struct {
+	From              time.Time   `json:"from"`
+	To                time.Time   `json:"to"`
+	Searches          int         `json:"searches"`
+	Users             int         `json:"users"`
+	ZeroResults       int         `json:"zero_results"`
+	ZeroResultRate    float64     `json:"zero_result_rate"`
+	ClickedSearches   int         `json:"clicked_searches"`
+	CTR               float64     `json:"ctr"`
+	AvgLatencyMs      float64     `json:"avg_latency_ms"`
+	TopQueries        []QueryStat `json:"top_queries"`
+	ZeroResultQueries []QueryStat `json:"zero_result_queries"`
+}
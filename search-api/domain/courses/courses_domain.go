@@ -1,11 +1,184 @@
 package courses
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidEvent identifica eventos que nunca podrán procesarse (mensajes venenosos)
+var ErrInvalidEvent = errors.New("evento de curso inválido")
+
+// ErrCourseNotFound indica que el curso pedido no está en el índice
+var ErrCourseNotFound = errors.New("curso no encontrado en el índice")
+
+// ErrReindexRunning indica que ya hay una reindexación en curso
+var ErrReindexRunning = errors.New("ya hay una reindexación en curso")
+
+// Errores de la administración de sinónimos
+var (
+	ErrInvalidSynonyms = errors.New("sinónimos inválidos")
+	ErrSynonymNotFound = errors.New("sinónimo no encontrado")
+)
+
 // CourseUpdate representa una actualización de curso enviada a través de RabbitMQ
 type CourseUpdate struct {
-	Operation   string `json:"operation"`   // Tipo de operación: "CREATE", "UPDATE", "DELETE"
-	ID          int64  `json:"id"`          // Identificador único del curso
-	Name        string `json:"name"`        // Nombre del curso (para "CREATE" o "UPDATE")
-	Category    string `json:"category"`    // Categoría del curso (para "CREATE" o "UPDATE")
-	Description string `json:"description"` // Descripción del curso (para "CREATE" o "UPDATE")
+	EventID      string  `json:"event_id"`                // Identificador único del evento, para descartar duplicados
+	Operation    string  `json:"operation"`               // Tipo de operación: "CREATE", "UPDATE", "DELETE"
+	ID           int64   `json:"id"`                      // Identificador único del curso
+	Version      int64   `json:"version"`                 // Versión del curso en courses-api (µs de la última escritura)
+	Name         string  `json:"name"`                    // Nombre del curso (para "CREATE" o "UPDATE")
+	Category     string  `json:"category"`                // Categoría del curso (para "CREATE" o "UPDATE")
+	Description  string  `json:"description"`             // Descripción del curso (para "CREATE" o "UPDATE")
+	Duration     string  `json:"duration,omitempty"`      // Duración del curso
+	InstructorID int64   `json:"instructor_id,omitempty"` // Instructor a cargo del curso
+	Capacity     int     `json:"capacity,omitempty"`      // Cupo máximo de alumnos
+	Available    bool    `json:"available"`               // Si el curso todavía acepta inscripciones
+	Rating       float64 `json:"rating"`                  // Calificación promedio del curso
 	// Añadir más campos si es necesario
 }
+
+// SearchFilters son los filtros opcionales de una búsqueda (category, min_rating, available, instructor_id)
+type SearchFilters struct {
+	Category     string
+	MinRating    *float64
+	Available    *bool
+	InstructorID int64
+}
+
+// FacetCount es la cantidad de resultados para un valor de una faceta
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets agrupa los conteos de las facetas de una búsqueda
+type Facets struct {
+	Categories   []FacetCount `json:"categories"`   // Conteo por categoría
+	Ratings      []FacetCount `json:"ratings"`      // Conteo por rango de calificación ("0-1", ..., "4-5")
+	Availability []FacetCount `json:"availability"` // Conteo por disponibilidad
+}
+
+// Criterios de orden aceptados por la búsqueda
+const (
+	SortRelevance = "relevance" // Por relevancia (por defecto)
+	SortRating    = "rating"    // Mejor calificados primero
+	SortName      = "name"      // Alfabético por nombre
+)
+
+// SearchResult es un curso tal como se devuelve en los resultados de búsqueda
+type SearchResult struct {
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	Category     string  `json:"category"`
+	Description  string  `json:"description"`
+	Duration     string  `json:"duration"`
+	InstructorID int64   `json:"instructor_id"`
+	Capacity     int     `json:"capacity"`
+	Available    bool    `json:"available"`
+	Rating       float64 `json:"rating"`
+	Score        float64 `json:"score"` // Relevancia calculada por el motor de búsqueda
+
+	Highlight *Highlight `json:"highlight,omitempty"` // Coincidencias resaltadas, si las hubo
+}
+
+// Highlight contiene el nombre y la descripción con los términos buscados
+// rodeados por los marcadores configurados (el resto del texto va escapado como HTML)
+type Highlight struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Collation es una búsqueda alternativa sugerida por el corrector ortográfico
+type Collation struct {
+	Query string `json:"query"` // Consulta corregida
+	Hits  int    `json:"hits"`  // Resultados que devolvería
+}
+
+// SearchResponse es la página de resultados con los datos de paginación y las facetas
+type SearchResponse struct {
+	Results     []SearchResult `json:"results"`
+	NumFound    int            `json:"num_found"`     // Total de cursos que coinciden con la búsqueda
+	Offset      int            `json:"offset"`        // Posición del primer resultado de la página
+	Limit       int            `json:"limit"`         // Tamaño de la página
+	Sort        string         `json:"sort"`          // Criterio de orden aplicado
+	Next        string         `json:"next"`          // Enlace a la página siguiente (vacío si es la última)
+	Prev        string         `json:"prev"`          // Enlace a la página anterior (vacío si es la primera)
+	QueryTimeMs int            `json:"query_time_ms"` // Tiempo de la consulta en el motor de búsqueda
+	Facets      Facets         `json:"facets"`
+	DidYouMean  []Collation    `json:"did_you_mean,omitempty"` // Solo cuando hubo pocos o ningún resultado
+	QueryID     string         `json:"query_id,omitempty"`     // Identifica la búsqueda al informar clics en /search/click
+}
+
+// SimilarResponse es una página de cursos parecidos a uno dado
+type SimilarResponse struct {
+	CourseID int64          `json:"course_id"` // Curso de origen, que no se incluye en los resultados
+	Results  []SearchResult `json:"results"`
+	NumFound int            `json:"num_found"`
+	Offset   int            `json:"offset"`
+	Limit    int            `json:"limit"`
+	Next     string         `json:"next"`
+	Prev     string         `json:"prev"`
+}
+
+// Suggestion es un curso propuesto mientras el usuario escribe la búsqueda
+type Suggestion struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// SynonymSet es un grupo de términos equivalentes para la búsqueda
+type SynonymSet struct {
+	Terms []string `json:"terms" binding:"required,min=2"`
+}
+
+// Estados de una reindexación
+const (
+	ReindexIdle      = "idle"
+	ReindexRunning   = "running"
+	ReindexSucceeded = "succeeded"
+	ReindexFailed    = "failed"
+)
+
+// ReindexStatus describe la última reindexación completa del índice de búsqueda
+type ReindexStatus struct {
+	State      string     `json:"state"`                 // idle, running, succeeded o failed
+	Phase      string     `json:"phase,omitempty"`       // Paso en curso o en el que falló
+	Core       string     `json:"core,omitempty"`        // Core construido por la reindexación
+	StartedAt  *time.Time `json:"started_at,omitempty"`  // Inicio de la reindexación
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // Fin de la reindexación
+	Expected   int        `json:"expected"`              // Cursos obtenidos de courses-api
+	Indexed    int        `json:"indexed"`               // Cursos escritos en el nuevo core
+	Skipped    int        `json:"skipped"`               // Cursos que ya tenían una versión más nueva (por eventos recibidos durante la carga)
+	Count      int        `json:"count"`                 // Documentos del nuevo core al validar
+	Tombstones int        `json:"tombstones"`            // Bajas copiadas del core en vivo
+	Error      string     `json:"error,omitempty"`
+}
+
+// DriftSet es un tipo de diferencia encontrada por el reconciliador
+type DriftSet struct {
+	Count int     `json:"count"` // Cantidad de cursos con esta diferencia
+	IDs   []int64 `json:"ids"`   // Muestra de los IDs afectados (hasta 100)
+}
+
+// DriftReport resume una pasada del reconciliador entre courses-api y el índice
+type DriftReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	DryRun     bool      `json:"dry_run"` // Si solo se informaron las diferencias sin corregirlas
+	Checked    int       `json:"checked"` // Cursos revisados en courses-api
+	Missing    DriftSet  `json:"missing"` // Cursos disponibles que faltaban en el índice
+	Extra      DriftSet  `json:"extra"`   // Documentos de cursos eliminados o no disponibles
+	Stale      DriftSet  `json:"stale"`   // Documentos con una versión o contenido desactualizado
+	Fixed      int       `json:"fixed"`   // Diferencias corregidas
+	Failed     int       `json:"failed"`  // Correcciones que fallaron (se reintentan en la próxima pasada)
+	Error      string    `json:"error,omitempty"`
+}
+
+// DeadLetter representa un evento descartado tras agotar los reintentos
+type DeadLetter struct {
+	Body     string    `json:"body"`      // Mensaje original tal como llegó a la cola
+	Error    string    `json:"error"`     // Último error al procesarlo
+	Retries  int       `json:"retries"`   // Reintentos realizados antes de descartarlo
+	FailedAt time.Time `json:"failed_at"` // Momento en que se envió a la cola de mensajes muertos
+}
@@ -2,7 +2,17 @@ module search-api
 
 go 1.22
 
-require gorm.io/driver/mysql v1.5.7
+require (
+	github.com/gin-contrib/cors v1.7.2
+	github.com/gin-gonic/gin v1.10.0
+	github.com/golang-jwt/jwt/v5 v5.2.2
+	github.com/stevenferrer/solr-go v0.3.4
+	github.com/streadway/amqp v1.1.0
+	golang.org/x/text v0.15.0
+	gorm.io/driver/mysql v1.5.7
+	gorm.io/gorm v1.25.12
+	httpclient v0.0.0-00010101000000-000000000000
+)
 
 require (
 	github.com/bytedance/sonic v1.11.6 // indirect
@@ -14,9 +24,13 @@ require (
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/go-playground/validator/v10 v10.20.0 // indirect
+	github.com/go-sql-driver/mysql v1.7.0 // indirect
 	github.com/goccy/go-json v0.10.2 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/klauspost/cpuid/v2 v2.2.7 // indirect
+	github.com/kr/text v0.2.0 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -32,14 +46,5 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
-require (
-	github.com/gin-contrib/cors v1.7.2
-	github.com/gin-gonic/gin v1.10.0
-	github.com/go-sql-driver/mysql v1.7.0 // indirect
-	github.com/jinzhu/inflection v1.0.0 // indirect
-	github.com/jinzhu/now v1.1.5 // indirect
-	github.com/stevenferrer/solr-go v0.3.4
-	github.com/streadway/amqp v1.1.0
-	golang.org/x/text v0.15.0 // indirect
-	gorm.io/gorm v1.25.12
-)
+// Cliente HTTP compartido por las APIs (ver httpclient/)
+replace httpclient => ../httpclient
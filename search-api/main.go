@@ -1,13 +1,23 @@
 package main
 
 import (
-	"context"
+	"expvar"
 	"log"
+	"os"
 	"search-api/clients/queues"
+	adminController "search-api/controllers/admin"
+	analyticsController "search-api/controllers/analytics"
 	searchController "search-api/controllers/search"
+	"search-api/middleware"
+	analyticsRepo "search-api/repositories/analytics"
 	httpRepo "search-api/repositories/courses/courses_http"
+	memoryRepo "search-api/repositories/courses/courses_memory"
 	solrRepo "search-api/repositories/courses/courses_solr"
+	analyticsService "search-api/services/analytics"
+	reconcileService "search-api/services/reconcile"
+	reindexService "search-api/services/reindex"
 	searchService "search-api/services/search"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -19,12 +29,8 @@ func main() {
 	time.Sleep(2 * time.Minute)
 	log.Println("Iniciando la aplicación...")
 
-	// Configuración de SolR
-	solrClient := solrRepo.NewSolr(solrRepo.SolrConfig{
-		Host:       "solr",    // SolR host
-		Port:       "8983",    // SolR port
-		Collection: "courses", // Nombre de la colección en SolR
-	})
+	// Motor de búsqueda: SolR o el motor en memoria para desarrollo local
+	searchEngine := newSearchEngine(os.Getenv("SEARCH_ENGINE"))
 
 	// Configuración del cliente HTTP para la API de Cursos
 	coursesClient := httpRepo.NewHTTP(httpRepo.HTTPConfig{
@@ -32,23 +38,36 @@ func main() {
 		Port: "8080",
 	})
 
-	// Obtener todos los cursos
-	coursesList, err := coursesClient.GetCoursesAvailability(context.Background())
-	if err != nil {
-		log.Fatalf("Error al obtener cursos: %v", err)
-	}
-
-	// Indexar todos los cursos en Solr
-	if err := solrClient.IndexAllCourses(context.Background(), coursesList); err != nil {
-		log.Fatalf("Error al indexar cursos en Solr: %v", err)
-	}
+	// Reindexación completa: se construye un core nuevo y se intercambia con el
+	// actual, así que las búsquedas siguen respondiendo mientras tanto
+	reindexer := reindexService.NewService(searchEngine, coursesClient, reindexService.Config{
+		Collection: "courses",
+		BatchSize:  getEnvInt("REINDEX_BATCH_SIZE", 500),
+	})
 
 	// Inicialización del servicio de búsqueda
 	// Configuración de Solr y cliente HTTP
-	searchSvc := searchService.NewService(solrClient, coursesClient)
+	searchSvc := searchService.NewService(searchEngine, coursesClient)
+
+	// Analítica de búsquedas en MySQL: consultas, resultados, latencia y clics
+	var recorder searchController.Recorder
+	var analyticsSvc *analyticsService.Service
+	if os.Getenv("ANALYTICS_ENABLED") != "false" {
+		analyticsSvc = analyticsService.NewService(analyticsRepo.NewMySQL(analyticsRepo.MySQLConfig{
+			Host:     getEnv("ANALYTICS_DB_HOST", "mysql"),
+			Port:     getEnv("ANALYTICS_DB_PORT", "3306"),
+			Database: getEnv("ANALYTICS_DB_NAME", "search"),
+			Username: getEnv("ANALYTICS_DB_USER", "root"),
+			Password: getEnv("ANALYTICS_DB_PASSWORD", "root"),
+		}), analyticsService.Config{
+			Salt: os.Getenv("ANALYTICS_SALT"),
+		})
+		defer analyticsSvc.Close()
+		recorder = analyticsSvc
+	}
 
 	// Inicialización del controlador de búsqueda
-	searchCtrl := searchController.NewController(searchSvc)
+	searchCtrl := searchController.NewController(searchSvc, recorder)
 
 	// Lanzar el consumidor de RabbitMQ
 	eventsQueue := queues.NewRabbit(queues.RabbitConfig{
@@ -57,25 +76,127 @@ func main() {
 		Username:  "root",
 		Password:  "root",
 		QueueName: "courses_queue",
+		Workers:   getEnvInt("SEARCH_WORKERS", 4),
+		Prefetch:  getEnvInt("RABBITMQ_PREFETCH", 32),
 	})
+	defer eventsQueue.Close()
 
 	if err := eventsQueue.StartConsumer(searchSvc.HandleCourseUpdate); err != nil {
 		log.Fatalf("Error al ejecutar el consumidor: %v", err)
 	}
 
+	// Reconciliación periódica entre courses-api y el índice
+	reconciler := reconcileService.NewService(searchEngine, coursesClient, reconcileService.Config{
+		Interval: time.Duration(getEnvInt("RECONCILE_INTERVAL_MINUTES", 15)) * time.Minute,
+		PageSize: getEnvInt("RECONCILE_PAGE_SIZE", 200),
+		DryRun:   os.Getenv("RECONCILE_DRY_RUN") == "true",
+	})
+	reconciler.Start()
+	defer reconciler.Close()
+
+	// Indexar todos los cursos al iniciar. Un fallo no detiene la API: se sigue
+	// usando el índice existente y se puede reintentar desde /admin/reindex.
+	if _, err := reindexer.Start(); err != nil {
+		log.Printf("No se pudo iniciar la reindexación inicial: %v", err)
+	}
+
 	// Configuración del router con Gin
 	router := gin.Default()
 
 	router.Use(cors.New(cors.Config{
 		AllowAllOrigins: true,
 		AllowMethods:    []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:    []string{"Origin", "Content-Type"},
+		AllowHeaders:    []string{"Origin", "Content-Type", "Authorization"},
 	}))
 
-	router.GET("/search", searchCtrl.Search)
+	// Leer la clave JWT desde la variable de entorno
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		jwtSecret = "ThisIsAnExampleJWTKey!"
+	}
+
+	router.GET("/search", middleware.Identify(jwtSecret), searchCtrl.Search)
+	router.GET("/search/suggest", searchCtrl.Suggest)
+	router.GET("/search/courses/:id/similar", searchCtrl.Similar)
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler())) // Métricas del consumidor y de las colas
+
+	if analyticsSvc != nil {
+		analyticsCtrl := analyticsController.NewController(analyticsSvc)
+		router.POST("/search/click", middleware.Identify(jwtSecret), analyticsCtrl.Click)
+		router.GET("/search/analytics", middleware.AdminOnly(jwtSecret), analyticsCtrl.Report)
+	}
+
+	// Rutas de administración de eventos descartados, sinónimos, reindexación y reconciliación
+	adminCtrl := adminController.NewController(eventsQueue, searchEngine, reindexer, reconciler)
+	admin := router.Group("/admin", middleware.AdminOnly(jwtSecret))
+	admin.GET("/dead-letters", adminCtrl.ListDeadLetters)
+	admin.POST("/dead-letters/replay", adminCtrl.ReplayDeadLetters)
+	admin.DELETE("/dead-letters", adminCtrl.PurgeDeadLetters)
+	admin.GET("/synonyms", adminCtrl.ListSynonyms)
+	admin.POST("/synonyms", adminCtrl.AddSynonyms)
+	admin.DELETE("/synonyms/:term", adminCtrl.DeleteSynonym)
+	admin.POST("/reindex", adminCtrl.StartReindex)
+	admin.GET("/reindex", adminCtrl.ReindexStatus)
+	admin.GET("/reconcile", adminCtrl.DriftReport)
+	admin.POST("/reconcile", adminCtrl.TriggerReconcile)
 
 	// Ejecutar la API en el puerto 8082
 	if err := router.Run(":8082"); err != nil {
 		log.Fatalf("Error al ejecutar la aplicación: %v", err)
 	}
 }
+
+// engine reúne las operaciones que los servicios necesitan del motor de búsqueda
+type engine interface {
+	searchService.Repository
+	reindexService.Index
+	reconcileService.Index
+	adminController.SynonymStore
+}
+
+// newSearchEngine crea el motor de búsqueda indicado: "solr" (por defecto) o
+// "memory", que no necesita SolR pero pierde el índice al reiniciar
+func newSearchEngine(kind string) engine {
+	highlightPre := os.Getenv("SEARCH_HIGHLIGHT_PRE")
+	highlightPost := os.Getenv("SEARCH_HIGHLIGHT_POST")
+
+	switch kind {
+	case "", "solr":
+		return solrRepo.NewSolr(solrRepo.SolrConfig{
+			Host:       "solr",    // SolR host
+			Port:       "8983",    // SolR port
+			Collection: "courses", // Nombre de la colección en SolR
+
+			HighlightPre:      highlightPre,
+			HighlightPost:     highlightPost,
+			SpellcheckMaxHits: getEnvInt("SEARCH_SPELLCHECK_MAX_HITS", 3),
+		})
+	case "memory":
+		log.Println("Usando el motor de búsqueda en memoria")
+		return memoryRepo.NewMemory(memoryRepo.MemoryConfig{
+			Collection:    "courses",
+			HighlightPre:  highlightPre,
+			HighlightPost: highlightPost,
+		})
+	default:
+		log.Fatalf("Motor de búsqueda desconocido: %s", kind)
+		return nil
+	}
+}
+
+// getEnv lee una variable de entorno con un valor por defecto
+func getEnv(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt lee una variable de entorno numérica con un valor por defecto
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
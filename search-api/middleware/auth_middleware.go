@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AdminOnly es un middleware que permite el acceso solo a administradores.
+// A diferencia de courses-api, verifica la firma del token con la clave compartida.
+func AdminOnly(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" || !strings.HasPrefix(header, "Bearer ") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token de autorización requerido"})
+			c.Abort()
+			return
+		}
+
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		token, err := jwt.Parse(tokenString, signingKey(secret))
+		if err != nil || !token.Valid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+			c.Abort()
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No se pudieron leer los claims del token"})
+			c.Abort()
+			return
+		}
+
+		userType, ok := claims["user_type"].(string)
+		if !ok || userType != "administrador" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Acceso solo para administradores"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UserKey es la clave del contexto donde Identify guarda el ID del usuario
+const UserKey = "user_id"
+
+// Identify guarda en el contexto el ID del usuario si la petición trae un token
+// válido. A diferencia de AdminOnly, no rechaza las peticiones anónimas.
+func Identify(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.Next()
+			return
+		}
+
+		token, err := jwt.Parse(strings.TrimPrefix(header, "Bearer "), signingKey(secret))
+		if err == nil && token.Valid {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				if userID, ok := claims["user_id"].(float64); ok {
+					c.Set(UserKey, fmt.Sprintf("%.0f", userID))
+				}
+			}
+		}
+		c.Next()
+	}
+}
+
+// signingKey verifica que el token esté firmado con HMAC y devuelve la clave compartida
+func signingKey(secret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("método de firma inesperado: %v", token.Header["alg"])
+		}
+		return []byte(secret), nil
+	}
+}
@@ -0,0 +1,163 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	dao "search-api/dao/analytics"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type MySQLConfig struct {
+	Host     string
+	Port     string
+	Database string
+	Username string
+	Password string
+}
+
+type MySQL struct {
+	db *gorm.DB
+}
+
+var (
+	migrate = []interface{}{
+		dao.SearchEvent{},
+		dao.SearchClick{},
+	}
+)
+
+// NewMySQL se conecta a MySQL, crea la base de datos si no existe y migra las tablas
+func NewMySQL(config MySQLConfig) MySQL {
+	server := fmt.Sprintf("%s:%s@tcp(%s:%s)/?charset=utf8mb4&parseTime=True&loc=UTC",
+		config.Username, config.Password, config.Host, config.Port)
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
+		config.Username, config.Password, config.Host, config.Port, config.Database)
+
+	// Reintentar por si MySQL todavía está iniciando
+	var err error
+	maxAttempts := 5
+	for attempts := 1; attempts <= maxAttempts; attempts++ {
+		if err = createDatabase(server, config.Database); err == nil {
+			break
+		}
+		log.Printf("Attempt %d: Unable to connect to MySQL. Retrying...", attempts)
+		time.Sleep(2 * time.Second)
+	}
+	if err != nil {
+		log.Fatalf("failed to connect to MySQL after %d attempts: %s", maxAttempts, err.Error())
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("failed to connect to MySQL: %s", err.Error())
+	}
+
+	// Automigrate structs to GORM
+	for _, target := range migrate {
+		if err := db.AutoMigrate(target); err != nil {
+			log.Fatalf("error automigrating structs: %s", err.Error())
+		}
+	}
+
+	log.Println("Successfully connected to MySQL.")
+	return MySQL{
+		db: db,
+	}
+}
+
+// createDatabase crea la base de datos de analítica, que no está en mysql-init
+// en las instalaciones anteriores a ella
+func createDatabase(server string, database string) error {
+	db, err := gorm.Open(mysql.Open(server), &gorm.Config{})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	return db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", database)).Error
+}
+
+// SaveSearches guarda un lote de búsquedas
+func (repository MySQL) SaveSearches(ctx context.Context, events []dao.SearchEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := repository.db.WithContext(ctx).Create(&events).Error; err != nil {
+		return fmt.Errorf("error saving %d search events: %w", len(events), err)
+	}
+	return nil
+}
+
+// SaveClick guarda un clic
+func (repository MySQL) SaveClick(ctx context.Context, click dao.SearchClick) error {
+	if err := repository.db.WithContext(ctx).Create(&click).Error; err != nil {
+		return fmt.Errorf("error saving click for query %s: %w", click.QueryID, err)
+	}
+	return nil
+}
+
+// clickedSearch indica si una búsqueda tuvo al menos un clic
+const clickedSearch = "EXISTS (SELECT 1 FROM search_clicks c WHERE c.query_id = search_events.query_id)"
+
+// Totals resume las búsquedas hechas desde since
+func (repository MySQL) Totals(ctx context.Context, since time.Time) (dao.Totals, error) {
+	var totals dao.Totals
+	err := repository.db.WithContext(ctx).Model(&dao.SearchEvent{}).
+		Select("COUNT(*) AS searches, "+
+			"COUNT(DISTINCT user_hash) AS users, "+
+			"COALESCE(SUM(results = 0), 0) AS zero_results, "+
+			"COALESCE(SUM("+clickedSearch+"), 0) AS clicked_searches, "+
+			"COALESCE(AVG(latency_ms), 0) AS avg_latency_ms").
+		Where("created_at >= ?", since).
+		Scan(&totals).Error
+	if err != nil {
+		return dao.Totals{}, fmt.Errorf("error computing search totals: %w", err)
+	}
+	return totals, nil
+}
+
+// TopQueries devuelve las consultas más buscadas desde since
+func (repository MySQL) TopQueries(ctx context.Context, since time.Time, limit int) ([]dao.QueryStat, error) {
+	stats, err := repository.queryStats(ctx, since, limit, false)
+	if err != nil {
+		return nil, fmt.Errorf("error computing top queries: %w", err)
+	}
+	return stats, nil
+}
+
+// ZeroResultQueries devuelve las consultas sin resultados más buscadas desde since
+func (repository MySQL) ZeroResultQueries(ctx context.Context, since time.Time, limit int) ([]dao.QueryStat, error) {
+	stats, err := repository.queryStats(ctx, since, limit, true)
+	if err != nil {
+		return nil, fmt.Errorf("error computing zero-result queries: %w", err)
+	}
+	return stats, nil
+}
+
+func (repository MySQL) queryStats(ctx context.Context, since time.Time, limit int, zeroResults bool) ([]dao.QueryStat, error) {
+	query := repository.db.WithContext(ctx).Model(&dao.SearchEvent{}).
+		Select("query, "+
+			"COUNT(*) AS searches, "+
+			"COUNT(DISTINCT user_hash) AS users, "+
+			"AVG(results) AS avg_results, "+
+			"COALESCE(SUM("+clickedSearch+"), 0) AS clicked_searches, "+
+			"MAX(created_at) AS last_searched_at").
+		Where("created_at >= ?", since)
+	if zeroResults {
+		query = query.Where("results = 0")
+	}
+
+	var stats []dao.QueryStat
+	err := query.Group("query").
+		Order("searches DESC, last_searched_at DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
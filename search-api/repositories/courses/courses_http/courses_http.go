@@ -2,13 +2,13 @@ package courses
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	daoCourses "search-api/dao/courses"       // Alias para evitar conflictos
 	domainCourses "search-api/domain/courses" // Alias para evitar conflictos
+
+	"httpclient"
 )
 
 type HTTPConfig struct {
@@ -17,42 +17,37 @@ type HTTPConfig struct {
 }
 
 type HTTP struct {
-	baseURL func(courseID string) string
+	client   *httpclient.Client
+	baseURL  func(courseID string) string
+	pagedURL func(offset int, limit int) string
 }
 
 // NewHTTP crea una nueva conexión a la API de cursos
 func NewHTTP(config HTTPConfig) HTTP {
 	return HTTP{
+		client: httpclient.New(httpclient.Config{Name: "courses-api"}),
 		baseURL: func(courseID string) string {
 			return fmt.Sprintf("http://%s:%s/courses/%s", config.Host, config.Port, courseID)
 		},
+		pagedURL: func(offset int, limit int) string {
+			return fmt.Sprintf("http://%s:%s/courses?offset=%d&limit=%d", config.Host, config.Port, offset, limit)
+		},
 	}
 }
 
-// GetCourseByID obtiene los detalles de un curso usando su ID
+// GetCourseByID obtiene los detalles de un curso usando su ID. Si el curso no
+// existe, el error cumple errors.Is(err, httpclient.ErrNotFound).
 func (repository HTTP) GetCourseByID(ctx context.Context, id string) (domainCourses.CourseUpdate, error) {
-	resp, err := http.Get(repository.baseURL(id))
-	if err != nil {
-		return domainCourses.CourseUpdate{}, fmt.Errorf("error fetching course (%s): %w", id, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return domainCourses.CourseUpdate{}, fmt.Errorf("failed to fetch course (%s): received status code %d", id, resp.StatusCode)
-	}
-
-	// Lee el cuerpo de la respuesta
-	body, err := io.ReadAll(resp.Body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, repository.baseURL(id), nil)
 	if err != nil {
-		return domainCourses.CourseUpdate{}, fmt.Errorf("error reading response body for course (%s): %w", id, err)
+		return domainCourses.CourseUpdate{}, fmt.Errorf("error building course request (%s): %w", id, err)
 	}
 
 	// Deserializa los datos del curso en la estructura CourseUpdate
 	var course domainCourses.CourseUpdate // Usando CourseUpdate del dominio
-	if err := json.Unmarshal(body, &course); err != nil {
-		return domainCourses.CourseUpdate{}, fmt.Errorf("error unmarshaling course data (%s): %w", id, err)
+	if err := repository.client.DoJSON(req, &course); err != nil {
+		return domainCourses.CourseUpdate{}, fmt.Errorf("error fetching course (%s): %w", id, err)
 	}
-
 	return course, nil
 }
 
@@ -63,26 +58,31 @@ func (repository HTTP) GetCoursesAvailability(ctx context.Context) ([]daoCourses
 	url := repository.baseURL("availability")      // Solo llama a baseURL sin concatenar http://
 	log.Printf("URL de la API de cursos: %s", url) // Log de la URL
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		log.Printf("Error al hacer la solicitud HTTP: %v", err) // Log del error
+		return nil, fmt.Errorf("error building courses request: %w", err)
+	}
+
+	var courses []daoCourses.Course // Usando Course del DAO
+	if err := repository.client.DoJSON(req, &courses); err != nil {
+		log.Printf("Error al obtener cursos: %v", err) // Log del error
 		return nil, fmt.Errorf("error fetching courses: %w", err)
 	}
-	defer resp.Body.Close()
 
-	log.Printf("Código de estado de la respuesta: %d", resp.StatusCode) // Log del código de estado
+	log.Printf("Cursos obtenidos: %+v", courses) // Log de los cursos obtenidos
+	return courses, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error al obtener cursos: código de estado recibido %d", resp.StatusCode) // Log del error
-		return nil, fmt.Errorf("failed to fetch courses: received status code %d", resp.StatusCode)
+// GetCoursesPage obtiene una página de cursos (disponibles o no) ordenados por ID
+func (repository HTTP) GetCoursesPage(ctx context.Context, offset int, limit int) ([]daoCourses.Course, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, repository.pagedURL(offset, limit), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building courses request: %w", err)
 	}
 
-	var courses []daoCourses.Course // Usando Course del DAO
-	if err := json.NewDecoder(resp.Body).Decode(&courses); err != nil {
-		log.Printf("Error al deserializar los datos de los cursos: %v", err) // Log del error
-		return nil, fmt.Errorf("error unmarshaling courses data: %w", err)
+	var courses []daoCourses.Course
+	if err := repository.client.DoJSON(req, &courses); err != nil {
+		return nil, fmt.Errorf("error fetching courses page (offset %d): %w", offset, err)
 	}
-
-	log.Printf("Cursos obtenidos: %+v", courses) // Log de los cursos obtenidos
 	return courses, nil
 }
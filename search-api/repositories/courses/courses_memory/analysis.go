@@ -0,0 +1,122 @@
+package courses
+
+import (
+	solrconfig "search-api/solr-config"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldTerm pasa a minúsculas y quita los diacríticos (tildes, diéresis, la
+// virgulilla de la ñ), como LowerCaseFilter seguido de ASCIIFoldingFilter
+func foldTerm(term string) string {
+	// Las cadenas de transformaciones guardan estado: se arma una por llamada
+	fold := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(fold, strings.ToLower(term))
+	if err != nil {
+		return strings.ToLower(term)
+	}
+	return folded
+}
+
+// stopwords son las palabras vacías de text_es, leídas del mismo archivo que usa SolR
+var stopwords = parseStopwords(solrconfig.StopwordsES)
+
+// parseStopwords lee una lista en el formato de StopFilterFactory: una palabra
+// por línea y comentarios en las líneas que empiezan con #. Como el filtro usa
+// ignoreCase, las palabras se pasan a minúsculas.
+func parseStopwords(list string) map[string]bool {
+	words := map[string]bool{}
+	for _, line := range strings.Split(list, "\n") {
+		word := strings.TrimSpace(line)
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words[strings.ToLower(word)] = true
+	}
+	return words
+}
+
+// word es una secuencia de letras o dígitos de un texto, con sus posiciones en bytes
+type word struct {
+	text       string
+	start, end int
+}
+
+// words divide un texto como StandardTokenizer para la prosa común
+func words(text string) []word {
+	var result []word
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			result = append(result, word{text: text[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		result = append(result, word{text: text[start:], start: start, end: len(text)})
+	}
+	return result
+}
+
+// stem es el stemmer liviano de español de Lucene (SpanishLightStemFilter)
+// sobre texto ya normalizado: quita las terminaciones de plural y de género de
+// las palabras largas
+func stem(term string) string {
+	n := len(term)
+	if n < 5 {
+		return term
+	}
+	switch term[n-1] {
+	case 'o', 'a', 'e':
+		return term[:n-1]
+	case 's':
+		if term[n-2] == 'e' && term[n-3] == 's' && term[n-4] == 'e' {
+			return term[:n-2]
+		}
+		if term[n-2] == 'e' && term[n-3] == 'c' {
+			return term[:n-3] + "z"
+		}
+		if term[n-2] == 'o' || term[n-2] == 'a' || term[n-2] == 'e' {
+			return term[:n-2]
+		}
+	}
+	return term
+}
+
+// analyzeWord devuelve el término indexado de una palabra, o "" si es una palabra vacía (text_es)
+func analyzeWord(text string) string {
+	term := foldTerm(text)
+	if stopwords[term] {
+		return ""
+	}
+	return stem(term)
+}
+
+// analyze convierte un texto en su secuencia de términos indexados (text_es)
+func analyze(text string) []string {
+	var terms []string
+	for _, w := range words(text) {
+		if term := analyzeWord(w.text); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// prefixTerms devuelve las palabras normalizadas de un texto, sin quitar las
+// palabras vacías ni aplicar stemming, como el campo text_suggest
+func prefixTerms(text string) []string {
+	var terms []string
+	for _, w := range words(text) {
+		terms = append(terms, foldTerm(w.text))
+	}
+	return terms
+}
@@ -0,0 +1,400 @@
+package courses
+
+import (
+	"context"
+	"fmt"
+	daoCourses "search-api/dao/courses"
+	domainCourses "search-api/domain/courses"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryConfig configura el motor en memoria
+type MemoryConfig struct {
+	Collection string // Nombre del core en vivo
+
+	// HighlightPre y HighlightPost rodean los términos resaltados (por defecto <em> y </em>)
+	HighlightPre  string
+	HighlightPost string
+}
+
+// Memory es un motor de búsqueda dentro del proceso. Guarda los cursos en
+// memoria y reproduce el comportamiento de la configuración de SolR de
+// solr-config: el análisis de text_es (con las mismas palabras vacías), los
+// boosts y el mínimo de coincidencias de /search, los filtros con facetas
+// multi-selección, los órdenes, el paginado, las sugerencias, los sinónimos,
+// las bajas y los cores de la reindexación. Los puntajes sirven para ordenar
+// una consulta pero no son los de SolR, y no propone correcciones ortográficas.
+//
+// Está pensado para desarrollo local y tests, donde no vale la pena levantar
+// SolR; el índice se pierde al terminar el proceso.
+type Memory struct {
+	collection    string
+	highlightPre  string
+	highlightPost string
+
+	mu       sync.RWMutex
+	cores    map[string]*memoryCore
+	synonyms map[string][]string
+	shadow   string // Core en reconstrucción, recibe una copia de cada escritura en vivo
+	mirrored int
+}
+
+// memoryCore guarda los documentos de un core, bajas incluidas
+type memoryCore struct {
+	docs map[int64]*memoryDoc
+}
+
+// memoryDoc es un curso con sus campos analizados
+type memoryDoc struct {
+	course        daoCourses.Course
+	name          []string
+	category      []string
+	description   []string
+	nameWords     []string // Palabras normalizadas del nombre, para las sugerencias
+	categoryWords []string // Palabras normalizadas de la categoría, para las sugerencias
+	nameSort      string
+}
+
+// NewMemory crea un motor en memoria vacío
+func NewMemory(config MemoryConfig) *Memory {
+	if config.HighlightPre == "" {
+		config.HighlightPre = "<em>"
+	}
+	if config.HighlightPost == "" {
+		config.HighlightPost = "</em>"
+	}
+	return &Memory{
+		collection:    config.Collection,
+		highlightPre:  config.HighlightPre,
+		highlightPost: config.HighlightPost,
+		cores:         map[string]*memoryCore{config.Collection: newMemoryCore()},
+		synonyms:      map[string][]string{},
+	}
+}
+
+func newMemoryCore() *memoryCore {
+	return &memoryCore{docs: map[int64]*memoryDoc{}}
+}
+
+func newMemoryDoc(course daoCourses.Course) *memoryDoc {
+	course.Score = 0
+	course.HighlightedName = ""
+	course.HighlightedDescription = ""
+	return &memoryDoc{
+		course:        course,
+		name:          analyze(course.Name),
+		category:      analyze(course.Category),
+		description:   analyze(course.Description),
+		nameWords:     prefixTerms(course.Name),
+		categoryWords: prefixTerms(course.Category),
+		nameSort:      foldTerm(course.Name),
+	}
+}
+
+// core devuelve un core por nombre; quien llama debe tener el lock
+func (engine *Memory) core(name string) (*memoryCore, error) {
+	core, ok := engine.cores[name]
+	if !ok {
+		return nil, fmt.Errorf("core %s not found", name)
+	}
+	return core, nil
+}
+
+// write guarda un documento en el core en vivo y, durante una reindexación, en el core en reconstrucción
+func (engine *Memory) write(course daoCourses.Course) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	engine.cores[engine.collection].docs[course.ID] = newMemoryDoc(course)
+	if core, ok := engine.cores[engine.shadow]; ok && engine.shadow != "" {
+		core.docs[course.ID] = newMemoryDoc(course)
+		engine.mirrored++
+	}
+}
+
+// Index agrega un curso al core en vivo
+func (engine *Memory) Index(ctx context.Context, course daoCourses.Course) (string, error) {
+	course.Deleted = false
+	engine.write(course)
+	return strconv.FormatInt(course.ID, 10), nil
+}
+
+// Update reemplaza un curso en el core en vivo
+func (engine *Memory) Update(ctx context.Context, course daoCourses.Course) error {
+	course.Deleted = false
+	engine.write(course)
+	return nil
+}
+
+// Delete reemplaza un curso por una baja que conserva la versión del borrado
+func (engine *Memory) Delete(ctx context.Context, id string, version int64) error {
+	courseID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid course id %q: %w", id, err)
+	}
+	engine.write(daoCourses.Course{ID: courseID, Version: version, Deleted: true})
+	return nil
+}
+
+// Version devuelve la versión guardada de un curso (incluidas las bajas), o 0 si nunca se indexó
+func (engine *Memory) Version(ctx context.Context, id string) (int64, error) {
+	courseID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid course id %q: %w", id, err)
+	}
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	if doc, ok := engine.cores[engine.collection].docs[courseID]; ok {
+		return doc.course.Version, nil
+	}
+	return 0, nil
+}
+
+// Documents devuelve los documentos (incluidas las bajas) de los cursos dados en el core en vivo
+func (engine *Memory) Documents(ctx context.Context, ids []int64) ([]daoCourses.Course, error) {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	var courses []daoCourses.Course
+	for _, id := range ids {
+		if doc, ok := engine.cores[engine.collection].docs[id]; ok {
+			courses = append(courses, doc.course)
+		}
+	}
+	return courses, nil
+}
+
+// LiveIDs recorre los IDs de los cursos vigentes (no borrados) del core en vivo, en páginas ordenadas por ID
+func (engine *Memory) LiveIDs(ctx context.Context, pageSize int, visit func(ids []int64) error) error {
+	engine.mu.RLock()
+	var ids []int64
+	for id, doc := range engine.cores[engine.collection].docs {
+		if !doc.course.Deleted {
+			ids = append(ids, id)
+		}
+	}
+	engine.mu.RUnlock()
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for start := 0; start < len(ids); start += pageSize {
+		end := start + pageSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := visit(ids[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tombstones recorre las bajas del core en vivo en páginas ordenadas por ID
+func (engine *Memory) Tombstones(ctx context.Context, pageSize int, visit func(tombstones []daoCourses.Course) error) error {
+	engine.mu.RLock()
+	var tombstones []daoCourses.Course
+	for _, doc := range engine.cores[engine.collection].docs {
+		if doc.course.Deleted {
+			tombstones = append(tombstones, doc.course)
+		}
+	}
+	engine.mu.RUnlock()
+	sort.Slice(tombstones, func(i, j int) bool { return tombstones[i].ID < tombstones[j].ID })
+
+	for start := 0; start < len(tombstones); start += pageSize {
+		end := start + pageSize
+		if end > len(tombstones) {
+			end = len(tombstones)
+		}
+		if err := visit(tombstones[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateCore crea un core vacío
+func (engine *Memory) CreateCore(ctx context.Context, name string) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if _, ok := engine.cores[name]; ok {
+		return fmt.Errorf("core %s already exists", name)
+	}
+	engine.cores[name] = newMemoryCore()
+	return nil
+}
+
+// UnloadCore elimina un core
+func (engine *Memory) UnloadCore(ctx context.Context, name string) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	if name == engine.collection {
+		return fmt.Errorf("the live core cannot be unloaded")
+	}
+	if _, err := engine.core(name); err != nil {
+		return err
+	}
+	delete(engine.cores, name)
+	return nil
+}
+
+// SwapCores intercambia atómicamente el core en vivo con el dado
+func (engine *Memory) SwapCores(ctx context.Context, other string) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	core, err := engine.core(other)
+	if err != nil {
+		return err
+	}
+	engine.cores[other], engine.cores[engine.collection] = engine.cores[engine.collection], core
+	return nil
+}
+
+// StartMirror empieza a repetir las escrituras en vivo en el core dado
+func (engine *Memory) StartMirror(core string) {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	engine.shadow = core
+	engine.mirrored = 0
+}
+
+// StopMirror deja de repetir las escrituras en vivo
+func (engine *Memory) StopMirror() {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	engine.shadow = ""
+}
+
+// MirroredWrites devuelve cuántos documentos se escribieron en el core espejo desde StartMirror
+func (engine *Memory) MirroredWrites() int {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	return engine.mirrored
+}
+
+// AddBatch escribe documentos de cursos (bajas para los borrados) en un core.
+// Las escrituras son visibles en el acto, así que commitWithin se ignora.
+func (engine *Memory) AddBatch(ctx context.Context, name string, courses []daoCourses.Course, commitWithin time.Duration) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	core, err := engine.core(name)
+	if err != nil {
+		return err
+	}
+	for _, course := range courses {
+		core.docs[course.ID] = newMemoryDoc(course)
+	}
+	return nil
+}
+
+// CommitCore no hace nada: las escrituras son visibles en el acto
+func (engine *Memory) CommitCore(ctx context.Context, name string) error {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	_, err := engine.core(name)
+	return err
+}
+
+// Versions devuelve la versión guardada (incluidas las bajas) de los cursos dados en un core
+func (engine *Memory) Versions(ctx context.Context, name string, ids []int64) (map[int64]int64, error) {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	core, err := engine.core(name)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]int64, len(ids))
+	for _, id := range ids {
+		if doc, ok := core.docs[id]; ok {
+			versions[id] = doc.course.Version
+		}
+	}
+	return versions, nil
+}
+
+// Count devuelve la cantidad de cursos vigentes (no borrados) de un core
+func (engine *Memory) Count(ctx context.Context, name string) (int, error) {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	core, err := engine.core(name)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, doc := range core.docs {
+		if !doc.course.Deleted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// normalizeSynonym normaliza un sinónimo como lo hace el analizador de consultas antes del filtro de sinónimos
+func normalizeSynonym(term string) string {
+	return strings.Join(strings.Fields(foldTerm(term)), " ")
+}
+
+// Synonyms devuelve los sinónimos vigentes (término -> sinónimos)
+func (engine *Memory) Synonyms(ctx context.Context) (map[string][]string, error) {
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+	synonyms := make(map[string][]string, len(engine.synonyms))
+	for term, mapped := range engine.synonyms {
+		synonyms[term] = append([]string(nil), mapped...)
+	}
+	return synonyms, nil
+}
+
+// AddSynonyms guarda un conjunto de términos equivalentes (cada uno lleva a todos los demás)
+func (engine *Memory) AddSynonyms(ctx context.Context, terms []string) ([]string, error) {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, term := range terms {
+		term = normalizeSynonym(term)
+		if term != "" && !seen[term] {
+			seen[term] = true
+			normalized = append(normalized, term)
+		}
+	}
+	if len(normalized) < 2 {
+		return nil, fmt.Errorf("%w: se necesitan al menos dos términos distintos", domainCourses.ErrInvalidSynonyms)
+	}
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	for _, term := range normalized {
+		mapped := map[string]bool{}
+		for _, existing := range engine.synonyms[term] {
+			mapped[existing] = true
+		}
+		for _, other := range normalized {
+			if other != term {
+				mapped[other] = true
+			}
+		}
+		engine.synonyms[term] = sortedKeys(mapped)
+	}
+	return normalized, nil
+}
+
+// DeleteSynonym elimina los sinónimos de un término
+func (engine *Memory) DeleteSynonym(ctx context.Context, term string) error {
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+	term = normalizeSynonym(term)
+	if _, ok := engine.synonyms[term]; !ok {
+		return fmt.Errorf("%w: %s", domainCourses.ErrSynonymNotFound, term)
+	}
+	delete(engine.synonyms, term)
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
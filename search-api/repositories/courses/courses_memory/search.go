@@ -0,0 +1,444 @@
+package courses
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math"
+	daoCourses "search-api/dao/courses"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Boosts por campo del manejador /search (qf y pf en solrconfig.xml)
+var (
+	queryBoosts  = fieldBoosts{name: 4, category: 2, description: 1}
+	phraseBoosts = fieldBoosts{name: 8, category: 3, description: 2}
+)
+
+type fieldBoosts struct {
+	name, category, description float64
+}
+
+// Faceta de rangos de calificación: un rango por estrella, el último incluye el 5
+const (
+	ratingFacetEnd = 5
+	ratingFacetGap = 1
+)
+
+// clause es un término o una frase entre comillas del usuario. Coincide con un
+// campo cuando alguna de sus alternativas (sus términos o los de un sinónimo)
+// aparece en él en orden.
+type clause [][]string
+
+// parseQuery divide el texto del usuario como escapeQuery para SolR (términos
+// y frases entre comillas) y analiza cada parte, expandiendo los sinónimos. Se
+// descartan las partes formadas solo por palabras vacías. Quien llama debe
+// tener el lock de lectura.
+func (engine *Memory) parseQuery(query string) []clause {
+	var (
+		parts   []string
+		current strings.Builder
+		phrase  bool
+	)
+	flush := func() {
+		if current.Len() > 0 {
+			parts = append(parts, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range query {
+		switch {
+		case r == '"':
+			flush()
+			phrase = !phrase
+		case !phrase && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	var clauses []clause
+	for _, part := range parts {
+		terms := analyze(part)
+		if len(terms) == 0 {
+			continue
+		}
+		alternatives := clause{terms}
+		for _, synonym := range engine.synonyms[strings.Join(prefixTerms(part), " ")] {
+			if synonymTerms := analyze(synonym); len(synonymTerms) > 0 {
+				alternatives = append(alternatives, synonymTerms)
+			}
+		}
+		clauses = append(clauses, alternatives)
+	}
+	return clauses
+}
+
+// minimumShouldMatch implementa mm=2<-1 5<75%: hasta 2 cláusulas se exigen
+// todas, hasta 5 todas menos una y desde ahí el 75% (redondeado hacia abajo)
+func minimumShouldMatch(clauses int) int {
+	switch {
+	case clauses <= 2:
+		return clauses
+	case clauses <= 5:
+		return clauses - 1
+	default:
+		return clauses * 75 / 100
+	}
+}
+
+// containsSequence indica si los términos aparecen seguidos en el campo
+func containsSequence(field []string, terms []string) bool {
+	for start := 0; start+len(terms) <= len(field); start++ {
+		match := true
+		for i, term := range terms {
+			if field[start+i] != term {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (c clause) matches(field []string) bool {
+	for _, terms := range c {
+		if containsSequence(field, terms) {
+			return true
+		}
+	}
+	return false
+}
+
+// boost devuelve el mayor boost entre los campos del documento con los que coincide la cláusula (0 si ninguno)
+func (c clause) boost(doc *memoryDoc, boosts fieldBoosts) float64 {
+	switch {
+	case c.matches(doc.name):
+		return boosts.name
+	case c.matches(doc.category):
+		return boosts.category
+	case c.matches(doc.description):
+		return boosts.description
+	}
+	return 0
+}
+
+// hit es un documento que coincidió con la consulta, con su puntaje
+type hit struct {
+	doc   *memoryDoc
+	score float64
+}
+
+// passes indica si un curso cumple los filtros, salvo el indicado en except
+// (para las facetas multi-selección)
+func passes(course daoCourses.Course, filters daoCourses.SearchFilters, except string) bool {
+	if except != "category" && filters.Category != "" && course.Category != filters.Category {
+		return false
+	}
+	if except != "rating" && filters.MinRating != nil && course.Rating < *filters.MinRating {
+		return false
+	}
+	if except != "available" && filters.Available != nil && course.Available != *filters.Available {
+		return false
+	}
+	if filters.InstructorID != 0 && course.InstructorID != filters.InstructorID {
+		return false
+	}
+	return true
+}
+
+// Search ejecuta una consulta con la semántica del manejador /search de SolR:
+// los términos y las frases se buscan en nombre, categoría y descripción con
+// los mismos boosts y mínimo de coincidencias, los filtros no afectan el
+// puntaje, cada faceta ignora su propio filtro y los empates se resuelven por
+// id para que el paginado sea estable.
+func (engine *Memory) Search(ctx context.Context, query string, filters daoCourses.SearchFilters, sortBy daoCourses.SearchSort, offset int, limit int) (daoCourses.SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return daoCourses.SearchResult{}, fmt.Errorf("la consulta no puede estar vacía")
+	}
+	started := time.Now()
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	clauses := engine.parseQuery(query)
+	hits := engine.match(clauses)
+
+	var results []hit
+	for _, h := range hits {
+		if passes(h.doc.course, filters, "") {
+			results = append(results, h)
+		}
+	}
+	sortHits(results, sortBy)
+
+	var courses []daoCourses.Course
+	if offset < len(results) {
+		end := offset + limit
+		if end > len(results) {
+			end = len(results)
+		}
+		terms := matchedTerms(clauses)
+		for _, h := range results[offset:end] {
+			course := h.doc.course
+			course.Score = h.score
+			course.HighlightedName = engine.highlight(course.Name, terms)
+			course.HighlightedDescription = engine.highlight(course.Description, terms)
+			courses = append(courses, course)
+		}
+	}
+
+	return daoCourses.SearchResult{
+		Courses:  courses,
+		NumFound: len(results),
+		QTime:    int(time.Since(started).Milliseconds()),
+		Facets:   facets(hits, filters),
+	}, nil
+}
+
+// match devuelve los documentos vigentes que alcanzan el mínimo de
+// coincidencias. El puntaje suma, por cada cláusula, el boost del mejor campo
+// ponderado por lo rara que es, más los boosts de frase cuando la consulta
+// completa aparece en orden en un campo.
+func (engine *Memory) match(clauses []clause) []hit {
+	if len(clauses) == 0 {
+		return nil
+	}
+	docs := engine.cores[engine.collection].docs
+
+	live := 0
+	frequencies := make([]int, len(clauses))
+	for _, doc := range docs {
+		if doc.course.Deleted {
+			continue
+		}
+		live++
+		for i, c := range clauses {
+			if c.boost(doc, queryBoosts) > 0 {
+				frequencies[i]++
+			}
+		}
+	}
+	idf := make([]float64, len(clauses))
+	for i, df := range frequencies {
+		idf[i] = math.Log(1 + (float64(live-df)+0.5)/(float64(df)+0.5))
+	}
+
+	var phrase []string
+	for _, c := range clauses {
+		phrase = append(phrase, c[0]...)
+	}
+
+	required := minimumShouldMatch(len(clauses))
+	var hits []hit
+	for _, doc := range docs {
+		if doc.course.Deleted {
+			continue
+		}
+		matched := 0
+		score := 0.0
+		for i, c := range clauses {
+			if boost := c.boost(doc, queryBoosts); boost > 0 {
+				matched++
+				score += boost * idf[i]
+			}
+		}
+		if matched < required {
+			continue
+		}
+		if len(phrase) > 1 {
+			score += clause{phrase}.boost(doc, phraseBoosts)
+		}
+		hits = append(hits, hit{doc: doc, score: score})
+	}
+	return hits
+}
+
+// sortHits ordena los resultados como sortClause para SolR
+func sortHits(hits []hit, sortBy daoCourses.SearchSort) {
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		switch sortBy {
+		case daoCourses.SortByRating:
+			if a.doc.course.Rating != b.doc.course.Rating {
+				return a.doc.course.Rating > b.doc.course.Rating
+			}
+			if a.score != b.score {
+				return a.score > b.score
+			}
+		case daoCourses.SortByName:
+			if a.doc.nameSort != b.doc.nameSort {
+				return a.doc.nameSort < b.doc.nameSort
+			}
+		default:
+			if a.score != b.score {
+				return a.score > b.score
+			}
+		}
+		return a.doc.course.ID < b.doc.course.ID
+	})
+}
+
+// facets cuenta categorías, rangos de calificación y disponibilidad sobre los
+// documentos encontrados, cada una ignorando su propio filtro
+func facets(hits []hit, filters daoCourses.SearchFilters) daoCourses.Facets {
+	categories := map[string]int{}
+	availability := map[string]int{}
+	ratings := make([]int, ratingFacetEnd/ratingFacetGap)
+
+	for _, h := range hits {
+		course := h.doc.course
+		if passes(course, filters, "category") && course.Category != "" {
+			categories[course.Category]++
+		}
+		if passes(course, filters, "available") {
+			availability[fmt.Sprint(course.Available)]++
+		}
+		if passes(course, filters, "rating") && course.Rating >= 0 && course.Rating <= ratingFacetEnd {
+			bucket := int(course.Rating / ratingFacetGap)
+			if bucket == len(ratings) {
+				bucket-- // El último rango incluye su extremo superior
+			}
+			ratings[bucket]++
+		}
+	}
+
+	result := daoCourses.Facets{
+		Categories:   sortedCounts(categories),
+		Availability: sortedCounts(availability),
+	}
+	for i, count := range ratings {
+		lower := i * ratingFacetGap
+		result.Ratings = append(result.Ratings, daoCourses.FacetCount{
+			Value: fmt.Sprintf("%d-%d", lower, lower+ratingFacetGap),
+			Count: count,
+		})
+	}
+	return result
+}
+
+// sortedCounts ordena los valores de una faceta por cantidad y luego por valor, como SolR
+func sortedCounts(counts map[string]int) []daoCourses.FacetCount {
+	result := make([]daoCourses.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		result = append(result, daoCourses.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// matchedTerms devuelve todos los términos analizados de la consulta, sinónimos incluidos
+func matchedTerms(clauses []clause) map[string]bool {
+	terms := map[string]bool{}
+	for _, c := range clauses {
+		for _, alternative := range c {
+			for _, term := range alternative {
+				terms[term] = true
+			}
+		}
+	}
+	return terms
+}
+
+// highlight rodea con los marcadores las palabras del texto que coinciden con
+// un término de la consulta y escapa el resto como HTML. Devuelve "" si nada
+// coincidió.
+func (engine *Memory) highlight(text string, terms map[string]bool) string {
+	var result strings.Builder
+	last := 0
+	marked := false
+	for _, w := range words(text) {
+		if !terms[analyzeWord(w.text)] {
+			continue
+		}
+		result.WriteString(html.EscapeString(text[last:w.start]))
+		result.WriteString(engine.highlightPre)
+		result.WriteString(html.EscapeString(w.text))
+		result.WriteString(engine.highlightPost)
+		last = w.end
+		marked = true
+	}
+	if !marked {
+		return ""
+	}
+	result.WriteString(html.EscapeString(text[last:]))
+	return result.String()
+}
+
+// Suggest devuelve los cursos disponibles con palabras del nombre o de la
+// categoría que empiezan con los términos escritos (todos deben coincidir),
+// como los campos con edge n-grams
+func (engine *Memory) Suggest(ctx context.Context, prefix string, limit int) ([]daoCourses.Course, error) {
+	prefixes := prefixTerms(prefix)
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("el prefijo no puede estar vacío")
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	var hits []hit
+	for _, doc := range engine.cores[engine.collection].docs {
+		if doc.course.Deleted || !doc.course.Available {
+			continue
+		}
+		score := 0.0
+		for _, p := range prefixes {
+			switch {
+			case hasPrefix(doc.nameWords, p):
+				score += 3
+			case hasPrefix(doc.categoryWords, p):
+				score++
+			default:
+				score = -1
+			}
+			if score < 0 {
+				break
+			}
+		}
+		if score > 0 {
+			hits = append(hits, hit{doc: doc, score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.doc.nameSort != b.doc.nameSort {
+			return a.doc.nameSort < b.doc.nameSort
+		}
+		return a.doc.course.ID < b.doc.course.ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	suggestions := make([]daoCourses.Course, 0, len(hits))
+	for _, h := range hits {
+		suggestions = append(suggestions, h.doc.course)
+	}
+	return suggestions, nil
+}
+
+func hasPrefix(words []string, prefix string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package courses
+
+import (
+	"context"
+	"fmt"
+	"math"
+	daoCourses "search-api/dao/courses"
+	domainCourses "search-api/domain/courses"
+	"sort"
+	"time"
+)
+
+// Parámetros del manejador /mlt (solrconfig.xml)
+const (
+	similarMinWordLength = 3
+	similarMaxTerms      = 25
+)
+
+// fieldTerm es un término de uno de los campos de texto de un curso
+type fieldTerm struct {
+	field int // 0 nombre, 1 categoría, 2 descripción
+	term  string
+}
+
+func (doc *memoryDoc) fields() [3][]string {
+	return [3][]string{doc.name, doc.category, doc.description}
+}
+
+// Similar devuelve los cursos disponibles más parecidos a uno dado en nombre,
+// categoría y descripción, como el manejador MoreLikeThis: los términos más
+// representativos del curso (frecuentes en él y raros en el índice) se
+// ponderan por campo y se buscan en los demás cursos.
+func (engine *Memory) Similar(ctx context.Context, id int64, offset int, limit int) (daoCourses.SearchResult, error) {
+	started := time.Now()
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	docs := engine.cores[engine.collection].docs
+	source, ok := docs[id]
+	if !ok {
+		return daoCourses.SearchResult{}, fmt.Errorf("%w: %d", domainCourses.ErrCourseNotFound, id)
+	}
+	if source.course.Deleted {
+		return daoCourses.SearchResult{}, nil
+	}
+
+	// Frecuencia en documentos de cada término del curso de origen, por campo
+	frequencies := map[fieldTerm]int{}
+	live := 0
+	for _, doc := range docs {
+		if doc.course.Deleted {
+			continue
+		}
+		live++
+		for field, terms := range doc.fields() {
+			seen := map[string]bool{}
+			for _, term := range terms {
+				if !seen[term] {
+					seen[term] = true
+					frequencies[fieldTerm{field, term}]++
+				}
+			}
+		}
+	}
+
+	// Términos representativos del origen: tf * idf, normalizados para que el mejor valga 1
+	weights := map[fieldTerm]float64{}
+	for field, terms := range source.fields() {
+		for _, term := range terms {
+			if len(term) >= similarMinWordLength {
+				weights[fieldTerm{field, term}]++
+			}
+		}
+	}
+	interesting := make([]fieldTerm, 0, len(weights))
+	for key, tf := range weights {
+		df := frequencies[key]
+		weights[key] = tf * math.Log(1+float64(live)/float64(df+1))
+		interesting = append(interesting, key)
+	}
+	sort.Slice(interesting, func(i, j int) bool {
+		if weights[interesting[i]] != weights[interesting[j]] {
+			return weights[interesting[i]] > weights[interesting[j]]
+		}
+		return interesting[i].term < interesting[j].term
+	})
+	if len(interesting) > similarMaxTerms {
+		interesting = interesting[:similarMaxTerms]
+	}
+	if len(interesting) == 0 {
+		return daoCourses.SearchResult{}, nil
+	}
+	top := weights[interesting[0]]
+	boosts := [3]float64{queryBoosts.name, queryBoosts.category, queryBoosts.description}
+
+	var hits []hit
+	for _, doc := range docs {
+		if doc == source || doc.course.Deleted || !doc.course.Available {
+			continue
+		}
+		fields := doc.fields()
+		score := 0.0
+		for _, key := range interesting {
+			if containsSequence(fields[key.field], []string{key.term}) {
+				score += boosts[key.field] * weights[key] / top
+			}
+		}
+		if score > 0 {
+			hits = append(hits, hit{doc: doc, score: score})
+		}
+	}
+	sortHits(hits, daoCourses.SortByScore)
+
+	var courses []daoCourses.Course
+	for i := offset; i < len(hits) && i < offset+limit; i++ {
+		course := hits[i].doc.course
+		course.Score = hits[i].score
+		courses = append(courses, course)
+	}
+	return daoCourses.SearchResult{
+		Courses:  courses,
+		NumFound: len(hits),
+		QTime:    int(time.Since(started).Milliseconds()),
+	}, nil
+}
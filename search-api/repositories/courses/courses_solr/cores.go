@@ -0,0 +1,279 @@
+package courses
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	daoCourses "search-api/dao/courses"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stevenferrer/solr-go"
+)
+
+// shadowCore is the core being rebuilt by a reindex. While it is set, every
+// write to the live core is repeated on it, so the updates that arrive during
+// the rebuild are not lost when the cores are swapped.
+type shadowCore struct {
+	mu     sync.RWMutex
+	name   string
+	writes int
+}
+
+// StartMirror starts repeating live writes on the given core
+func (searchEngine Solr) StartMirror(core string) {
+	searchEngine.shadow.mu.Lock()
+	defer searchEngine.shadow.mu.Unlock()
+	searchEngine.shadow.name = core
+	searchEngine.shadow.writes = 0
+}
+
+// StopMirror stops repeating live writes
+func (searchEngine Solr) StopMirror() {
+	searchEngine.shadow.mu.Lock()
+	defer searchEngine.shadow.mu.Unlock()
+	searchEngine.shadow.name = ""
+}
+
+// MirroredWrites returns how many documents were written to the mirror core since StartMirror
+func (searchEngine Solr) MirroredWrites() int {
+	searchEngine.shadow.mu.RLock()
+	defer searchEngine.shadow.mu.RUnlock()
+	return searchEngine.shadow.writes
+}
+
+// mirror writes a document already stored in the live core to the core being
+// rebuilt, if any. The read lock is held during the write so that StopMirror
+// waits for writes in flight.
+func (searchEngine Solr) mirror(ctx context.Context, doc map[string]interface{}) error {
+	searchEngine.shadow.mu.RLock()
+	core := searchEngine.shadow.name
+	if core == "" {
+		searchEngine.shadow.mu.RUnlock()
+		return nil
+	}
+	err := searchEngine.addDocuments(ctx, core, []map[string]interface{}{doc}, time.Second)
+	searchEngine.shadow.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("error mirroring document to core %s: %w", core, err)
+	}
+
+	searchEngine.shadow.mu.Lock()
+	searchEngine.shadow.writes++
+	searchEngine.shadow.mu.Unlock()
+	return nil
+}
+
+// coreAdmin runs a CoreAdmin API action
+func (searchEngine Solr) coreAdmin(ctx context.Context, action string, params url.Values) error {
+	params.Set("action", action)
+	params.Set("wt", "json")
+	if _, err := searchEngine.call(ctx, http.MethodGet, "admin/cores?"+params.Encode(), nil, nil); err != nil {
+		return fmt.Errorf("error running core action %s: %w", action, err)
+	}
+	return nil
+}
+
+// CreateCore creates an empty core with the courses configset
+func (searchEngine Solr) CreateCore(ctx context.Context, name string) error {
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("instanceDir", name)
+	params.Set("configSet", searchEngine.configSet)
+	return searchEngine.coreAdmin(ctx, "CREATE", params)
+}
+
+// SwapCores atomically exchanges the live core with the given one: from then
+// on, searches on the collection name are served by the other core
+func (searchEngine Solr) SwapCores(ctx context.Context, other string) error {
+	params := url.Values{}
+	params.Set("core", searchEngine.Collection)
+	params.Set("other", other)
+	return searchEngine.coreAdmin(ctx, "SWAP", params)
+}
+
+// UnloadCore removes a core and its index. The instance directory is kept,
+// since after a swap it may be the one holding the mounted configuration.
+func (searchEngine Solr) UnloadCore(ctx context.Context, name string) error {
+	params := url.Values{}
+	params.Set("core", name)
+	params.Set("deleteIndex", "true")
+	params.Set("deleteDataDir", "true")
+	return searchEngine.coreAdmin(ctx, "UNLOAD", params)
+}
+
+// AddBatch writes course documents (tombstones for the deleted ones) to a
+// core, letting Solr commit within the given time instead of committing after
+// every batch
+func (searchEngine Solr) AddBatch(ctx context.Context, core string, courses []daoCourses.Course, commitWithin time.Duration) error {
+	docs := make([]map[string]interface{}, 0, len(courses))
+	for _, course := range courses {
+		docs = append(docs, courseDocument(course))
+	}
+	return searchEngine.addDocuments(ctx, core, docs, commitWithin)
+}
+
+func (searchEngine Solr) addDocuments(ctx context.Context, core string, docs []map[string]interface{}, commitWithin time.Duration) error {
+	params := url.Values{}
+	params.Set("commitWithin", strconv.FormatInt(commitWithin.Milliseconds(), 10))
+	params.Set("wt", "json")
+	if _, err := searchEngine.call(ctx, http.MethodPost, core+"/update?"+params.Encode(), docs, nil); err != nil {
+		return fmt.Errorf("error adding documents to core %s: %w", core, err)
+	}
+	return nil
+}
+
+// CommitCore makes every pending write of a core visible
+func (searchEngine Solr) CommitCore(ctx context.Context, core string) error {
+	if err := searchEngine.Client.Commit(ctx, core); err != nil {
+		return fmt.Errorf("error committing core %s: %w", core, err)
+	}
+	return nil
+}
+
+// Versions returns the stored version (including tombstones) of the given
+// courses in a core. Courses that are not in the core are left out. It uses
+// the real-time get handler, which also sees the documents written with
+// commitWithin that are not committed yet: a search would miss them and the
+// reindex would overwrite newer versions written by events moments before.
+func (searchEngine Solr) Versions(ctx context.Context, core string, ids []int64) (map[int64]int64, error) {
+	versions := make(map[int64]int64, len(ids))
+	if len(ids) == 0 {
+		return versions, nil
+	}
+
+	params := url.Values{}
+	params.Set("ids", joinIDs(ids))
+	params.Set("fl", "id,version")
+
+	resp, err := searchEngine.queryCore(ctx, core, "get", params)
+	if err != nil {
+		return nil, fmt.Errorf("error getting versions in core %s: %w", core, err)
+	}
+	for _, doc := range resp.Response.Documents {
+		versions[getIntField(doc, "id")] = getIntField(doc, "version")
+	}
+	return versions, nil
+}
+
+// idsFilter builds a filter query matching the given course IDs
+func idsFilter(ids []int64) string {
+	return "{!terms f=id}" + joinIDs(ids)
+}
+
+// joinIDs lists course IDs separated by commas
+func joinIDs(ids []int64) string {
+	terms := make([]string, 0, len(ids))
+	for _, id := range ids {
+		terms = append(terms, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(terms, ",")
+}
+
+// Count returns the number of live (not deleted) courses in a core
+func (searchEngine Solr) Count(ctx context.Context, core string) (int, error) {
+	params := url.Values{}
+	params.Set("q", "*:*")
+	params.Set("fq", "-deleted:true")
+	params.Set("rows", "0")
+
+	resp, err := searchEngine.queryCore(ctx, core, "select", params)
+	if err != nil {
+		return 0, fmt.Errorf("error counting documents in core %s: %w", core, err)
+	}
+	return resp.Response.NumFound, nil
+}
+
+// storedFields are the fields needed to rebuild a course from its document
+const storedFields = "id,name,category,description,duration,instructor_id,capacity,available,rating,version,deleted"
+
+// Documents returns the documents (including tombstones) of the given courses in the live core
+func (searchEngine Solr) Documents(ctx context.Context, ids []int64) ([]daoCourses.Course, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	params := url.Values{}
+	params.Set("q", "*:*")
+	params.Set("fq", idsFilter(ids))
+	params.Set("fl", storedFields)
+	params.Set("rows", strconv.Itoa(len(ids)))
+
+	resp, err := searchEngine.query(ctx, "select", params)
+	if err != nil {
+		return nil, fmt.Errorf("error querying documents: %w", err)
+	}
+
+	courses := make([]daoCourses.Course, 0, len(resp.Response.Documents))
+	for _, doc := range resp.Response.Documents {
+		courses = append(courses, courseFromDocument(doc))
+	}
+	return courses, nil
+}
+
+// LiveIDs walks the IDs of every live (not deleted) course in the live core,
+// in pages sorted by the uniqueKey, using a cursor so that deep pages stay
+// cheap. The key is a string field, so the order is lexicographic.
+func (searchEngine Solr) LiveIDs(ctx context.Context, pageSize int, visit func(ids []int64) error) error {
+	err := searchEngine.walk(ctx, "-deleted:true", "id", pageSize, func(docs []solr.M) error {
+		ids := make([]int64, 0, len(docs))
+		for _, doc := range docs {
+			ids = append(ids, getIntField(doc, "id"))
+		}
+		return visit(ids)
+	})
+	if err != nil {
+		return fmt.Errorf("error walking live documents: %w", err)
+	}
+	return nil
+}
+
+// Tombstones walks the tombstones of the live core in pages, in the same
+// order as LiveIDs. A reindex copies them into the new core so that the
+// deletions keep rejecting the stale events that arrive after the swap.
+func (searchEngine Solr) Tombstones(ctx context.Context, pageSize int, visit func(tombstones []daoCourses.Course) error) error {
+	err := searchEngine.walk(ctx, "deleted:true", "id,version,deleted", pageSize, func(docs []solr.M) error {
+		tombstones := make([]daoCourses.Course, 0, len(docs))
+		for _, doc := range docs {
+			tombstones = append(tombstones, courseFromDocument(doc))
+		}
+		return visit(tombstones)
+	})
+	if err != nil {
+		return fmt.Errorf("error walking tombstones: %w", err)
+	}
+	return nil
+}
+
+// walk visits the documents of the live core matching a filter, in pages
+// sorted by the uniqueKey and read with a cursor
+func (searchEngine Solr) walk(ctx context.Context, filter string, fields string, pageSize int, visit func(docs []solr.M) error) error {
+	cursor := "*"
+	for {
+		params := url.Values{}
+		params.Set("q", "*:*")
+		params.Set("fq", filter)
+		params.Set("fl", fields)
+		params.Set("sort", "id asc")
+		params.Set("rows", strconv.Itoa(pageSize))
+		params.Set("cursorMark", cursor)
+
+		resp, err := searchEngine.query(ctx, "select", params)
+		if err != nil {
+			return err
+		}
+		if len(resp.Response.Documents) > 0 {
+			if err := visit(resp.Response.Documents); err != nil {
+				return err
+			}
+		}
+
+		if resp.NextCursorMark == "" || resp.NextCursorMark == cursor {
+			return nil
+		}
+		cursor = resp.NextCursorMark
+	}
+}
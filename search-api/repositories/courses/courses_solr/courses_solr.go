@@ -6,8 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	daoCourses "search-api/dao/courses"
+	domainCourses "search-api/domain/courses"
 	"strconv" // Asegúrate de que esta línea esté presente
+	"strings"
+	"time"
 
 	"github.com/stevenferrer/solr-go"
 )
@@ -16,11 +21,40 @@ type SolrConfig struct {
 	Host       string // Solr host
 	Port       string // Solr port
 	Collection string // Solr collection name
+
+	// MinimumShouldMatch overrides the "mm" default of the /search handler (e.g. "2<-1 5<75%")
+	MinimumShouldMatch string
+
+	// HighlightPre and HighlightPost surround matched terms in highlighted fields (default <em> and </em>)
+	HighlightPre  string
+	HighlightPost string
+
+	// SpellcheckMaxHits is the hit count up to which "did you mean" collations are returned (default 3)
+	SpellcheckMaxHits int
+
+	// ConfigSet is the Solr configset used to create the cores of a reindex (default "courses")
+	ConfigSet string
 }
 
+const (
+	defaultHighlightPre      = "<em>"
+	defaultHighlightPost     = "</em>"
+	defaultSpellcheckMaxHits = 3
+	defaultConfigSet         = "courses"
+)
+
 type Solr struct {
 	Client     *solr.JSONClient
 	Collection string
+
+	baseURL            string
+	httpClient         *http.Client
+	minimumShouldMatch string
+	highlightPre       string
+	highlightPost      string
+	spellcheckMaxHits  int
+	configSet          string
+	shadow             *shadowCore // Core being rebuilt, shared by all copies of the client
 }
 
 // NewSolr initializes a new Solr client
@@ -28,21 +62,37 @@ func NewSolr(config SolrConfig) Solr {
 	baseURL := fmt.Sprintf("http://%s:%s", config.Host, config.Port)
 	client := solr.NewJSONClient(baseURL)
 
+	if config.HighlightPre == "" {
+		config.HighlightPre = defaultHighlightPre
+	}
+	if config.HighlightPost == "" {
+		config.HighlightPost = defaultHighlightPost
+	}
+	if config.SpellcheckMaxHits <= 0 {
+		config.SpellcheckMaxHits = defaultSpellcheckMaxHits
+	}
+	if config.ConfigSet == "" {
+		config.ConfigSet = defaultConfigSet
+	}
+
 	return Solr{
-		Client:     client,
-		Collection: config.Collection,
+		Client:             client,
+		Collection:         config.Collection,
+		baseURL:            baseURL,
+		httpClient:         &http.Client{Timeout: 10 * time.Second},
+		minimumShouldMatch: config.MinimumShouldMatch,
+		highlightPre:       config.HighlightPre,
+		highlightPost:      config.HighlightPost,
+		spellcheckMaxHits:  config.SpellcheckMaxHits,
+		configSet:          config.ConfigSet,
+		shadow:             &shadowCore{},
 	}
 }
 
 // Index adds a new course document to the Solr collection
 func (searchEngine Solr) Index(ctx context.Context, course daoCourses.Course) (string, error) {
 	// Prepare the document for SolR
-	doc := map[string]interface{}{
-		"id":          course.ID,
-		"name":        course.Name,
-		"category":    course.Category,
-		"description": course.Description,
-	}
+	doc := courseDocument(course)
 
 	// Log the course ID being indexed
 	log.Printf("Indexando curso con ID: %d", course.ID)
@@ -76,17 +126,16 @@ func (searchEngine Solr) Index(ctx context.Context, course daoCourses.Course) (s
 		return "", fmt.Errorf("error committing changes to SolR: %w", err)
 	}
 
+	if err := searchEngine.mirror(ctx, doc); err != nil {
+		return "", err
+	}
+
 	return fmt.Sprintf("%d", course.ID), nil // Convert CourseID to string
 }
 
 // Update modifies an existing course document in the Solr collection
 func (searchEngine Solr) Update(ctx context.Context, course daoCourses.Course) error {
-	doc := map[string]interface{}{
-		"id":          course.ID,
-		"name":        course.Name,
-		"category":    course.Category,
-		"description": course.Description,
-	}
+	doc := courseDocument(course)
 
 	log.Printf("Actualizando curso con ID: %d", course.ID)
 	log.Printf("Documento a actualizar: %+v", doc)
@@ -112,18 +161,22 @@ func (searchEngine Solr) Update(ctx context.Context, course daoCourses.Course) e
 		return fmt.Errorf("error committing changes to SolR: %w", err)
 	}
 
-	return nil
+	return searchEngine.mirror(ctx, doc)
 }
 
-// Delete removes a course document from the Solr collection
-func (searchEngine Solr) Delete(ctx context.Context, id string) error {
-	docToDelete := map[string]interface{}{
-		"delete": map[string]interface{}{
-			"id": id,
-		},
+// Delete replaces a course document with a tombstone that keeps the version of
+// the deletion, so that stale updates arriving later cannot bring it back
+func (searchEngine Solr) Delete(ctx context.Context, id string, version int64) error {
+	doc := map[string]interface{}{
+		"id":      id,
+		"version": version,
+		"deleted": true,
+	}
+	tombstone := map[string]interface{}{
+		"add": []interface{}{doc},
 	}
 
-	body, err := json.Marshal(docToDelete)
+	body, err := json.Marshal(tombstone)
 	if err != nil {
 		return fmt.Errorf("error marshaling course document: %w", err)
 	}
@@ -140,40 +193,291 @@ func (searchEngine Solr) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("error committing changes to SolR: %w", err)
 	}
 
-	return nil
+	return searchEngine.mirror(ctx, doc)
 }
 
-// Search searches for courses in the Solr collection based on a query
-func (searchEngine Solr) Search(ctx context.Context, query string, limit int, offset int) ([]daoCourses.Course, error) {
-	if query == "" {
-		return nil, fmt.Errorf("la consulta no puede estar vacía")
+// Version returns the source version stored for a course (including tombstones), or 0 if it was never indexed
+func (searchEngine Solr) Version(ctx context.Context, id string) (int64, error) {
+	query := solr.NewQuery(fmt.Sprintf("id:%s", id)).Fields("id", "version").Limit(1)
+
+	resp, err := searchEngine.Client.Query(ctx, searchEngine.Collection, query)
+	if err != nil {
+		return 0, fmt.Errorf("error querying course version: %w", err)
+	}
+	if resp.Error != nil {
+		return 0, fmt.Errorf("failed to query course version: %v", resp.Error)
+	}
+	if len(resp.Response.Documents) == 0 {
+		return 0, nil
 	}
 
-	solrQuery := fmt.Sprintf("q=(name:*%s* OR description:*%s*)&rows=%d&start=%d&wt=json", query, query, limit, offset)
+	return getIntField(resp.Response.Documents[0], "version"), nil
+}
 
-	log.Printf("Consulta a Solr: %s", solrQuery)
+// Rating range facet: one bucket per star, the last one including 5
+const (
+	ratingFacetStart = 0
+	ratingFacetEnd   = 5
+	ratingFacetGap   = 1
+)
 
-	resp, err := searchEngine.Client.Query(ctx, searchEngine.Collection, solr.NewQuery(solrQuery))
-	if err != nil {
-		return nil, fmt.Errorf("error ejecutando la consulta de búsqueda: %w", err)
+// Search searches for courses through the edismax /search handler (see
+// solrconfig.xml for the field boosts). The user text is escaped, so it can only
+// match terms or quoted phrases, and results are ordered by score.
+//
+// When the query gets at most spellcheckMaxHits results, the spellcheck
+// component of the handler proposes collations, already checked against the
+// index with the same filters. Matches in name and description are highlighted
+// with the configured markers; the rest of the text is HTML-escaped.
+//
+// Filters are sent as tagged filter queries, so they don't affect scoring and
+// each facet ignores its own filter (multi-select faceting): selecting a
+// category still returns the counts of the other categories.
+func (searchEngine Solr) Search(ctx context.Context, query string, filters daoCourses.SearchFilters, sort daoCourses.SearchSort, offset int, limit int) (daoCourses.SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return daoCourses.SearchResult{}, fmt.Errorf("la consulta no puede estar vacía")
 	}
-	log.Printf("Respuesta de Solr: %+v", resp) // Log de la respuesta de Solr
-	if resp.Error != nil {
-		return nil, fmt.Errorf("error al ejecutar la consulta de búsqueda: %v, consulta: %s", resp.Error, solrQuery)
+
+	params := url.Values{}
+	params.Set("q", escapeQuery(query))
+	params.Set("start", strconv.Itoa(offset))
+	params.Set("rows", strconv.Itoa(limit))
+	params.Set("fl", "id,name,category,description,duration,instructor_id,capacity,available,rating,version,score")
+	params.Set("sort", sortClause(sort))
+	params.Add("fq", "-deleted:true") // Exclude tombstones left by deleted courses
+	for _, filter := range filterQueries(filters) {
+		params.Add("fq", filter)
+	}
+	if searchEngine.minimumShouldMatch != "" {
+		params.Set("mm", searchEngine.minimumShouldMatch)
+	}
+
+	params.Set("facet", "true")
+	params.Set("facet.mincount", "1")
+	params.Add("facet.field", "{!ex=category}category_facet")
+	params.Add("facet.field", "{!ex=available}available")
+	params.Set("facet.range", "{!ex=rating}rating")
+	params.Set("f.rating.facet.range.start", strconv.Itoa(ratingFacetStart))
+	params.Set("f.rating.facet.range.end", strconv.Itoa(ratingFacetEnd))
+	params.Set("f.rating.facet.range.gap", strconv.Itoa(ratingFacetGap))
+	params.Add("f.rating.facet.range.include", "lower")
+	params.Add("f.rating.facet.range.include", "edge")
+	params.Set("f.rating.facet.mincount", "0") // Empty rating buckets are still listed
+
+	params.Set("spellcheck", "true")
+	params.Set("spellcheck.maxResultsForSuggest", strconv.Itoa(searchEngine.spellcheckMaxHits))
+
+	params.Set("hl", "true")
+	params.Set("hl.method", "unified")
+	params.Set("hl.fl", "name,description")
+	params.Set("hl.tag.pre", searchEngine.highlightPre)
+	params.Set("hl.tag.post", searchEngine.highlightPost)
+	params.Set("hl.encoder", "html")
+	params.Set("hl.snippets", "1")
+	params.Set("f.name.hl.fragsize", "0") // Whole name
+	params.Set("f.description.hl.fragsize", "160")
+
+	log.Printf("Consulta a Solr: %s", params.Encode())
+
+	resp, err := searchEngine.query(ctx, "search", params)
+	if err != nil {
+		return daoCourses.SearchResult{}, fmt.Errorf("error ejecutando la consulta de búsqueda: %w", err)
 	}
 
 	var coursesList []daoCourses.Course
 	for _, doc := range resp.Response.Documents {
-		course := daoCourses.Course{
-			ID:          getIntField(doc, "id"),
-			Name:        getStringField(doc, "name"),
-			Category:    getStringField(doc, "category"),
-			Description: getStringField(doc, "description"),
+		course := courseFromDocument(doc)
+		highlights := resp.Highlighting[strconv.FormatInt(course.ID, 10)]
+		if snippets := highlights["name"]; len(snippets) > 0 {
+			course.HighlightedName = snippets[0]
+		}
+		if snippets := highlights["description"]; len(snippets) > 0 {
+			course.HighlightedDescription = snippets[0]
 		}
 		coursesList = append(coursesList, course)
 	}
 
-	return coursesList, nil
+	qTime := 0
+	if resp.BaseResponse != nil && resp.Header != nil {
+		qTime = resp.Header.QTime
+	}
+
+	return daoCourses.SearchResult{
+		Courses:  coursesList,
+		NumFound: resp.Response.NumFound,
+		QTime:    qTime,
+		Facets: daoCourses.Facets{
+			Categories:   pairs(resp.FacetCounts.FacetFields["category_facet"]),
+			Ratings:      ratingRanges(pairs(resp.FacetCounts.FacetRanges["rating"].Counts)),
+			Availability: pairs(resp.FacetCounts.FacetFields["available"]),
+		},
+		Collations: collations(resp.Spellcheck.Collations),
+	}, nil
+}
+
+// Suggest returns available courses whose name or category words start with
+// the typed terms (all of them must match). It queries the edge n-gram fields,
+// which are filled on every index update, so suggestions follow the same
+// RabbitMQ path as search results.
+func (searchEngine Solr) Suggest(ctx context.Context, prefix string, limit int) ([]daoCourses.Course, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return nil, fmt.Errorf("el prefijo no puede estar vacío")
+	}
+
+	params := url.Values{}
+	params.Set("defType", "edismax")
+	params.Set("q", escapeQuery(prefix))
+	params.Set("qf", "name_suggest^3 category_suggest")
+	params.Set("uf", "-*")
+	params.Set("mm", "100%")
+	params.Set("rows", strconv.Itoa(limit))
+	params.Set("fl", "id,name,category")
+	params.Set("sort", "score desc,name_sort asc,id asc")
+	params.Add("fq", "-deleted:true")
+	params.Add("fq", "available:true")
+
+	resp, err := searchEngine.query(ctx, "select", params)
+	if err != nil {
+		return nil, fmt.Errorf("error ejecutando la consulta de sugerencias: %w", err)
+	}
+
+	suggestions := make([]daoCourses.Course, 0, len(resp.Response.Documents))
+	for _, doc := range resp.Response.Documents {
+		suggestions = append(suggestions, courseFromDocument(doc))
+	}
+	return suggestions, nil
+}
+
+// Similar returns the available courses most alike a given one in name,
+// category and description (MoreLikeThis), excluding the course itself. A
+// deleted course has no text left, so it has no similar courses.
+func (searchEngine Solr) Similar(ctx context.Context, id int64, offset int, limit int) (daoCourses.SearchResult, error) {
+	source, err := searchEngine.Documents(ctx, []int64{id})
+	if err != nil {
+		return daoCourses.SearchResult{}, err
+	}
+	if len(source) == 0 {
+		return daoCourses.SearchResult{}, fmt.Errorf("%w: %d", domainCourses.ErrCourseNotFound, id)
+	}
+	if source[0].Deleted {
+		return daoCourses.SearchResult{}, nil
+	}
+
+	params := url.Values{}
+	params.Set("q", "id:"+strconv.FormatInt(id, 10))
+	params.Set("start", strconv.Itoa(offset))
+	params.Set("rows", strconv.Itoa(limit))
+	params.Set("sort", "score desc,id asc")
+	params.Add("fq", "-deleted:true")
+	params.Add("fq", "available:true")
+	params.Add("fq", "-id:"+strconv.FormatInt(id, 10))
+
+	resp, err := searchEngine.query(ctx, "mlt", params)
+	if err != nil {
+		return daoCourses.SearchResult{}, fmt.Errorf("error querying courses similar to %d: %w", id, err)
+	}
+
+	courses := make([]daoCourses.Course, 0, len(resp.Response.Documents))
+	for _, doc := range resp.Response.Documents {
+		courses = append(courses, courseFromDocument(doc))
+	}
+
+	qTime := 0
+	if resp.BaseResponse != nil && resp.Header != nil {
+		qTime = resp.Header.QTime
+	}
+	return daoCourses.SearchResult{
+		Courses:  courses,
+		NumFound: resp.Response.NumFound,
+		QTime:    qTime,
+	}, nil
+}
+
+// sortClause returns the Solr sort for a search order. Ties are always broken
+// by id so that paging is stable.
+func sortClause(sort daoCourses.SearchSort) string {
+	switch sort {
+	case daoCourses.SortByRating:
+		return "rating desc,score desc,id asc"
+	case daoCourses.SortByName:
+		return "name_sort asc,id asc"
+	default:
+		return "score desc,id asc"
+	}
+}
+
+// filterQueries translates the search filters into Solr fq params. Each one is
+// tagged so its facet can exclude it.
+func filterQueries(filters daoCourses.SearchFilters) []string {
+	var fq []string
+	if filters.Category != "" {
+		// The term parser takes the value literally, no escaping needed
+		fq = append(fq, "{!term f=category_facet tag=category}"+filters.Category)
+	}
+	if filters.MinRating != nil {
+		fq = append(fq, "{!tag=rating}rating:["+strconv.FormatFloat(*filters.MinRating, 'f', -1, 64)+" TO *]")
+	}
+	if filters.Available != nil {
+		fq = append(fq, "{!tag=available}available:"+strconv.FormatBool(*filters.Available))
+	}
+	if filters.InstructorID != 0 {
+		fq = append(fq, "instructor_id:"+strconv.FormatInt(filters.InstructorID, 10))
+	}
+	return fq
+}
+
+// ratingRanges labels the range facet buckets ("1.0" -> "1-2")
+func ratingRanges(buckets []daoCourses.FacetCount) []daoCourses.FacetCount {
+	for i, bucket := range buckets {
+		lower, err := strconv.ParseFloat(bucket.Value, 64)
+		if err != nil {
+			continue
+		}
+		buckets[i].Value = fmt.Sprintf("%g-%g", lower, lower+ratingFacetGap)
+	}
+	return buckets
+}
+
+// courseFromDocument maps a stored Solr document back to a course
+func courseFromDocument(doc map[string]interface{}) daoCourses.Course {
+	return daoCourses.Course{
+		ID:           getIntField(doc, "id"),
+		Name:         getStringField(doc, "name"),
+		Category:     getStringField(doc, "category"),
+		Description:  getStringField(doc, "description"),
+		Duration:     getStringField(doc, "duration"),
+		InstructorID: getIntField(doc, "instructor_id"),
+		Capacity:     int(getIntField(doc, "capacity")),
+		Available:    getBoolField(doc, "available"),
+		Rating:       getFloatField(doc, "rating"),
+		Version:      getIntField(doc, "version"),
+		Deleted:      getBoolField(doc, "deleted"),
+		Score:        getFloatField(doc, "score"),
+	}
+}
+
+// courseDocument builds the Solr document for a course, or its tombstone if it was deleted
+func courseDocument(course daoCourses.Course) map[string]interface{} {
+	if course.Deleted {
+		return map[string]interface{}{
+			"id":      course.ID,
+			"version": course.Version,
+			"deleted": true,
+		}
+	}
+	return map[string]interface{}{
+		"id":            course.ID,
+		"name":          course.Name,
+		"category":      course.Category,
+		"description":   course.Description,
+		"duration":      course.Duration,
+		"instructor_id": course.InstructorID,
+		"capacity":      course.Capacity,
+		"available":     course.Available,
+		"rating":        course.Rating,
+		"version":       course.Version,
+		"deleted":       false,
+	}
 }
 
 // Helper function to safely get string fields from the document
@@ -189,6 +493,20 @@ func getStringField(doc map[string]interface{}, field string) string {
 	return ""
 }
 
+// Helper function to safely get float64 fields from the document
+func getFloatField(doc map[string]interface{}, field string) float64 {
+	if val, ok := doc[field].(float64); ok {
+		return val
+	}
+	return 0
+}
+
+// Helper function to safely get bool fields from the document
+func getBoolField(doc map[string]interface{}, field string) bool {
+	val, _ := doc[field].(bool)
+	return val
+}
+
 // Helper function to safely get int64 fields from the document
 func getIntField(doc map[string]interface{}, field string) int64 {
 	log.Printf("Document fields: %+v", doc)    // Log para ver todos los campos del documento
@@ -213,20 +531,3 @@ func getIntField(doc map[string]interface{}, field string) int64 {
 	log.Printf("Campo '%s' no encontrado o no es un float64 ni int64", field) // Ahora incluye el nombre del campo
 	return 0
 }
-
-// IndexAllCourses indexa todos los cursos en Solr
-func (searchEngine Solr) IndexAllCourses(ctx context.Context, courses []daoCourses.Course) error {
-	for _, course := range courses {
-
-		courseUpdate := daoCourses.Course{
-			ID:          course.ID, // Asignar el ID convertido
-			Name:        course.Name,
-			Category:    course.Category,
-			Description: course.Description,
-		}
-		if _, err := searchEngine.Index(ctx, courseUpdate); err != nil {
-			return fmt.Errorf("error indexing course %s: %w", course.Name, err)
-		}
-	}
-	return nil
-}
@@ -0,0 +1,215 @@
+package courses
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	daoCourses "search-api/dao/courses"
+	"strings"
+
+	"github.com/stevenferrer/solr-go"
+)
+
+// luceneSpecialChars are the characters with meaning in the Lucene/edismax query syntax
+const luceneSpecialChars = `\+-&|!(){}[]^"~*?:/`
+
+// escapeQuery turns free user text into a safe edismax query. Every token is
+// escaped so it is matched literally, except for text between double quotes,
+// which is kept as a phrase query.
+func escapeQuery(input string) string {
+	var (
+		parts   []string
+		current strings.Builder
+		phrase  bool
+	)
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		if phrase {
+			parts = append(parts, `"`+current.String()+`"`)
+		} else {
+			parts = append(parts, current.String())
+		}
+		current.Reset()
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"':
+			flush()
+			phrase = !phrase
+		case !phrase && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		case phrase && r == '\\':
+			current.WriteString(`\\`)
+		case !phrase && strings.ContainsRune(luceneSpecialChars, r):
+			current.WriteRune('\\')
+			current.WriteRune(r)
+		default:
+			current.WriteRune(r)
+		}
+	}
+	// An unbalanced quote still produces a phrase with the remaining text
+	flush()
+
+	// Bare boolean operators would otherwise be parsed as syntax
+	for i, part := range parts {
+		switch part {
+		case "AND", "OR", "NOT":
+			parts[i] = strings.ToLower(part)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// queryResponse is a Solr select response, including the facet section that
+// solr.QueryResponse does not decode
+type queryResponse struct {
+	*solr.BaseResponse
+	Response     solr.QueryResponseBody         `json:"response"`
+	FacetCounts  facetCounts                    `json:"facet_counts"`
+	Highlighting map[string]map[string][]string `json:"highlighting"`
+	Spellcheck   struct {
+		Collations []interface{} `json:"collations"`
+	} `json:"spellcheck"`
+	NextCursorMark string `json:"nextCursorMark"`
+}
+
+// facetCounts holds field and range facets as Solr returns them: flat lists
+// alternating value and count
+type facetCounts struct {
+	FacetFields map[string][]interface{} `json:"facet_fields"`
+	FacetRanges map[string]struct {
+		Counts []interface{} `json:"counts"`
+	} `json:"facet_ranges"`
+}
+
+// query sends a GET request to a Solr request handler of the live core with URL-encoded params
+func (searchEngine Solr) query(ctx context.Context, handler string, params url.Values) (*queryResponse, error) {
+	return searchEngine.queryCore(ctx, searchEngine.Collection, handler, params)
+}
+
+// queryCore sends a GET request to a Solr request handler of the given core
+func (searchEngine Solr) queryCore(ctx context.Context, core string, handler string, params url.Values) (*queryResponse, error) {
+	params.Set("wt", "json")
+	urlStr := fmt.Sprintf("%s/solr/%s/%s?%s", searchEngine.baseURL, core, handler, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building Solr request: %w", err)
+	}
+
+	resp, err := searchEngine.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending Solr request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result queryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding Solr response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.BaseResponse != nil && result.Error != nil {
+		return nil, fmt.Errorf("solr error: %v", result.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("solr returned status code %d", resp.StatusCode)
+	}
+
+	return &result, nil
+}
+
+// call sends a request to a Solr API path (relative to /solr) with an optional
+// JSON body, decodes the JSON answer into out (if not nil) and returns the
+// status code. Non-2xx answers are returned as errors together with the status.
+func (searchEngine Solr) call(ctx context.Context, method string, path string, body interface{}, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("error marshaling Solr request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, searchEngine.baseURL+"/solr/"+path, reader)
+	if err != nil {
+		return 0, fmt.Errorf("error building Solr request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := searchEngine.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending Solr request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, fmt.Errorf("solr returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("error decoding Solr response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// collations reads the spellcheck collations. With extended results Solr returns
+// a flat list alternating the "collation" key and an object with the query and
+// its hits; without them, the values are plain query strings.
+func collations(flat []interface{}) []daoCourses.Collation {
+	var result []daoCourses.Collation
+	for _, item := range flat {
+		switch value := item.(type) {
+		case map[string]interface{}:
+			query, _ := value["collationQuery"].(string)
+			hits, _ := value["hits"].(float64)
+			if query != "" {
+				result = append(result, daoCourses.Collation{Query: unescapeQuery(query), Hits: int(hits)})
+			}
+		case string:
+			if value != "collation" {
+				result = append(result, daoCourses.Collation{Query: unescapeQuery(value)})
+			}
+		}
+	}
+	return result
+}
+
+// unescapeQuery removes the escaping added by escapeQuery, so a collation can
+// be shown to the user and sent back as a new search
+func unescapeQuery(query string) string {
+	var unescaped strings.Builder
+	escaped := false
+	for _, r := range query {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		unescaped.WriteRune(r)
+	}
+	return unescaped.String()
+}
+
+// pairs converts a flat facet list ["a", 3, "b", 1] into value/count pairs
+func pairs(flat []interface{}) []daoCourses.FacetCount {
+	counts := make([]daoCourses.FacetCount, 0, len(flat)/2)
+	for i := 0; i+1 < len(flat); i += 2 {
+		value := fmt.Sprint(flat[i])
+		count, _ := flat[i+1].(float64)
+		counts = append(counts, daoCourses.FacetCount{Value: value, Count: int(count)})
+	}
+	return counts
+}
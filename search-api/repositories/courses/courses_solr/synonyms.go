@@ -0,0 +1,101 @@
+package courses
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	domainCourses "search-api/domain/courses"
+	"strings"
+)
+
+// synonymsResource is the managed resource used by the text_es query analyzer
+const synonymsResource = "spanish"
+
+// foldTerm normalizes a synonym the same way the text_es analyzer does before
+// the synonym filter (lowercase and ASCII folding), so that stored mappings
+// match what users type with or without accents
+var foldTerm = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+)
+
+func normalizeTerm(term string) string {
+	return strings.Join(strings.Fields(foldTerm.Replace(strings.ToLower(term))), " ")
+}
+
+func (searchEngine Solr) synonymsPath(term string) string {
+	path := fmt.Sprintf("%s/schema/analysis/synonyms/%s", searchEngine.Collection, synonymsResource)
+	if term != "" {
+		path += "/" + url.PathEscape(term)
+	}
+	return path
+}
+
+// Synonyms returns the current synonym mappings (term -> synonyms)
+func (searchEngine Solr) Synonyms(ctx context.Context) (map[string][]string, error) {
+	var resp struct {
+		SynonymMappings struct {
+			ManagedMap map[string][]string `json:"managedMap"`
+		} `json:"synonymMappings"`
+	}
+	if _, err := searchEngine.call(ctx, http.MethodGet, searchEngine.synonymsPath(""), nil, &resp); err != nil {
+		return nil, fmt.Errorf("error reading synonyms: %w", err)
+	}
+	if resp.SynonymMappings.ManagedMap == nil {
+		return map[string][]string{}, nil
+	}
+	return resp.SynonymMappings.ManagedMap, nil
+}
+
+// AddSynonyms stores a set of equivalent terms (each one maps to all the
+// others) and reloads the core so that queries start using them. Returns the
+// terms as they were stored.
+func (searchEngine Solr) AddSynonyms(ctx context.Context, terms []string) ([]string, error) {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, term := range terms {
+		term = normalizeTerm(term)
+		if term != "" && !seen[term] {
+			seen[term] = true
+			normalized = append(normalized, term)
+		}
+	}
+	if len(normalized) < 2 {
+		return nil, fmt.Errorf("%w: se necesitan al menos dos términos distintos", domainCourses.ErrInvalidSynonyms)
+	}
+
+	// A JSON list is stored by Solr as a symmetric mapping
+	if _, err := searchEngine.call(ctx, http.MethodPut, searchEngine.synonymsPath(""), normalized, nil); err != nil {
+		return nil, fmt.Errorf("error storing synonyms: %w", err)
+	}
+	if err := searchEngine.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// DeleteSynonym removes the mapping of a term and reloads the core
+func (searchEngine Solr) DeleteSynonym(ctx context.Context, term string) error {
+	status, err := searchEngine.call(ctx, http.MethodDelete, searchEngine.synonymsPath(normalizeTerm(term)), nil, nil)
+	if status == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", domainCourses.ErrSynonymNotFound, term)
+	}
+	if err != nil {
+		return fmt.Errorf("error deleting synonym: %w", err)
+	}
+	return searchEngine.Reload(ctx)
+}
+
+// Reload reloads the core. Solr opens the new core (with the updated managed
+// resources) while the current one keeps serving requests, and swaps them once
+// it is ready, so searches are not interrupted.
+func (searchEngine Solr) Reload(ctx context.Context) error {
+	params := url.Values{}
+	params.Set("action", "RELOAD")
+	params.Set("core", searchEngine.Collection)
+	params.Set("wt", "json")
+	if _, err := searchEngine.call(ctx, http.MethodGet, "admin/cores?"+params.Encode(), nil, nil); err != nil {
+		return fmt.Errorf("error reloading core %s: %w", searchEngine.Collection, err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package analytics
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"log"
+	dao "search-api/dao/analytics"
+	domain "search-api/domain/analytics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repository define dónde se guardan y agregan las búsquedas y los clics
+type Repository interface {
+	SaveSearches(ctx context.Context, events []dao.SearchEvent) error
+	SaveClick(ctx context.Context, click dao.SearchClick) error
+	Totals(ctx context.Context, since time.Time) (dao.Totals, error)
+	TopQueries(ctx context.Context, since time.Time, limit int) ([]dao.QueryStat, error)
+	ZeroResultQueries(ctx context.Context, since time.Time, limit int) ([]dao.QueryStat, error)
+}
+
+// Valores por defecto del registro de búsquedas
+const (
+	defaultBufferSize    = 1000
+	defaultBatchSize     = 100
+	defaultFlushInterval = 2 * time.Second
+	maxQueryLength       = 255
+	queryIDLength        = 32 // Parte aleatoria y firma, en hexadecimal
+	queryNonceLength     = 16
+	userHashLength       = 16
+)
+
+// metrics expone en /debug/vars cuántas búsquedas se registran y cuántas se pierden
+var metrics = expvar.NewMap("search_analytics")
+
+// Config configura el registro de búsquedas
+type Config struct {
+	Salt          string        // Clave para anonimizar a los usuarios
+	BufferSize    int           // Búsquedas pendientes de guardar; las que no entran se descartan
+	BatchSize     int           // Búsquedas por escritura
+	FlushInterval time.Duration // Tiempo máximo que una búsqueda espera en el buffer
+}
+
+// Service registra las búsquedas y los clics y arma los informes. Las
+// búsquedas se guardan por lotes en segundo plano, para no demorar las
+// respuestas; si el buffer se llena, se descartan en vez de bloquear.
+type Service struct {
+	repository Repository
+	config     Config
+
+	pending chan dao.SearchEvent
+	done    chan struct{}
+	stopped sync.WaitGroup
+	once    sync.Once
+}
+
+// NewService crea el servicio y lanza la escritura en segundo plano
+func NewService(repository Repository, config Config) *Service {
+	if config.Salt == "" {
+		log.Println("ANALYTICS_SALT no configurada: los usuarios anonimizados cambiarán en cada reinicio")
+		config.Salt = randomHex(32)
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultBufferSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+
+	service := &Service{
+		repository: repository,
+		config:     config,
+		pending:    make(chan dao.SearchEvent, config.BufferSize),
+		done:       make(chan struct{}),
+	}
+	service.stopped.Add(1)
+	go service.writer()
+	return service
+}
+
+// RecordSearch encola una búsqueda y devuelve el ID con el que se informan sus
+// clics. El ID va firmado junto con la consulta, así las páginas siguientes
+// pueden verificarlo sin esperar a que la búsqueda se guarde.
+func (service *Service) RecordSearch(event domain.SearchEvent) string {
+	query := normalizeQuery(event.Query)
+	queryID := service.signQueryID(randomHex(queryNonceLength/2), query)
+	record := dao.SearchEvent{
+		QueryID:   queryID,
+		Query:     query,
+		UserHash:  service.anonymize(event.User),
+		Sort:      event.Sort,
+		Filtered:  event.Filtered,
+		Results:   event.Results,
+		LatencyMs: int(event.Latency.Milliseconds()),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	select {
+	case service.pending <- record:
+	default:
+		metrics.Add("dropped", 1)
+	}
+	return queryID
+}
+
+// VerifyQueryID indica si el ID fue devuelto por RecordSearch para la consulta dada
+func (service *Service) VerifyQueryID(queryID string, query string) bool {
+	if !validQueryID(queryID) {
+		return false
+	}
+	expected := service.signQueryID(queryID[:queryNonceLength], normalizeQuery(query))
+	return hmac.Equal([]byte(queryID), []byte(expected))
+}
+
+// RecordClick guarda el resultado elegido en una búsqueda
+func (service *Service) RecordClick(ctx context.Context, click domain.Click, user string) error {
+	if !validQueryID(click.QueryID) {
+		return fmt.Errorf("%w: query_id %q", domain.ErrInvalidClick, click.QueryID)
+	}
+	err := service.repository.SaveClick(ctx, dao.SearchClick{
+		QueryID:   click.QueryID,
+		CourseID:  click.CourseID,
+		Position:  click.Position,
+		UserHash:  service.anonymize(user),
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("error al registrar el clic: %w", err)
+	}
+	metrics.Add("clicks", 1)
+	return nil
+}
+
+// Report arma el informe de las búsquedas de los últimos días
+func (service *Service) Report(ctx context.Context, days int, limit int) (domain.Report, error) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -days)
+
+	totals, err := service.repository.Totals(ctx, from)
+	if err != nil {
+		return domain.Report{}, fmt.Errorf("error al obtener los totales de búsqueda: %w", err)
+	}
+	top, err := service.repository.TopQueries(ctx, from, limit)
+	if err != nil {
+		return domain.Report{}, fmt.Errorf("error al obtener las consultas más buscadas: %w", err)
+	}
+	zero, err := service.repository.ZeroResultQueries(ctx, from, limit)
+	if err != nil {
+		return domain.Report{}, fmt.Errorf("error al obtener las consultas sin resultados: %w", err)
+	}
+
+	return domain.Report{
+		From:              from,
+		To:                to,
+		Searches:          totals.Searches,
+		Users:             totals.Users,
+		ZeroResults:       totals.ZeroResults,
+		ZeroResultRate:    ratio(totals.ZeroResults, totals.Searches),
+		ClickedSearches:   totals.ClickedSearches,
+		CTR:               ratio(totals.ClickedSearches, totals.Searches),
+		AvgLatencyMs:      totals.AvgLatencyMs,
+		TopQueries:        queryStats(top),
+		ZeroResultQueries: queryStats(zero),
+	}, nil
+}
+
+// Close guarda las búsquedas pendientes y detiene la escritura
+func (service *Service) Close() {
+	service.once.Do(func() { close(service.done) })
+	service.stopped.Wait()
+}
+
+// writer guarda las búsquedas encoladas por lotes, al llenarse un lote o cada FlushInterval
+func (service *Service) writer() {
+	defer service.stopped.Done()
+	ticker := time.NewTicker(service.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]dao.SearchEvent, 0, service.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := service.repository.SaveSearches(ctx, batch); err != nil {
+			log.Printf("Error al guardar %d búsquedas: %v", len(batch), err)
+			metrics.Add("failed", int64(len(batch)))
+		} else {
+			metrics.Add("recorded", int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event := <-service.pending:
+			batch = append(batch, event)
+			if len(batch) >= service.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-service.done:
+			for {
+				select {
+				case event := <-service.pending:
+					batch = append(batch, event)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// anonymize reemplaza el usuario por un hash con clave: permite contar usuarios
+// distintos sin guardar quiénes son
+func (service *Service) anonymize(user string) string {
+	if user == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(service.config.Salt))
+	mac.Write([]byte(user))
+	return hex.EncodeToString(mac.Sum(nil))[:userHashLength]
+}
+
+// signQueryID completa la parte aleatoria de un ID de búsqueda con la firma de
+// esa parte y de la consulta
+func (service *Service) signQueryID(nonce string, query string) string {
+	mac := hmac.New(sha256.New, []byte(service.config.Salt))
+	mac.Write([]byte("query_id\x00" + nonce + "\x00" + query))
+	return nonce + hex.EncodeToString(mac.Sum(nil))[:queryIDLength-queryNonceLength]
+}
+
+// normalizeQuery agrupa las variantes de una consulta que solo difieren en mayúsculas o espacios
+func normalizeQuery(query string) string {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if len(query) > maxQueryLength {
+		query = strings.ToValidUTF8(query[:maxQueryLength], "")
+	}
+	return query
+}
+
+func validQueryID(queryID string) bool {
+	if len(queryID) != queryIDLength {
+		return false
+	}
+	_, err := hex.DecodeString(queryID)
+	return err == nil
+}
+
+func randomHex(bytes int) string {
+	buf := make([]byte, bytes)
+	if _, err := rand.Read(buf); err != nil {
+		panic(fmt.Sprintf("error generating random ID: %v", err))
+	}
+	return hex.EncodeToString(buf)
+}
+
+func ratio(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func queryStats(stats []dao.QueryStat) []domain.QueryStat {
+	result := make([]domain.QueryStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, domain.QueryStat{
+			Query:           stat.Query,
+			Searches:        stat.Searches,
+			Users:           stat.Users,
+			AvgResults:      stat.AvgResults,
+			ClickedSearches: stat.ClickedSearches,
+			CTR:             ratio(stat.ClickedSearches, stat.Searches),
+			LastSearchedAt:  stat.LastSearchedAt,
+		})
+	}
+	return result
+}
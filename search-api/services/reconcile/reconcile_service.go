@@ -0,0 +1,293 @@
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
+	"fmt"
+	"log"
+	dao "search-api/dao/courses"
+	domain "search-api/domain/courses"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Index define las operaciones del índice que usa el reconciliador
+type Index interface {
+	Documents(ctx context.Context, ids []int64) ([]dao.Course, error)
+	LiveIDs(ctx context.Context, pageSize int, visit func(ids []int64) error) error
+	Index(ctx context.Context, course dao.Course) (string, error)
+	Update(ctx context.Context, course dao.Course) error
+	Delete(ctx context.Context, id string, version int64) error
+}
+
+// Source devuelve los cursos de courses-api por páginas ordenadas por ID
+type Source interface {
+	GetCoursesPage(ctx context.Context, offset int, limit int) ([]dao.Course, error)
+}
+
+// Valores por defecto del reconciliador
+const (
+	defaultInterval = 15 * time.Minute
+	defaultPageSize = 200
+	defaultTimeout  = 10 * time.Minute
+	maxSampleIDs    = 100
+)
+
+// metrics expone en /debug/vars el resultado de las pasadas del reconciliador
+var metrics = expvar.NewMap("reconciler")
+
+// Config configura el reconciliador
+type Config struct {
+	Interval time.Duration // Tiempo entre pasadas
+	PageSize int           // Cursos por página al recorrer courses-api y el índice
+	Timeout  time.Duration // Duración máxima de una pasada
+	DryRun   bool          // Solo informar las diferencias, sin corregirlas
+}
+
+// Service compara periódicamente los cursos de courses-api con los documentos
+// del índice y corrige las diferencias que dejan los eventos perdidos: cursos
+// faltantes, documentos de cursos eliminados o no disponibles y documentos
+// desactualizados. Nunca reemplaza un documento por una versión más vieja.
+type Service struct {
+	index  Index
+	source Source
+	config Config
+
+	trigger chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	mu     sync.Mutex
+	report domain.DriftReport
+}
+
+// NewService crea una nueva instancia del reconciliador
+func NewService(index Index, source Source, config Config) *Service {
+	if config.Interval <= 0 {
+		config.Interval = defaultInterval
+	}
+	if config.PageSize <= 0 {
+		config.PageSize = defaultPageSize
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Service{
+		index:   index,
+		source:  source,
+		config:  config,
+		trigger: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start lanza las pasadas periódicas en segundo plano
+func (service *Service) Start() {
+	go func() {
+		ticker := time.NewTicker(service.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-service.done:
+				return
+			case <-ticker.C:
+			case <-service.trigger:
+			}
+			service.runOnce()
+		}
+	}()
+}
+
+// Trigger pide una pasada inmediata. Si ya hay una pendiente, no agrega otra.
+func (service *Service) Trigger() {
+	select {
+	case service.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Close detiene las pasadas periódicas
+func (service *Service) Close() {
+	service.once.Do(func() { close(service.done) })
+}
+
+// Report devuelve el informe de la última pasada completa
+func (service *Service) Report() domain.DriftReport {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	return service.report
+}
+
+func (service *Service) runOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), service.config.Timeout)
+	defer cancel()
+
+	report, err := service.Reconcile(ctx)
+	if err != nil {
+		log.Printf("Error en la reconciliación del índice: %v", err)
+	}
+	log.Printf("Reconciliación del índice: %d cursos revisados, %d faltantes, %d sobrantes, %d desactualizados, %d corregidos",
+		report.Checked, report.Missing.Count, report.Extra.Count, report.Stale.Count, report.Fixed)
+
+	service.mu.Lock()
+	service.report = report
+	service.mu.Unlock()
+
+	metrics.Add("runs", 1)
+	metrics.Add("fixed", int64(report.Fixed))
+	metrics.Add("failed", int64(report.Failed))
+	setMetric("last_missing", report.Missing.Count)
+	setMetric("last_extra", report.Extra.Count)
+	setMetric("last_stale", report.Stale.Count)
+	setMetric("last_run", int(report.FinishedAt.Unix()))
+}
+
+func setMetric(name string, value int) {
+	metric := new(expvar.Int)
+	metric.Set(int64(value))
+	metrics.Set(name, metric)
+}
+
+// Reconcile hace una pasada completa y devuelve el informe de diferencias. Si
+// falla a mitad de camino, el informe incluye lo revisado hasta ese momento.
+func (service *Service) Reconcile(ctx context.Context) (domain.DriftReport, error) {
+	report := domain.DriftReport{
+		StartedAt: time.Now().UTC(),
+		DryRun:    service.config.DryRun,
+	}
+	err := service.reconcile(ctx, &report)
+	report.FinishedAt = time.Now().UTC()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
+
+func (service *Service) reconcile(ctx context.Context, report *domain.DriftReport) error {
+	// IDs de todos los cursos de courses-api, disponibles o no
+	known := make(map[int64]bool)
+
+	for offset := 0; ; offset += service.config.PageSize {
+		page, err := service.source.GetCoursesPage(ctx, offset, service.config.PageSize)
+		if err != nil {
+			return fmt.Errorf("error al obtener los cursos (offset %d): %w", offset, err)
+		}
+
+		ids := make([]int64, 0, len(page))
+		for _, course := range page {
+			ids = append(ids, course.ID)
+			known[course.ID] = true
+		}
+		docs, err := service.index.Documents(ctx, ids)
+		if err != nil {
+			return err
+		}
+		indexed := make(map[int64]dao.Course, len(docs))
+		for _, doc := range docs {
+			indexed[doc.ID] = doc
+		}
+
+		for _, course := range page {
+			service.compare(ctx, report, course, indexed)
+		}
+		report.Checked += len(page)
+
+		if len(page) < service.config.PageSize {
+			break
+		}
+	}
+
+	// Documentos en vivo de cursos que ya no existen en courses-api
+	return service.index.LiveIDs(ctx, service.config.PageSize, func(ids []int64) error {
+		var unknown []int64
+		for _, id := range ids {
+			if !known[id] {
+				unknown = append(unknown, id)
+			}
+		}
+		if len(unknown) == 0 {
+			return nil
+		}
+
+		docs, err := service.index.Documents(ctx, unknown)
+		if err != nil {
+			return err
+		}
+		scanStart := report.StartedAt.UnixMicro()
+		for _, doc := range docs {
+			// Las versiones son marcas de tiempo: un documento escrito después de
+			// empezar la pasada es de un curso creado mientras se recorría courses-api
+			if doc.Deleted || doc.Version >= scanStart {
+				continue
+			}
+			addDrift(&report.Extra, doc.ID)
+			service.fix(report, func() error {
+				return service.index.Delete(ctx, strconv.FormatInt(doc.ID, 10), time.Now().UnixMicro())
+			})
+		}
+		return nil
+	})
+}
+
+// compare revisa un curso de courses-api contra su documento en el índice
+func (service *Service) compare(ctx context.Context, report *domain.DriftReport, course dao.Course, indexed map[int64]dao.Course) {
+	doc, found := indexed[course.ID]
+	// El índice ya tiene algo más nuevo que lo leído: lo trae un evento posterior
+	if found && doc.Version > course.Version {
+		return
+	}
+
+	switch {
+	case course.Available && (!found || doc.Deleted):
+		addDrift(&report.Missing, course.ID)
+		service.fix(report, func() error {
+			_, err := service.index.Index(ctx, course)
+			return err
+		})
+
+	case course.Available && (doc.Version < course.Version || courseHash(doc) != courseHash(course)):
+		addDrift(&report.Stale, course.ID)
+		service.fix(report, func() error {
+			return service.index.Update(ctx, course)
+		})
+
+	case !course.Available && found && !doc.Deleted:
+		// Los cursos no disponibles no se buscan (ver UpdateCourseAvailability en courses-api)
+		addDrift(&report.Extra, course.ID)
+		service.fix(report, func() error {
+			return service.index.Delete(ctx, strconv.FormatInt(course.ID, 10), course.Version)
+		})
+	}
+}
+
+// fix aplica una corrección, salvo en modo de solo informe
+func (service *Service) fix(report *domain.DriftReport, apply func() error) {
+	if service.config.DryRun {
+		return
+	}
+	if err := apply(); err != nil {
+		log.Printf("Error al corregir una diferencia del índice: %v", err)
+		report.Failed++
+		return
+	}
+	report.Fixed++
+}
+
+func addDrift(set *domain.DriftSet, id int64) {
+	set.Count++
+	if len(set.IDs) < maxSampleIDs {
+		set.IDs = append(set.IDs, id)
+	}
+}
+
+// courseHash resume los campos indexados de un curso, para detectar documentos
+// con la misma versión pero distinto contenido
+func courseHash(course dao.Course) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d\x00%d\x00%t\x00%g",
+		course.Name, course.Category, course.Description, course.Duration,
+		course.InstructorID, course.Capacity, course.Available, course.Rating)))
+	return hex.EncodeToString(sum[:])
+}
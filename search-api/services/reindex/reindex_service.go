@@ -0,0 +1,288 @@
+package reindex
+
+import (
+	"context"
+	"fmt"
+	"log"
+	dao "search-api/dao/courses"
+	domain "search-api/domain/courses"
+	"sync"
+	"time"
+)
+
+// Index define las operaciones del motor de búsqueda que usa la reindexación
+type Index interface {
+	CreateCore(ctx context.Context, name string) error
+	UnloadCore(ctx context.Context, name string) error
+	SwapCores(ctx context.Context, other string) error
+	StartMirror(core string)
+	StopMirror()
+	MirroredWrites() int
+	AddBatch(ctx context.Context, core string, courses []dao.Course, commitWithin time.Duration) error
+	CommitCore(ctx context.Context, core string) error
+	Versions(ctx context.Context, core string, ids []int64) (map[int64]int64, error)
+	Count(ctx context.Context, core string) (int, error)
+	Tombstones(ctx context.Context, pageSize int, visit func(tombstones []dao.Course) error) error
+}
+
+// Source devuelve los cursos a indexar
+type Source interface {
+	GetCoursesAvailability(ctx context.Context) ([]dao.Course, error)
+}
+
+// Valores por defecto de la reindexación
+const (
+	defaultBatchSize    = 500
+	defaultCommitWithin = 5 * time.Second
+	defaultTimeout      = 30 * time.Minute
+)
+
+// Config configura la reindexación
+type Config struct {
+	Collection   string        // Nombre del core en vivo
+	BatchSize    int           // Cursos por escritura
+	CommitWithin time.Duration // Plazo que se le da a SolR para confirmar cada lote
+	Timeout      time.Duration // Duración máxima de una reindexación
+}
+
+// Service reconstruye el índice de búsqueda sin cortar las búsquedas: carga
+// todos los cursos en un core nuevo, valida la cantidad de documentos y lo
+// intercambia atómicamente con el core en vivo. Mientras se construye, los
+// eventos de RabbitMQ se escriben en ambos cores. Las bajas del core en vivo
+// (documentos que solo guardan la versión de la baja) se copian al nuevo, así
+// un evento viejo que llega después del intercambio no revive un curso borrado.
+type Service struct {
+	index  Index
+	source Source
+	config Config
+
+	mu     sync.Mutex
+	status domain.ReindexStatus
+}
+
+// NewService crea una nueva instancia del servicio de reindexación
+func NewService(index Index, source Source, config Config) *Service {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.CommitWithin <= 0 {
+		config.CommitWithin = defaultCommitWithin
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Service{
+		index:  index,
+		source: source,
+		config: config,
+		status: domain.ReindexStatus{State: domain.ReindexIdle},
+	}
+}
+
+// Status devuelve el estado de la reindexación en curso o de la última realizada
+func (service *Service) Status() domain.ReindexStatus {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	return service.status
+}
+
+// Start lanza una reindexación en segundo plano. Devuelve domain.ErrReindexRunning
+// si ya hay una en curso.
+func (service *Service) Start() (domain.ReindexStatus, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if service.status.State == domain.ReindexRunning {
+		return service.status, domain.ErrReindexRunning
+	}
+
+	now := time.Now().UTC()
+	core := fmt.Sprintf("%s_%s", service.config.Collection, now.Format("20060102150405"))
+	service.status = domain.ReindexStatus{
+		State:     domain.ReindexRunning,
+		Phase:     "creating_core",
+		Core:      core,
+		StartedAt: &now,
+	}
+
+	go service.run(core)
+	return service.status, nil
+}
+
+// update modifica el estado bajo el mutex
+func (service *Service) update(change func(status *domain.ReindexStatus)) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	change(&service.status)
+}
+
+func (service *Service) run(core string) {
+	ctx, cancel := context.WithTimeout(context.Background(), service.config.Timeout)
+	defer cancel()
+
+	log.Printf("Reindexación iniciada en el core %s", core)
+	if err := service.rebuild(ctx, core); err != nil {
+		log.Printf("Reindexación fallida: %v", err)
+		service.index.StopMirror()
+		if unloadErr := service.index.UnloadCore(context.Background(), core); unloadErr != nil {
+			log.Printf("Error al descartar el core %s: %v", core, unloadErr)
+		}
+		service.finish(domain.ReindexFailed, err)
+		return
+	}
+	log.Printf("Reindexación completada: el core %s está en vivo", core)
+	service.finish(domain.ReindexSucceeded, nil)
+}
+
+func (service *Service) finish(state string, err error) {
+	now := time.Now().UTC()
+	service.update(func(status *domain.ReindexStatus) {
+		status.State = state
+		status.FinishedAt = &now
+		if err != nil {
+			status.Error = err.Error()
+		}
+	})
+}
+
+// rebuild construye el nuevo core y, si la validación es correcta, lo pone en vivo
+func (service *Service) rebuild(ctx context.Context, core string) error {
+	if err := service.index.CreateCore(ctx, core); err != nil {
+		return err
+	}
+	service.index.StartMirror(core)
+
+	liveCount, err := service.index.Count(ctx, service.config.Collection)
+	if err != nil {
+		return err
+	}
+
+	// Las bajas van primero: loadBatch saltea los cursos que courses-api
+	// devuelva con una versión anterior a la de su baja
+	service.update(func(status *domain.ReindexStatus) { status.Phase = "tombstones" })
+	err = service.index.Tombstones(ctx, service.config.BatchSize, func(tombstones []dao.Course) error {
+		return service.copyTombstones(ctx, core, tombstones)
+	})
+	if err != nil {
+		return fmt.Errorf("error al copiar las bajas: %w", err)
+	}
+
+	service.update(func(status *domain.ReindexStatus) { status.Phase = "loading" })
+	courses, err := service.source.GetCoursesAvailability(ctx)
+	if err != nil {
+		return fmt.Errorf("error al obtener los cursos: %w", err)
+	}
+	expected := len(courses)
+	service.update(func(status *domain.ReindexStatus) { status.Expected = expected })
+
+	// Una respuesta vacía de courses-api no debe dejar el buscador sin cursos
+	if expected == 0 && liveCount > 0 {
+		return fmt.Errorf("courses-api no devolvió cursos y el índice en vivo tiene %d", liveCount)
+	}
+
+	for start := 0; start < len(courses); start += service.config.BatchSize {
+		end := start + service.config.BatchSize
+		if end > len(courses) {
+			end = len(courses)
+		}
+		if err := service.loadBatch(ctx, core, courses[start:end]); err != nil {
+			return err
+		}
+	}
+
+	service.update(func(status *domain.ReindexStatus) { status.Phase = "validating" })
+	if err := service.index.CommitCore(ctx, core); err != nil {
+		return err
+	}
+	count, err := service.index.Count(ctx, core)
+	if err != nil {
+		return err
+	}
+	service.update(func(status *domain.ReindexStatus) { status.Count = count })
+
+	// Solo los eventos recibidos durante la carga pueden explicar una diferencia
+	difference := count - expected
+	if difference < 0 {
+		difference = -difference
+	}
+	if mirrored := service.index.MirroredWrites(); difference > mirrored {
+		return fmt.Errorf("el nuevo core tiene %d cursos, se esperaban %d (con %d eventos recibidos durante la carga)", count, expected, mirrored)
+	}
+
+	service.update(func(status *domain.ReindexStatus) { status.Phase = "swapping" })
+	if err := service.index.SwapCores(ctx, core); err != nil {
+		return err
+	}
+	service.index.StopMirror()
+
+	// Tras el intercambio, el nombre del core nuevo corresponde al índice anterior
+	service.update(func(status *domain.ReindexStatus) { status.Phase = "cleanup" })
+	if err := service.index.UnloadCore(ctx, core); err != nil {
+		log.Printf("Error al descartar el índice anterior (%s): %v", core, err)
+	}
+	return nil
+}
+
+// copyTombstones escribe en el nuevo core un lote de bajas del core en vivo,
+// salvo las de cursos que el nuevo core ya tiene con una versión igual o más
+// nueva por eventos recibidos durante la carga
+func (service *Service) copyTombstones(ctx context.Context, core string, tombstones []dao.Course) error {
+	ids := make([]int64, 0, len(tombstones))
+	for _, tombstone := range tombstones {
+		ids = append(ids, tombstone.ID)
+	}
+	versions, err := service.index.Versions(ctx, core, ids)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]dao.Course, 0, len(tombstones))
+	for _, tombstone := range tombstones {
+		if version, ok := versions[tombstone.ID]; ok && version >= tombstone.Version {
+			continue
+		}
+		pending = append(pending, tombstone)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := service.index.AddBatch(ctx, core, pending, service.config.CommitWithin); err != nil {
+		return err
+	}
+
+	service.update(func(status *domain.ReindexStatus) { status.Tombstones += len(pending) })
+	return nil
+}
+
+// loadBatch escribe un lote de cursos, salteando los que el core ya tiene con
+// una versión igual o más nueva por eventos recibidos durante la carga
+func (service *Service) loadBatch(ctx context.Context, core string, batch []dao.Course) error {
+	ids := make([]int64, 0, len(batch))
+	for _, course := range batch {
+		ids = append(ids, course.ID)
+	}
+	versions, err := service.index.Versions(ctx, core, ids)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]dao.Course, 0, len(batch))
+	for _, course := range batch {
+		if version, ok := versions[course.ID]; ok && version >= course.Version {
+			continue
+		}
+		pending = append(pending, course)
+	}
+
+	if len(pending) > 0 {
+		if err := service.index.AddBatch(ctx, core, pending, service.config.CommitWithin); err != nil {
+			return err
+		}
+	}
+
+	service.update(func(status *domain.ReindexStatus) {
+		status.Indexed += len(pending)
+		status.Skipped += len(batch) - len(pending)
+	})
+	return nil
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"sync"
+	"time"
+)
+
+// eventWindow recuerda los IDs de los eventos ya procesados durante un tiempo,
+// para que una redelivery de RabbitMQ no vuelva a aplicarse
+type eventWindow struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func newEventWindow(ttl time.Duration) *eventWindow {
+	return &eventWindow{
+		ttl:       ttl,
+		seen:      make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+}
+
+// Seen indica si el evento ya se procesó dentro de la ventana
+func (window *eventWindow) Seen(eventID string) bool {
+	window.mu.Lock()
+	defer window.mu.Unlock()
+
+	processedAt, ok := window.seen[eventID]
+	return ok && time.Since(processedAt) < window.ttl
+}
+
+// Mark registra el evento como procesado y descarta los que salieron de la ventana
+func (window *eventWindow) Mark(eventID string) {
+	window.mu.Lock()
+	defer window.mu.Unlock()
+
+	now := time.Now()
+	window.seen[eventID] = now
+
+	if now.Sub(window.lastPrune) < window.ttl {
+		return
+	}
+	for id, processedAt := range window.seen {
+		if now.Sub(processedAt) >= window.ttl {
+			delete(window.seen, id)
+		}
+	}
+	window.lastPrune = now
+}
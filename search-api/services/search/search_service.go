@@ -2,26 +2,37 @@ package search
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	dao "search-api/dao/courses"                            // Alias para los tipos de DAO
 	domain "search-api/domain/courses"                      // Alias para los tipos de dominio
 	httpRepo "search-api/repositories/courses/courses_http" // Importar el paquete HTTP
 	"strconv"
+	"time"
+
+	"httpclient"
 )
 
 // Repository define las operaciones necesarias en el índice de SolR
 type Repository interface {
 	Index(ctx context.Context, course dao.Course) (string, error)
 	Update(ctx context.Context, course dao.Course) error
-	Delete(ctx context.Context, id string) error
-	Search(ctx context.Context, query string, limit int, offset int) ([]dao.Course, error)
+	Delete(ctx context.Context, id string, version int64) error
+	Version(ctx context.Context, id string) (int64, error)
+	Search(ctx context.Context, query string, filters dao.SearchFilters, sort dao.SearchSort, offset int, limit int) (dao.SearchResult, error)
+	Suggest(ctx context.Context, prefix string, limit int) ([]dao.Course, error)
+	Similar(ctx context.Context, id int64, offset int, limit int) (dao.SearchResult, error)
 }
 
+// dedupWindow es el tiempo durante el cual se recuerdan los eventos ya procesados
+const dedupWindow = 10 * time.Minute
+
 // Service representa el servicio de búsqueda
 type Service struct {
 	repository Repository
 	httpClient httpRepo.HTTP // Cliente HTTP para interactuar con la API de Cursos
+	processed  *eventWindow  // Eventos procesados recientemente
 }
 
 // NewService crea una nueva instancia del servicio de búsqueda
@@ -29,97 +40,226 @@ func NewService(repository Repository, httpClient httpRepo.HTTP) Service {
 	return Service{
 		repository: repository,
 		httpClient: httpClient,
+		processed:  newEventWindow(dedupWindow),
 	}
 }
 
-// HandleCourseUpdate procesa las actualizaciones de cursos recibidas desde RabbitMQ
-func (service Service) HandleCourseUpdate(courseNew domain.CourseUpdate) {
+// HandleCourseUpdate procesa las actualizaciones de cursos recibidas desde RabbitMQ.
+// Devuelve un error para que el consumidor reintente el mensaje; los errores que
+// envuelven domain.ErrInvalidEvent no se reintentan.
+//
+// Es idempotente: los eventos repetidos dentro de la ventana de deduplicación se
+// ignoran, y los que traen una versión anterior a la indexada (incluidas las
+// bajas) se descartan para no pisar datos más nuevos ni revivir cursos eliminados.
+// De eso depende el orden por curso: un evento que falló vuelve de la cola de
+// reintentos después de los posteriores del mismo curso.
+func (service Service) HandleCourseUpdate(courseNew domain.CourseUpdate) error {
 	ctx := context.Background()
 
 	// Agregar log para ver el mensaje recibido
 	log.Printf("Mensaje recibido para procesar: %+v", courseNew)
 
+	if courseNew.EventID != "" && service.processed.Seen(courseNew.EventID) {
+		log.Printf("Evento duplicado ignorado: %s", courseNew.EventID)
+		return nil
+	}
+
 	// Convertir ID a string
 	courseIDStr := strconv.FormatInt(courseNew.ID, 10) // Convertir ID a string
 
+	indexedVersion, err := service.repository.Version(ctx, courseIDStr)
+	if err != nil {
+		return fmt.Errorf("error al obtener la versión indexada del curso (%d): %w", courseNew.ID, err)
+	}
+	// Los eventos sin versión (publicados antes de versionar los cursos) no se descartan aquí
+	if courseNew.Version > 0 && courseNew.Version < indexedVersion {
+		log.Printf("Evento %s obsoleto para el curso %d: versión %d < indexada %d", courseNew.Operation, courseNew.ID, courseNew.Version, indexedVersion)
+		service.markProcessed(courseNew)
+		return nil
+	}
+
 	switch courseNew.Operation {
-	case "POST":
+	case "POST", "UPDATE":
 
 		// Llamar a GetCourseByID y almacenar el resultado en 'curso'
 		courseUpdate, err := service.httpClient.GetCourseByID(ctx, courseIDStr) // Usar courseIDStr
-		curso := dao.Course{
-			ID:          courseUpdate.ID,
-			Name:        courseUpdate.Name,
-			Category:    courseUpdate.Category,
-			Description: courseUpdate.Description,
+		if errors.Is(err, httpclient.ErrNotFound) {
+			// El curso se borró después del evento: el DELETE lo saca del índice
+			log.Printf("Curso %d borrado antes de procesar el evento %s, se ignora", courseNew.ID, courseNew.Operation)
+			service.markProcessed(courseNew)
+			return nil
 		}
 		if err != nil {
-			log.Printf("Error al obtener el curso (ID: %s): %v", courseIDStr, err) // Cambiar ID a string
-			return                                                                 // Salir de la función si hay un error
+			return fmt.Errorf("error al obtener el curso (ID: %s): %w", courseIDStr, err)
 		}
-		log.Printf("Curso obtenido, listo para procesar la operación: %d", curso.ID) // ID sigue en int64
-		log.Printf("Procesando operación POST para el curso: %d", curso.ID)
-		// Indexar el nuevo curso en SolR
-		if _, err := service.repository.Index(ctx, curso); err != nil {
-			log.Printf("Error al indexar el curso (%d): %v", curso.ID, err)
-		} else {
-			log.Printf("Curso indexado exitosamente: %d", curso.ID)
+		if courseUpdate.Version < indexedVersion {
+			log.Printf("Curso %d obtenido con versión %d anterior a la indexada %d, se ignora", courseUpdate.ID, courseUpdate.Version, indexedVersion)
+			service.markProcessed(courseNew)
+			return nil
 		}
-
-	case "UPDATE":
-
-		courseUpdate, err := service.httpClient.GetCourseByID(ctx, courseIDStr) // Usar courseIDStr
 		curso := dao.Course{
-			ID:          courseUpdate.ID,
-			Name:        courseUpdate.Name,
-			Category:    courseUpdate.Category,
-			Description: courseUpdate.Description,
-		}
-		if err != nil {
-			log.Printf("Error al obtener el curso (ID: %s): %v", courseIDStr, err) // Cambiar ID a string
-			return                                                                 // Salir de la función si hay un error
+			ID:           courseUpdate.ID,
+			Name:         courseUpdate.Name,
+			Category:     courseUpdate.Category,
+			Description:  courseUpdate.Description,
+			Duration:     courseUpdate.Duration,
+			InstructorID: courseUpdate.InstructorID,
+			Capacity:     courseUpdate.Capacity,
+			Available:    courseUpdate.Available,
+			Rating:       courseUpdate.Rating,
+			Version:      courseUpdate.Version,
 		}
-		log.Printf("Curso obtenido, listo para procesar la operación: %d", curso.ID) // ID sigue en int64
-		log.Printf("Procesando operación UPDATE para el curso: %d", curso.ID)
-		// Actualizar el curso existente en SolR
-		if err := service.repository.Update(ctx, curso); err != nil {
-			log.Printf("Error al actualizar el curso (%d): %v", courseUpdate.ID, err)
-		} else {
-			log.Printf("Curso actualizado exitosamente: %d", courseUpdate.ID)
+		log.Printf("Procesando operación %s para el curso: %d", courseNew.Operation, curso.ID)
+		// Indexar o actualizar el curso en SolR
+		if courseNew.Operation == "POST" {
+			if _, err := service.repository.Index(ctx, curso); err != nil {
+				return fmt.Errorf("error al indexar el curso (%d): %w", curso.ID, err)
+			}
+		} else if err := service.repository.Update(ctx, curso); err != nil {
+			return fmt.Errorf("error al actualizar el curso (%d): %w", curso.ID, err)
 		}
+		log.Printf("Curso indexado exitosamente: %d (versión %d)", curso.ID, curso.Version)
 
 	case "DELETE":
 		log.Printf("Procesando operación DELETE para el curso: %d", courseNew.ID)
-		// Eliminar el curso del índice de SolR
-		if err := service.repository.Delete(ctx, courseIDStr); err != nil { // Usar courseIDStr
-			log.Printf("Error al eliminar el curso (%d): %v", courseNew.ID, err)
-		} else {
-			log.Printf("Curso eliminado exitosamente: %d", courseNew.ID)
+		// Reemplazar el curso por una baja que conserva la versión del evento
+		deletedVersion := courseNew.Version
+		if deletedVersion < indexedVersion {
+			deletedVersion = indexedVersion
 		}
+		if err := service.repository.Delete(ctx, courseIDStr, deletedVersion); err != nil { // Usar courseIDStr
+			return fmt.Errorf("error al eliminar el curso (%d): %w", courseNew.ID, err)
+		}
+		log.Printf("Curso eliminado exitosamente: %d", courseNew.ID)
 
 	default:
-		log.Printf("Operación desconocida: %s", courseNew.Operation)
+		return fmt.Errorf("%w: operación desconocida %q", domain.ErrInvalidEvent, courseNew.Operation)
+	}
+
+	service.markProcessed(courseNew)
+	return nil
+}
+
+// markProcessed recuerda el evento para descartar sus redeliveries
+func (service Service) markProcessed(courseNew domain.CourseUpdate) {
+	if courseNew.EventID != "" {
+		service.processed.Mark(courseNew.EventID)
+	}
+}
+
+// Search busca cursos en SolR según el término de búsqueda, los filtros y el orden,
+// devolviendo la página indicada por offset y limit
+func (service Service) Search(ctx context.Context, query string, filters domain.SearchFilters, sort string, offset int, limit int) (domain.SearchResponse, error) {
+	daoResults, err := service.repository.Search(ctx, query, dao.SearchFilters{
+		Category:     filters.Category,
+		MinRating:    filters.MinRating,
+		Available:    filters.Available,
+		InstructorID: filters.InstructorID,
+	}, searchSort(sort), offset, limit)
+	if err != nil {
+		return domain.SearchResponse{}, fmt.Errorf("error en la búsqueda de cursos: %w", err)
+	}
+
+	var didYouMean []domain.Collation
+	for _, collation := range daoResults.Collations {
+		didYouMean = append(didYouMean, domain.Collation{Query: collation.Query, Hits: collation.Hits})
 	}
+
+	return domain.SearchResponse{
+		Results:     searchResults(daoResults.Courses),
+		NumFound:    daoResults.NumFound,
+		Offset:      offset,
+		Limit:       limit,
+		Sort:        sort,
+		QueryTimeMs: daoResults.QTime,
+		Facets: domain.Facets{
+			Categories:   facetCounts(daoResults.Facets.Categories),
+			Ratings:      facetCounts(daoResults.Facets.Ratings),
+			Availability: facetCounts(daoResults.Facets.Availability),
+		},
+		DidYouMean: didYouMean,
+	}, nil
 }
 
-// Search busca cursos en SolR según el término de búsqueda, límite y desplazamiento
-func (service Service) Search(ctx context.Context, query string, limit int, offset int) ([]domain.CourseUpdate, error) {
-	daoResults, err := service.repository.Search(ctx, query, limit, offset)
+// Suggest devuelve los cursos disponibles cuyo nombre o categoría empiezan con el texto ingresado
+func (service Service) Suggest(ctx context.Context, prefix string, limit int) ([]domain.Suggestion, error) {
+	daoCourses, err := service.repository.Suggest(ctx, prefix, limit)
 	if err != nil {
-		return nil, fmt.Errorf("error en la búsqueda de cursos: %w", err)
+		return nil, fmt.Errorf("error al obtener sugerencias: %w", err)
 	}
 
-	// Convertir de dao.Course a domain.CourseUpdate
-	var results []domain.CourseUpdate
-	for _, daoCourse := range daoResults {
-		results = append(results, domain.CourseUpdate{
-			Operation:   "SEARCH", // El tipo de operación es 'SEARCH' para los resultados de la búsqueda
-			ID:          daoCourse.ID,
-			Name:        daoCourse.Name,
-			Category:    daoCourse.Category,
-			Description: daoCourse.Description,
+	suggestions := make([]domain.Suggestion, 0, len(daoCourses))
+	for _, daoCourse := range daoCourses {
+		suggestions = append(suggestions, domain.Suggestion{
+			ID:       daoCourse.ID,
+			Name:     daoCourse.Name,
+			Category: daoCourse.Category,
 		})
 	}
+	return suggestions, nil
+}
+
+// Similar devuelve los cursos disponibles más parecidos a uno dado, sin incluirlo
+func (service Service) Similar(ctx context.Context, id int64, offset int, limit int) (domain.SimilarResponse, error) {
+	daoResults, err := service.repository.Similar(ctx, id, offset, limit)
+	if err != nil {
+		return domain.SimilarResponse{}, fmt.Errorf("error al buscar cursos parecidos al curso %d: %w", id, err)
+	}
+
+	return domain.SimilarResponse{
+		CourseID: id,
+		Results:  searchResults(daoResults.Courses),
+		NumFound: daoResults.NumFound,
+		Offset:   offset,
+		Limit:    limit,
+	}, nil
+}
 
-	return results, nil
+// searchResults convierte los cursos del DAO en resultados de búsqueda
+func searchResults(daoCourses []dao.Course) []domain.SearchResult {
+	results := make([]domain.SearchResult, 0, len(daoCourses))
+	for _, daoCourse := range daoCourses {
+		var highlight *domain.Highlight
+		if daoCourse.HighlightedName != "" || daoCourse.HighlightedDescription != "" {
+			highlight = &domain.Highlight{
+				Name:        daoCourse.HighlightedName,
+				Description: daoCourse.HighlightedDescription,
+			}
+		}
+		results = append(results, domain.SearchResult{
+			ID:           daoCourse.ID,
+			Name:         daoCourse.Name,
+			Category:     daoCourse.Category,
+			Description:  daoCourse.Description,
+			Duration:     daoCourse.Duration,
+			InstructorID: daoCourse.InstructorID,
+			Capacity:     daoCourse.Capacity,
+			Available:    daoCourse.Available,
+			Rating:       daoCourse.Rating,
+			Score:        daoCourse.Score,
+			Highlight:    highlight,
+		})
+	}
+	return results
+}
+
+// searchSort traduce el criterio de orden de la API al del índice
+func searchSort(sort string) dao.SearchSort {
+	switch sort {
+	case domain.SortRating:
+		return dao.SortByRating
+	case domain.SortName:
+		return dao.SortByName
+	default:
+		return dao.SortByScore
+	}
+}
+
+// facetCounts convierte los conteos de una faceta del DAO al dominio
+func facetCounts(daoCounts []dao.FacetCount) []domain.FacetCount {
+	counts := make([]domain.FacetCount, 0, len(daoCounts))
+	for _, count := range daoCounts {
+		counts = append(counts, domain.FacetCount{Value: count.Value, Count: count.Count})
+	}
+	return counts
 }
@@ -0,0 +1,369 @@
+package search_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	dao "search-api/dao/courses"
+	domain "search-api/domain/courses"
+	httpRepo "search-api/repositories/courses/courses_http"
+	memoryRepo "search-api/repositories/courses/courses_memory"
+	service "search-api/services/search"
+)
+
+// coursesAPI simula courses-api: sirve los cursos cargados en GET /courses/:id
+type coursesAPI struct {
+	mu      sync.Mutex
+	courses map[string]domain.CourseUpdate
+	down    bool // Responde 503 a todo, como un courses-api caído
+}
+
+func (api *coursesAPI) setDown(down bool) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.down = down
+}
+
+func (api *coursesAPI) set(course domain.CourseUpdate) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.courses[strconv.FormatInt(course.ID, 10)] = course
+}
+
+func (api *coursesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	course, ok := api.courses[strings.TrimPrefix(r.URL.Path, "/courses/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(course)
+}
+
+// newService crea el servicio sobre el motor en memoria y un courses-api simulado
+func newService(t *testing.T) (service.Service, *memoryRepo.Memory, *coursesAPI) {
+	t.Helper()
+	api := &coursesAPI{courses: map[string]domain.CourseUpdate{}}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("invalid test server URL %s: %v", server.URL, err)
+	}
+	engine := memoryRepo.NewMemory(memoryRepo.MemoryConfig{Collection: "courses"})
+	client := httpRepo.NewHTTP(httpRepo.HTTPConfig{Host: host, Port: port})
+	return service.NewService(engine, client), engine, api
+}
+
+func index(t *testing.T, engine *memoryRepo.Memory, courses ...dao.Course) {
+	t.Helper()
+	for _, course := range courses {
+		if _, err := engine.Index(context.Background(), course); err != nil {
+			t.Fatalf("error indexing course %d: %v", course.ID, err)
+		}
+	}
+}
+
+func ids(results []domain.SearchResult) []int64 {
+	var result []int64
+	for _, r := range results {
+		result = append(result, r.ID)
+	}
+	return result
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func facetCount(counts []domain.FacetCount, value string) int {
+	for _, count := range counts {
+		if count.Value == value {
+			return count.Count
+		}
+	}
+	return 0
+}
+
+func catalog() []dao.Course {
+	return []dao.Course{
+		{ID: 1, Name: "Programación en Go", Category: "Programación", Description: "Concurrencia y servicios web con Go", Rating: 4.8, Available: true, Version: 1},
+		{ID: 2, Name: "Programación en Python", Category: "Programación", Description: "Scripts y análisis de datos", Rating: 4.2, Available: true, Version: 1},
+		{ID: 3, Name: "Diseño web", Category: "Diseño", Description: "Maquetación y programación de interfaces", Rating: 3.5, Available: false, Version: 1},
+		{ID: 4, Name: "Bases de datos", Category: "Datos", Description: "Modelado relacional", Rating: 5, Available: true, Version: 1},
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	svc, engine, _ := newService(t)
+	index(t, engine, catalog()...)
+
+	t.Run("Ranks name matches first and folds accents and plurals", func(t *testing.T) {
+		response, err := svc.Search(ctx, "programacion", domain.SearchFilters{}, domain.SortRelevance, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.NumFound != 3 {
+			t.Fatalf("expected 3 results, got %d", response.NumFound)
+		}
+		if got := ids(response.Results); got[2] != 3 {
+			t.Errorf("expected the description match last, got %v", got)
+		}
+		if response.Results[0].Highlight == nil || !strings.Contains(response.Results[0].Highlight.Name, "<em>Programación</em>") {
+			t.Errorf("expected highlighted name, got %+v", response.Results[0].Highlight)
+		}
+	})
+
+	t.Run("Filters keep facets of the other values", func(t *testing.T) {
+		available := true
+		response, err := svc.Search(ctx, "programacion", domain.SearchFilters{Available: &available}, domain.SortRelevance, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.NumFound != 2 {
+			t.Errorf("expected 2 available results, got %d", response.NumFound)
+		}
+		if got := facetCount(response.Facets.Availability, "false"); got != 1 {
+			t.Errorf("expected the availability facet to ignore its own filter, got %d unavailable", got)
+		}
+		if got := facetCount(response.Facets.Categories, "Diseño"); got != 0 {
+			t.Errorf("expected the category facet to honor the availability filter, got %d", got)
+		}
+		if got := facetCount(response.Facets.Ratings, "4-5"); got != 2 {
+			t.Errorf("expected 2 courses rated 4-5, got %d", got)
+		}
+	})
+
+	t.Run("Sorts and pages", func(t *testing.T) {
+		first, err := svc.Search(ctx, "programacion", domain.SearchFilters{}, domain.SortRating, 0, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		second, err := svc.Search(ctx, "programacion", domain.SearchFilters{}, domain.SortRating, 2, 2)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := append(ids(first.Results), ids(second.Results)...); !equalIDs(got, []int64{1, 2, 3}) {
+			t.Errorf("expected courses by rating [1 2 3], got %v", got)
+		}
+		if first.NumFound != 3 || second.NumFound != 3 {
+			t.Errorf("expected the total on every page, got %d and %d", first.NumFound, second.NumFound)
+		}
+
+		byName, err := svc.Search(ctx, "programacion", domain.SearchFilters{}, domain.SortName, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ids(byName.Results); !equalIDs(got, []int64{3, 1, 2}) {
+			t.Errorf("expected courses by name [3 1 2], got %v", got)
+		}
+	})
+
+	t.Run("Requires every term of short queries", func(t *testing.T) {
+		response, err := svc.Search(ctx, "programacion python", domain.SearchFilters{}, domain.SortRelevance, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ids(response.Results); !equalIDs(got, []int64{2}) {
+			t.Errorf("expected only course 2, got %v", got)
+		}
+	})
+
+	t.Run("Expands synonyms", func(t *testing.T) {
+		if _, err := engine.AddSynonyms(ctx, []string{"bbdd", "bases de datos"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := svc.Search(ctx, "bbdd", domain.SearchFilters{}, domain.SortRelevance, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ids(response.Results); !equalIDs(got, []int64{4}) {
+			t.Errorf("expected course 4, got %v", got)
+		}
+	})
+
+	t.Run("Rejects empty queries", func(t *testing.T) {
+		if _, err := svc.Search(ctx, "  ", domain.SearchFilters{}, domain.SortRelevance, 0, 10); err == nil {
+			t.Error("expected an error for an empty query")
+		}
+	})
+}
+
+func TestSimilar(t *testing.T) {
+	ctx := context.Background()
+	svc, engine, _ := newService(t)
+	index(t, engine, catalog()...)
+
+	response, err := svc.Similar(ctx, 1, 0, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// El curso 3 también habla de programación, pero no está disponible
+	if got := ids(response.Results); !equalIDs(got, []int64{2}) {
+		t.Errorf("expected only course 2, got %v", got)
+	}
+
+	page, err := svc.Similar(ctx, 1, 1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Results) != 0 || page.NumFound != 1 {
+		t.Errorf("expected an empty second page of 1 result, got %d of %d", len(page.Results), page.NumFound)
+	}
+
+	if _, err := svc.Similar(ctx, 99, 0, 10); !errors.Is(err, domain.ErrCourseNotFound) {
+		t.Errorf("expected course not found, got %v", err)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	svc, engine, _ := newService(t)
+	index(t, engine, catalog()...)
+
+	suggestions, err := svc.Suggest(ctx, "dis", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(suggestions) != 0 {
+		t.Errorf("expected no suggestions for unavailable courses, got %+v", suggestions)
+	}
+
+	suggestions, err = svc.Suggest(ctx, "prog", 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(suggestions) != 2 || suggestions[0].ID != 1 || suggestions[1].ID != 2 {
+		t.Errorf("expected courses 1 and 2, got %+v", suggestions)
+	}
+}
+
+func TestHandleCourseUpdate(t *testing.T) {
+	ctx := context.Background()
+	svc, _, api := newService(t)
+
+	search := func(query string) []int64 {
+		t.Helper()
+		response, err := svc.Search(ctx, query, domain.SearchFilters{}, domain.SortRelevance, 0, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return ids(response.Results)
+	}
+
+	api.set(domain.CourseUpdate{ID: 7, Name: "Introducción a Kubernetes", Category: "DevOps", Available: true, Version: 10})
+	if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e1", Operation: "POST", ID: 7, Version: 10}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := search("kubernetes"); !equalIDs(got, []int64{7}) {
+		t.Fatalf("expected the created course, got %v", got)
+	}
+
+	t.Run("Stale events don't overwrite newer data", func(t *testing.T) {
+		api.set(domain.CourseUpdate{ID: 7, Name: "Kubernetes avanzado", Category: "DevOps", Available: true, Version: 20})
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e2", Operation: "UPDATE", ID: 7, Version: 20}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e0", Operation: "DELETE", ID: 7, Version: 5}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := search("avanzado"); !equalIDs(got, []int64{7}) {
+			t.Errorf("expected the updated course to stay indexed, got %v", got)
+		}
+	})
+
+	t.Run("Deletes leave a tombstone that stops older updates", func(t *testing.T) {
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e3", Operation: "DELETE", ID: 7, Version: 30}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e4", Operation: "UPDATE", ID: 7, Version: 25}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := search("kubernetes"); len(got) != 0 {
+			t.Errorf("expected the deleted course not to come back, got %v", got)
+		}
+	})
+
+	t.Run("Duplicated events are ignored", func(t *testing.T) {
+		api.set(domain.CourseUpdate{ID: 8, Name: "Terraform", Category: "DevOps", Available: true, Version: 40})
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e5", Operation: "POST", ID: 8, Version: 40}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		api.set(domain.CourseUpdate{ID: 8, Name: "Ansible", Category: "DevOps", Available: true, Version: 50})
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e5", Operation: "POST", ID: 8, Version: 40}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := search("terraform"); !equalIDs(got, []int64{8}) {
+			t.Errorf("expected the redelivered event to be skipped, got %v", got)
+		}
+	})
+
+	t.Run("Retried events don't overwrite newer ones", func(t *testing.T) {
+		// La modificación v60 falla y va a la cola de reintentos; mientras
+		// espera, se aplican la v70 y la baja v80 del mismo curso
+		api.set(domain.CourseUpdate{ID: 10, Name: "Docker", Category: "DevOps", Available: true, Version: 50})
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e7", Operation: "POST", ID: 10, Version: 50}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		api.setDown(true)
+		retried := domain.CourseUpdate{EventID: "e8", Operation: "UPDATE", ID: 10, Version: 60}
+		if err := svc.HandleCourseUpdate(retried); err == nil {
+			t.Fatalf("expected the update to fail while courses-api is down")
+		}
+		api.setDown(false)
+
+		api.set(domain.CourseUpdate{ID: 10, Name: "Docker Compose", Category: "DevOps", Available: true, Version: 70})
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e9", Operation: "UPDATE", ID: 10, Version: 70}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := svc.HandleCourseUpdate(retried); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := search("compose"); !equalIDs(got, []int64{10}) {
+			t.Errorf("expected the newer update to stay indexed, got %v", got)
+		}
+
+		// Una modificación reintentada que llega después de la baja no revive el
+		// curso, aunque courses-api todavía devuelva la v70
+		if err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e10", Operation: "DELETE", ID: 10, Version: 80}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		retried = domain.CourseUpdate{EventID: "e11", Operation: "UPDATE", ID: 10, Version: 75}
+		if err := svc.HandleCourseUpdate(retried); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := search("docker"); len(got) != 0 {
+			t.Errorf("expected the retried update not to bring back the deleted course, got %v", got)
+		}
+	})
+
+	t.Run("Unknown operations are invalid events", func(t *testing.T) {
+		err := svc.HandleCourseUpdate(domain.CourseUpdate{EventID: "e6", Operation: "PATCH", ID: 9})
+		if !errors.Is(err, domain.ErrInvalidEvent) {
+			t.Errorf("expected an invalid event error, got %v", err)
+		}
+	})
+}
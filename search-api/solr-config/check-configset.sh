@@ -0,0 +1,113 @@
+#!/usr/bin/env bash
+# Verifica que el configset "courses" carga en SolR 8.11: levanta un SolR
+# descartable, crea cores con la CoreAdmin API igual que el reindex de
+# search-api (CREATE con configSet=courses) y prueba sobre ellos los
+# manejadores y recursos que usa search-api: /update, /search con el corrector
+# ortográfico, /mlt, /get, los sinónimos administrados, SWAP y UNLOAD.
+#
+# Uso: search-api/solr-config/check-configset.sh   (requiere docker y curl)
+set -euo pipefail
+
+image="${SOLR_IMAGE:-solr:8.11.1}"
+port="${SOLR_CHECK_PORT:-8984}"
+base="http://localhost:${port}/solr"
+config_dir="$(cd "$(dirname "$0")/config" && pwd)"
+
+# Se trabaja sobre una copia: SolR escribe en conf/ los recursos administrados
+work="$(mktemp -d)"
+container=""
+cleanup() {
+	if [ -n "$container" ]; then
+		docker rm -f "$container" >/dev/null 2>&1 || true
+	fi
+	rm -rf "$work"
+}
+trap cleanup EXIT
+
+cp -r "$config_dir" "$work/conf"
+chmod -R a+rwX "$work"
+
+fail() {
+	echo "ERROR: $*" >&2
+	if [ -n "$container" ]; then
+		docker logs --tail 50 "$container" >&2 || true
+	fi
+	exit 1
+}
+
+# request METHOD PATH [BODY]: imprime la respuesta y falla si SolR no responde 2xx
+request() {
+	local method="$1" path="$2" body="${3:-}"
+	if [ -n "$body" ]; then
+		curl -fsS -X "$method" -H 'Content-Type: application/json' --data "$body" "${base}/${path}" ||
+			fail "$method $path"
+	else
+		curl -fsS -X "$method" "${base}/${path}" || fail "$method $path"
+	fi
+}
+
+# expect TEXTO SALIDA: falla si la salida no contiene el texto
+expect() {
+	case "$2" in
+	*"$1"*) ;;
+	*) fail "se esperaba '$1' en: $2" ;;
+	esac
+}
+
+container="$(docker run -d -p "${port}:8983" \
+	-v "$work/conf:/var/solr/data/configsets/courses/conf" \
+	"$image")"
+
+echo "Esperando a SolR en ${base}..."
+for _ in $(seq 1 60); do
+	if curl -fsS "${base}/admin/cores?action=STATUS&wt=json" >/dev/null 2>&1; then
+		break
+	fi
+	sleep 1
+done
+request GET "admin/cores?action=STATUS&wt=json" >/dev/null
+
+echo "Creando los cores desde el configset..."
+for core in courses courses_reindex_check; do
+	out="$(request GET "admin/cores?action=CREATE&name=${core}&instanceDir=${core}&configSet=courses&wt=json")"
+	expect '"status":0' "$out"
+done
+
+echo "Sinónimos administrados..."
+request PUT "courses/schema/analysis/synonyms/spanish" '{"js":["javascript"]}' >/dev/null
+request GET "admin/cores?action=RELOAD&core=courses&wt=json" >/dev/null
+
+echo "Indexando..."
+request POST "courses/update?commit=true&wt=json" '[
+	{"id":"1","name":"Programación en JavaScript","category":"Programación","description":"Aprendé JavaScript desde cero","rating":4.5,"capacity":30,"available":true,"instructor_id":7,"duration":"8 semanas","version":1},
+	{"id":"2","name":"JavaScript avanzado","category":"Programación","description":"Patrones y asincronismo en JavaScript","rating":4.0,"capacity":20,"available":true,"instructor_id":7,"duration":"6 semanas","version":1},
+	{"id":"3","name":"Cocina italiana","category":"Gastronomía","description":"Pastas y salsas","rating":3.5,"capacity":10,"available":false,"instructor_id":8,"duration":"4 semanas","version":1},
+	{"id":"4","deleted":true,"version":2}
+]' >/dev/null
+
+echo "Consultando /search, /mlt y el corrector..."
+out="$(request GET "courses/search?q=js&fq=-deleted:true&wt=json")"
+expect '"numFound":2' "$out"
+out="$(request GET "courses/search?q=javscript&fq=-deleted:true&spellcheck=true&wt=json")"
+expect '"collation"' "$out"
+out="$(request GET "courses/mlt?q=id:1&fq=-deleted:true&wt=json")"
+expect '"id":"2"' "$out"
+out="$(request GET "courses/select?q=*:*&fq=category_facet:Programaci%C3%B3n&sort=name_sort+asc&wt=json")"
+expect '"numFound":2' "$out"
+
+echo "Obteniendo versiones sin confirmar con /get..."
+request POST "courses_reindex_check/update?commitWithin=60000&wt=json" '[
+	{"id":"5","name":"Curso sin confirmar","version":7}
+]' >/dev/null
+out="$(request GET "courses_reindex_check/select?q=id:5&wt=json")"
+expect '"numFound":0' "$out"
+out="$(request GET "courses_reindex_check/get?ids=5,6&fl=id,version&wt=json")"
+expect '"version":7' "$out"
+
+echo "Intercambiando y descargando cores..."
+request GET "admin/cores?action=SWAP&core=courses&other=courses_reindex_check&wt=json" >/dev/null
+out="$(request GET "courses/select?q=*:*&wt=json")"
+expect '"numFound":0' "$out"
+request GET "admin/cores?action=UNLOAD&core=courses_reindex_check&deleteIndex=true&deleteDataDir=true&wt=json" >/dev/null
+
+echo "OK: el configset courses carga y responde en ${image}"
@@ -0,0 +1,10 @@
+// Package solrconfig expone los archivos del configset de SolR que también usa
+// el motor de búsqueda en memoria, para que ambos motores analicen igual
+package solrconfig
+
+import _ "embed"
+
+// StopwordsES es la lista de palabras vacías del tipo text_es (config/lang/stopwords_es.txt)
+//
+//go:embed config/lang/stopwords_es.txt
+var StopwordsES string
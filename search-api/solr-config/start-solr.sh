@@ -0,0 +1,24 @@
+#!/usr/bin/env bash
+# Arranque de SolR para docker-compose: instala el configset "courses" del
+# proyecto en SOLR_HOME y crea el core "courses" con ese configset, el mismo con
+# el que search-api crea los cores al reindexar. Así todos los cores comparten
+# esquema, solrconfig y sinónimos administrados.
+set -euo pipefail
+
+configsets=/var/solr/data/configsets
+
+init-var-solr
+
+# Se copia en cada arranque para tomar los cambios del repositorio; los
+# recursos administrados (sinónimos) que SolR guarda en conf/ se conservan.
+mkdir -p "$configsets/courses/conf"
+cp -r /opt/solr/server/solr/configsets/courses/conf/. "$configsets/courses/conf/"
+
+# Después de un reindex el core "courses" vive en el directorio del core con
+# el que se intercambió, así que se lo busca por nombre y no por directorio.
+if ! grep -qsx 'name=courses' /var/solr/data/*/core.properties; then
+	mkdir -p /var/solr/data/courses
+	printf 'name=courses\nconfigSet=courses\n' >/var/solr/data/courses/core.properties
+fi
+
+exec solr-foreground
@@ -1,20 +1,27 @@
 package users
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"strings"
 	domain "users-api/domain/users"
 )
 
+// maxBatchIDs limits how many users can be requested at once with ?ids=
+const maxBatchIDs = 100
+
 type Service interface {
 	GetAll() ([]domain.User, error)
 	GetByID(id int64) (domain.User, error)
-	Create(user domain.User) (int64, error)
-	Update(user domain.User) error
+	GetByIDs(ids []int64) ([]domain.User, error)
+	Create(user domain.User, callerType string) (int64, error)
+	Update(user domain.User, callerType string) error
 	Delete(id int64) error
 	Login(username string, password string) (domain.LoginResponse, error)
+	CallerType(token string) string
 }
 
 type Controller struct {
@@ -28,6 +35,12 @@ func NewController(service Service) Controller {
 }
 
 func (controller Controller) GetAll(c *gin.Context) {
+	// GET /users?ids=1,2,3 returns only those users
+	if c.Query("ids") != "" {
+		controller.getByIDs(c)
+		return
+	}
+
 	// Invoke service
 	users, err := controller.service.GetAll()
 	if err != nil {
@@ -41,6 +54,42 @@ func (controller Controller) GetAll(c *gin.Context) {
 	c.JSON(http.StatusOK, users)
 }
 
+// getByIDs returns the users with the IDs in the "ids" query parameter, in the
+// same order and skipping the ones that don't exist, so other APIs can fetch
+// many users with a single request
+func (controller Controller) getByIDs(c *gin.Context) {
+	// Parse the comma separated IDs
+	var ids []int64
+	for _, param := range strings.Split(c.Query("ids"), ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(param), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("invalid request: invalid user ID %q", param),
+			})
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > maxBatchIDs {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("invalid request: at most %d IDs are allowed", maxBatchIDs),
+		})
+		return
+	}
+
+	// Invoke service
+	users, err := controller.service.GetByIDs(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("error getting users: %s", err.Error()),
+		})
+		return
+	}
+
+	// Send response
+	c.JSON(http.StatusOK, users)
+}
+
 func (controller Controller) GetByID(c *gin.Context) {
 	// Parse user ID from HTTP request
 	userID := c.Param("id")
@@ -75,10 +124,10 @@ func (controller Controller) Create(c *gin.Context) {
 		return
 	}
 
-	// Invoke service
-	id, err := controller.service.Create(user)
+	// Invoke service. Registering an administrator requires an administrator's token
+	id, err := controller.service.Create(user, controller.callerType(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(userErrorStatus(err), gin.H{
 			"error": fmt.Sprintf("error creating user: %s", err.Error()),
 		})
 		return
@@ -113,9 +162,9 @@ func (controller Controller) Update(c *gin.Context) {
 	// Set the ID of the user to be updated
 	user.ID = id
 
-	// Invoke service
-	if err := controller.service.Update(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// Invoke service. Changing the user type requires an administrator's token
+	if err := controller.service.Update(user, controller.callerType(c)); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{
 			"error": fmt.Sprintf("error updating user: %s", err.Error()),
 		})
 		return
@@ -171,4 +220,21 @@ func (controller Controller) Login(c *gin.Context) {
 
 	// Send login with token
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// callerType returns the user type of the request's bearer token, if it has a valid one
+func (controller Controller) callerType(c *gin.Context) string {
+	return controller.service.CallerType(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+}
+
+// userErrorStatus maps the errors of Create and Update to an HTTP status
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrInvalidUserType):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrAdminRequired):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,5 +1,19 @@
 package users
 
+import "errors"
+
+// Tipos de usuario. Cualquiera puede registrarse como alumno; administrador
+// solo lo asigna otro administrador.
+const (
+	UserTypeStudent = "alumno"
+	UserTypeAdmin   = "administrador"
+)
+
+var (
+	ErrInvalidUserType = errors.New("invalid user type")
+	ErrAdminRequired   = errors.New("only an administrator can assign this user type")
+)
+
 type User struct {
 	ID       int64  `json:"id"`
 	Username string `json:"username"`
@@ -36,4 +36,33 @@ func (tokenizer JWT) GenerateToken(username string, userID int64, userType strin
 	}
 
 	return value, nil
-}
\ No newline at end of file
+}
+
+// ParseToken verifies the token signature and its expiration_date claim and
+// returns the user ID and type it was issued for
+func (tokenizer JWT) ParseToken(value string) (int64, string, error) {
+	token, err := jwt.Parse(value, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(tokenizer.config.Key), nil
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("error parsing JWT token: %w", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, "", fmt.Errorf("invalid JWT token claims")
+	}
+
+	// A token without a readable expiration is treated as expired
+	expirationDate, _ := claims["expiration_date"].(string)
+	expiration, err := time.Parse(time.RFC3339Nano, expirationDate)
+	if err != nil || time.Now().After(expiration) {
+		return 0, "", fmt.Errorf("JWT token expired")
+	}
+
+	userID, _ := claims["user_id"].(float64)
+	userType, _ := claims["user_type"].(string)
+	return int64(userID), userType, nil
+}
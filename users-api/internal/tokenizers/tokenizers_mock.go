@@ -14,3 +14,8 @@ func (m *Mock) GenerateToken(username string, userID int64, userType string) (st
 	args := m.Called(username, userID, userType)
 	return args.String(0), args.Error(1)
 }
+
+func (m *Mock) ParseToken(value string) (int64, string, error) {
+	args := m.Called(value)
+	return args.Get(0).(int64), args.String(1), args.Error(2)
+}
@@ -58,6 +58,14 @@ func main() {
 	// Services
 	service := services.NewService(mySQLRepo, cacheRepo, memcachedRepo, jwtTokenizer)
 
+	// Solo un administrador puede registrar a otro, así que el primero se crea
+	// al arrancar con ADMIN_USERNAME y ADMIN_PASSWORD si todavía no existe
+	if username, password := os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD"); username != "" && password != "" {
+		if err := service.EnsureAdmin(username, password); err != nil {
+			log.Printf("No se pudo crear el administrador inicial: %v", err)
+		}
+	}
+
 	// Handlers
 	controller := controllers.NewController(service)
 	helathService, err := helathServices.NewService() // Añadimos el manejo del error
@@ -50,6 +50,17 @@ func (repository Cache) GetByID(id int64) (users.User, error) {
 	return users.User{}, fmt.Errorf("cache miss for user ID %d", id)
 }
 
+// GetByIDs returns the cached users among the given IDs; misses are skipped
+func (repository Cache) GetByIDs(ids []int64) ([]users.User, error) {
+	usersList := make([]users.User, 0, len(ids))
+	for _, id := range ids {
+		if user, err := repository.GetByID(id); err == nil {
+			usersList = append(usersList, user)
+		}
+	}
+	return usersList, nil
+}
+
 func (repository Cache) GetByUsername(username string) (users.User, error) {
 	// Use username as cache key
 	userKey := fmt.Sprintf("user:username:%s", username)
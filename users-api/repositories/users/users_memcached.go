@@ -59,6 +59,32 @@ func (repository Memcached) GetByID(id int64) (users.User, error) {
 	return user, nil
 }
 
+// GetByIDs fetches the given IDs in a single round trip; misses are skipped
+func (repository Memcached) GetByIDs(ids []int64) ([]users.User, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = idKey(id)
+	}
+	items, err := repository.client.GetMulti(keys)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching users from memcached: %w", err)
+	}
+
+	usersList := make([]users.User, 0, len(items))
+	for _, key := range keys {
+		item, ok := items[key]
+		if !ok {
+			continue
+		}
+		var user users.User
+		if err := json.Unmarshal(item.Value, &user); err != nil {
+			return nil, fmt.Errorf("error unmarshaling user: %w", err)
+		}
+		usersList = append(usersList, user)
+	}
+	return usersList, nil
+}
+
 func (repository Memcached) GetByUsername(username string) (users.User, error) {
 	// Assume we store users with "username:<username>" as key
 	key := usernameKey(username)
@@ -29,6 +29,14 @@ func (m *Mock) GetByID(id int64) (users.User, error) {
 	return args.Get(0).(users.User), nil
 }
 
+func (m *Mock) GetByIDs(ids []int64) ([]users.User, error) {
+	args := m.Called(ids)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return args.Get(0).([]users.User), nil
+}
+
 func (m *Mock) GetByUsername(username string) (users.User, error) {
 	args := m.Called(username)
 	if err := args.Error(1); err != nil {
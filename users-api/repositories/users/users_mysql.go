@@ -92,6 +92,15 @@ func (repository MySQL) GetByID(id int64) (users.User, error) {
 	return user, nil
 }
 
+// GetByIDs fetches the users with the given IDs in a single query; missing IDs are skipped
+func (repository MySQL) GetByIDs(ids []int64) ([]users.User, error) {
+	var usersList []users.User
+	if err := repository.db.Where("id IN ?", ids).Find(&usersList).Error; err != nil {
+		return nil, fmt.Errorf("error fetching users by ids: %w", err)
+	}
+	return usersList, nil
+}
+
 func (repository MySQL) GetByUsername(username string) (users.User, error) {
 	var user users.User
 	if err := repository.db.Where("username = ?", username).First(&user).Error; err != nil {
@@ -11,6 +11,7 @@ import (
 type Repository interface {
 	GetAll() ([]dao.User, error)
 	GetByID(id int64) (dao.User, error)
+	GetByIDs(ids []int64) ([]dao.User, error)
 	GetByUsername(username string) (dao.User, error)
 	Create(user dao.User) (int64, error)
 	Update(user dao.User) error
@@ -19,6 +20,7 @@ type Repository interface {
 
 type Tokenizer interface {
 	GenerateToken(username string, userID int64, userType string) (string, error)
+	ParseToken(token string) (int64, string, error)
 }
 
 type Service struct {
@@ -89,6 +91,69 @@ func (service Service) GetByID(id int64) (domain.User, error) {
 	return service.convertUser(user), nil
 }
 
+// GetByIDs returns the users with the given IDs in the same order, skipping the
+// ones that don't exist. Like GetByID it checks the cache first, then memcached,
+// and only queries the main repository once for the remaining IDs.
+func (service Service) GetByIDs(ids []int64) ([]domain.User, error) {
+	found := make(map[int64]dao.User, len(ids))
+	missing := func() []int64 {
+		result := make([]int64, 0, len(ids))
+		for _, id := range ids {
+			if _, ok := found[id]; !ok {
+				result = append(result, id)
+			}
+		}
+		return result
+	}
+
+	// Check in cache first
+	cached, err := service.cacheRepository.GetByIDs(ids)
+	if err == nil {
+		for _, user := range cached {
+			found[user.ID] = user
+		}
+	}
+
+	// Check in memcached
+	if pending := missing(); len(pending) > 0 {
+		users, err := service.memcachedRepository.GetByIDs(pending)
+		if err == nil {
+			for _, user := range users {
+				found[user.ID] = user
+				if _, err := service.cacheRepository.Create(user); err != nil {
+					return nil, fmt.Errorf("error caching user after memcached retrieval: %w", err)
+				}
+			}
+		}
+	}
+
+	// Check in main repository
+	if pending := missing(); len(pending) > 0 {
+		users, err := service.mainRepository.GetByIDs(pending)
+		if err != nil {
+			return nil, fmt.Errorf("error getting users by IDs: %w", err)
+		}
+		for _, user := range users {
+			found[user.ID] = user
+			if _, err := service.cacheRepository.Create(user); err != nil {
+				return nil, fmt.Errorf("error caching user after main retrieval: %w", err)
+			}
+			if _, err := service.memcachedRepository.Create(user); err != nil {
+				return nil, fmt.Errorf("error saving user in memcached: %w", err)
+			}
+		}
+	}
+
+	result := make([]domain.User, 0, len(found))
+	for _, id := range ids {
+		if user, ok := found[id]; ok {
+			result = append(result, service.convertUser(user))
+			delete(found, id) // Repeated IDs are returned once
+		}
+	}
+	return result, nil
+}
+
 func (service Service) GetByUsername(username string) (domain.User, error) {
 	// Check in cache first
 	user, err := service.cacheRepository.GetByUsername(username)
@@ -122,7 +187,17 @@ func (service Service) GetByUsername(username string) (domain.User, error) {
 	return service.convertUser(user), nil
 }
 
-func (service Service) Create(user domain.User) (int64, error) {
+// Create registers a user. Anyone can register as a student (the default);
+// the administrator role can only be assigned by another administrator, so
+// callerType is the user type of the token that made the request, if any.
+func (service Service) Create(user domain.User, callerType string) (int64, error) {
+	if user.UserType == "" {
+		user.UserType = domain.UserTypeStudent
+	}
+	if err := checkUserType(user.UserType, callerType); err != nil {
+		return 0, err
+	}
+
 	// Hash the password
 	passwordHash := Hash(user.Password)
 
@@ -151,7 +226,18 @@ func (service Service) Create(user domain.User) (int64, error) {
 	return id, nil
 }
 
-func (service Service) Update(user domain.User) error {
+// Update replaces a user. Changing the user type is reserved to
+// administrators, so a student can't promote or demote anyone.
+func (service Service) Update(user domain.User, callerType string) error {
+	if user.UserType != "" {
+		if callerType != domain.UserTypeAdmin {
+			return domain.ErrAdminRequired
+		}
+		if err := checkUserType(user.UserType, callerType); err != nil {
+			return err
+		}
+	}
+
 	// Hash the password if provided
 	var passwordHash string
 	if user.Password != "" {
@@ -260,6 +346,47 @@ func (service Service) Login(username string, password string) (domain.LoginResp
 	}, nil
 }
 
+// EnsureAdmin creates the administrator with the given credentials if there is
+// no user with that username yet. It is how the first administrator is created,
+// since registering as one requires another administrator.
+func (service Service) EnsureAdmin(username string, password string) error {
+	if _, err := service.mainRepository.GetByUsername(username); err == nil {
+		return nil
+	}
+	if _, err := service.Create(domain.User{Username: username, Password: password, UserType: domain.UserTypeAdmin}, domain.UserTypeAdmin); err != nil {
+		return fmt.Errorf("error creating administrator %s: %w", username, err)
+	}
+	return nil
+}
+
+// CallerType returns the user type of a valid token, or an empty string if the
+// token is missing, expired or not signed by this API
+func (service Service) CallerType(token string) string {
+	if token == "" {
+		return ""
+	}
+	_, userType, err := service.tokenizer.ParseToken(token)
+	if err != nil {
+		return ""
+	}
+	return userType
+}
+
+// checkUserType validates the user type being assigned by a caller of callerType
+func checkUserType(userType string, callerType string) error {
+	switch userType {
+	case domain.UserTypeStudent:
+		return nil
+	case domain.UserTypeAdmin:
+		if callerType != domain.UserTypeAdmin {
+			return domain.ErrAdminRequired
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", domain.ErrInvalidUserType, userType)
+	}
+}
+
 func Hash(input string) string {
 	hash := md5.Sum([]byte(input))
 	return hex.EncodeToString(hash[:])
@@ -118,14 +118,54 @@ func TestService(t *testing.T) {
 		memcachedRepo.AssertExpectations(t)
 	})
 
+	t.Run("GetByIDs - Cache, Memcached and Main Repo", func(t *testing.T) {
+		user1 := dao.User{ID: 1, Username: "user1"}
+		user2 := dao.User{ID: 2, Username: "user2"}
+		user3 := dao.User{ID: 3, Username: "user3"}
+		cacheRepo.On("GetByIDs", []int64{3, 1, 2, 4}).Return([]dao.User{user1}, nil).Once()
+		memcachedRepo.On("GetByIDs", []int64{3, 2, 4}).Return([]dao.User{user2}, nil).Once()
+		cacheRepo.On("Create", user2).Return(int64(2), nil).Once()
+		mainRepo.On("GetByIDs", []int64{3, 4}).Return([]dao.User{user3}, nil).Once()
+		cacheRepo.On("Create", user3).Return(int64(3), nil).Once()
+		memcachedRepo.On("Create", user3).Return(int64(3), nil).Once()
+
+		result, err := usersService.GetByIDs([]int64{3, 1, 2, 4})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(result))
+		assert.Equal(t, "user3", result[0].Username)
+		assert.Equal(t, "user1", result[1].Username)
+		assert.Equal(t, "user2", result[2].Username)
+
+		mainRepo.AssertExpectations(t)
+		cacheRepo.AssertExpectations(t)
+		memcachedRepo.AssertExpectations(t)
+	})
+
+	t.Run("GetByIDs - Error in Main Repo", func(t *testing.T) {
+		cacheRepo.On("GetByIDs", []int64{1}).Return([]dao.User{}, nil).Once()
+		memcachedRepo.On("GetByIDs", []int64{1}).Return(nil, errors.New("memcached down")).Once()
+		mainRepo.On("GetByIDs", []int64{1}).Return(nil, errors.New("db error")).Once()
+
+		result, err := usersService.GetByIDs([]int64{1})
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.Equal(t, "error getting users by IDs: db error", err.Error())
+
+		mainRepo.AssertExpectations(t)
+		cacheRepo.AssertExpectations(t)
+		memcachedRepo.AssertExpectations(t)
+	})
+
 	t.Run("Create - Success", func(t *testing.T) {
-		newUser := dao.User{Username: "newuser", Password: service.Hash("password")}
+		newUser := dao.User{Username: "newuser", Password: service.Hash("password"), UserType: domain.UserTypeStudent}
 		mainRepo.On("Create", newUser).Return(int64(1), nil).Once()
 		newUser.ID = 1
 		cacheRepo.On("Create", newUser).Return(int64(1), nil).Once()
 		memcachedRepo.On("Create", newUser).Return(int64(1), nil).Once()
 
-		id, err := usersService.Create(domain.User{Username: "newuser", Password: "password"})
+		id, err := usersService.Create(domain.User{Username: "newuser", Password: "password"}, "")
 
 		assert.NoError(t, err)
 		assert.Equal(t, int64(1), id)
@@ -136,10 +176,10 @@ func TestService(t *testing.T) {
 	})
 
 	t.Run("Create - Error", func(t *testing.T) {
-		newUser := dao.User{Username: "newuser", Password: service.Hash("password")}
+		newUser := dao.User{Username: "newuser", Password: service.Hash("password"), UserType: domain.UserTypeStudent}
 		mainRepo.On("Create", newUser).Return(int64(0), errors.New("db error")).Once()
 
-		id, err := usersService.Create(domain.User{Username: "newuser", Password: "password"})
+		id, err := usersService.Create(domain.User{Username: "newuser", Password: "password", UserType: domain.UserTypeStudent}, "")
 
 		assert.Error(t, err)
 		assert.Equal(t, int64(0), id)
@@ -150,6 +190,56 @@ func TestService(t *testing.T) {
 		memcachedRepo.AssertExpectations(t)
 	})
 
+	t.Run("Create - Administrator requires an administrator", func(t *testing.T) {
+		for _, callerType := range []string{"", domain.UserTypeStudent} {
+			_, err := usersService.Create(domain.User{Username: "admin", Password: "password", UserType: domain.UserTypeAdmin}, callerType)
+			assert.ErrorIs(t, err, domain.ErrAdminRequired)
+		}
+
+		newUser := dao.User{Username: "admin", Password: service.Hash("password"), UserType: domain.UserTypeAdmin}
+		mainRepo.On("Create", newUser).Return(int64(2), nil).Once()
+		newUser.ID = 2
+		cacheRepo.On("Create", newUser).Return(int64(2), nil).Once()
+		memcachedRepo.On("Create", newUser).Return(int64(2), nil).Once()
+
+		id, err := usersService.Create(domain.User{Username: "admin", Password: "password", UserType: domain.UserTypeAdmin}, domain.UserTypeAdmin)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), id)
+
+		mainRepo.AssertExpectations(t)
+		cacheRepo.AssertExpectations(t)
+		memcachedRepo.AssertExpectations(t)
+	})
+
+	t.Run("Create - Invalid user type", func(t *testing.T) {
+		_, err := usersService.Create(domain.User{Username: "svc", Password: "password", UserType: "servicio"}, domain.UserTypeAdmin)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidUserType)
+
+		mainRepo.AssertExpectations(t)
+	})
+
+	t.Run("Update - User type requires an administrator", func(t *testing.T) {
+		userToUpdate := domain.User{ID: 1, Username: "user1", Password: "password", UserType: domain.UserTypeAdmin}
+		err := usersService.Update(userToUpdate, domain.UserTypeStudent)
+
+		assert.ErrorIs(t, err, domain.ErrAdminRequired)
+
+		mainRepo.AssertExpectations(t)
+	})
+
+	t.Run("CallerType", func(t *testing.T) {
+		tokenizer.On("ParseToken", "admin-token").Return(int64(2), domain.UserTypeAdmin, nil).Once()
+		tokenizer.On("ParseToken", "expired-token").Return(int64(0), "", errors.New("JWT token expired")).Once()
+
+		assert.Equal(t, domain.UserTypeAdmin, usersService.CallerType("admin-token"))
+		assert.Equal(t, "", usersService.CallerType("expired-token"))
+		assert.Equal(t, "", usersService.CallerType(""))
+
+		tokenizer.AssertExpectations(t)
+	})
+
 	t.Run("Update - Success", func(t *testing.T) {
 		updateUser := dao.User{ID: 1, Username: "updateduser", Password: service.Hash("newpassword")}
 		mainRepo.On("Update", updateUser).Return(nil).Once()
@@ -157,7 +247,7 @@ func TestService(t *testing.T) {
 		memcachedRepo.On("Update", updateUser).Return(nil).Once()
 
 		userToUpdate := domain.User{ID: 1, Username: "updateduser", Password: "newpassword"}
-		err := usersService.Update(userToUpdate)
+		err := usersService.Update(userToUpdate, "")
 
 		assert.NoError(t, err)
 
@@ -171,7 +261,7 @@ func TestService(t *testing.T) {
 		mainRepo.On("Update", updateUser).Return(errors.New("db error")).Once()
 
 		userToUpdate := domain.User{ID: 1, Username: "updateduser", Password: "newpassword"}
-		err := usersService.Update(userToUpdate)
+		err := usersService.Update(userToUpdate, "")
 
 		assert.Error(t, err)
 		assert.Equal(t, "error updating user: db error", err.Error())